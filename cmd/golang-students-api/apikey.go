@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+)
+
+const apiKeyUsage = `usage: golang-students-api [-config path] apikey <command>
+
+commands:
+  create <name> [roles]  create an API key for a client with comma-separated roles, e.g. teacher,registrar,
+                         and print it, it cannot be shown again
+  roles <name> <roles>   replace the roles of the API key of a client
+  list                   list every API key, without the keys themselves
+  revoke <name>          revoke the API key of a client`
+
+// apiKeyArgs holds the number of arguments of each apikey command, including the command itself.
+var apiKeyArgs = map[string][]int{
+	"create": {2, 3},
+	"roles":  {3},
+	"list":   {1},
+	"revoke": {2},
+}
+
+// runAPIKey implements the apikey subcommand, which manages the API keys clients authenticate with.
+func runAPIKey(cfg *config.Config, args []string) error {
+	if len(args) == 0 || !slices.Contains(apiKeyArgs[args[0]], len(args)) {
+		return errors.New(apiKeyUsage)
+	}
+
+	if cfg.StorageDriver == "memory" {
+		return errors.New("the memory storage cannot keep API keys between runs")
+	}
+
+	store, err := newStorage(cfg) // the schema must be up to date for the api_keys table to exist
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		key, record := auth.GenerateAPIKey(args[1], time.Now())
+
+		if len(args) == 3 {
+			record.Roles = parseRoles(cfg, args[2])
+		}
+
+		_, err := store.CreateAPIKey(ctx, record)
+		if errors.Is(err, storage.ErrConflict) {
+			return fmt.Errorf("an API key named %q already exists, revoked keys keep their name", args[1])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(key)
+	case "list":
+		keys, err := store.GetAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+		fmt.Fprintln(table, "NAME\tKEY\tROLES\tCREATED AT\tREVOKED AT")
+
+		for _, key := range keys {
+			revokedAt := ""
+			if key.RevokedAt != nil {
+				revokedAt = key.RevokedAt.Local().Format(time.DateTime)
+			}
+
+			fmt.Fprintf(table, "%s\t%s...\t%s\t%s\t%s\n", key.Name, key.Prefix, strings.Join(key.Roles, ","), key.CreatedAt.Local().Format(time.DateTime), revokedAt)
+		}
+
+		return table.Flush()
+	case "roles":
+		if err := store.SetAPIKeyRoles(ctx, args[1], parseRoles(cfg, args[2])); err != nil {
+			return err
+		}
+
+		fmt.Printf("set the roles of %s\n", args[1])
+	case "revoke":
+		if err := store.RevokeAPIKey(ctx, args[1], time.Now()); err != nil {
+			return err
+		}
+
+		fmt.Printf("revoked %s\n", args[1])
+	default:
+		return errors.New(apiKeyUsage)
+	}
+
+	return nil
+}
+
+// parseRoles splits comma-separated roles, warning about the roles the configured policy does not define, which
+// grant nothing until it does.
+func parseRoles(cfg *config.Config, list string) []string {
+	defined := cfg.Auth.Roles
+	if len(defined) == 0 {
+		defined = auth.DefaultRoles
+	}
+
+	roles := []string{}
+
+	for _, role := range strings.Split(list, ",") {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			continue
+		}
+
+		if _, ok := defined[role]; !ok {
+			fmt.Fprintf(os.Stderr, "warning: role %s is not defined by the policy and grants no permissions\n", role)
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles
+}
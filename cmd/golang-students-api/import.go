@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/csvimport"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/validation"
+)
+
+const importUsage = `usage: golang-students-api [-config path] import [flags] <file.csv>
+
+Creates a student for every row of a CSV file whose first row is a header; - reads the file from stdin.
+
+flags:
+  -dry-run            validate every row without writing anything
+  -atomic=false       import the valid rows even if other rows have errors
+  -map Header=field   map a CSV header to name, email or age, may be repeated`
+
+// mappingFlag collects the repeated -map flags of the import subcommand.
+type mappingFlag []string
+
+func (m *mappingFlag) String() string {
+	return strings.Join(*m, ", ")
+}
+
+func (m *mappingFlag) Set(value string) error {
+	*m = append(*m, value)
+
+	return nil
+}
+
+// runImport implements the import subcommand, which imports students from a CSV file like POST /api/students/import.
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard) // the usage below is printed instead
+
+	var mappings mappingFlag
+
+	dryRun := flags.Bool("dry-run", false, "")
+	atomic := flags.Bool("atomic", true, "")
+	flags.Var(&mappings, "map", "")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	mapping, err := csvimport.ParseMapping(mappings)
+	if err != nil {
+		return err
+	}
+
+	input := os.Stdin
+
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		input = file
+	}
+
+	validate, err := validation.New(cfg.Validation) // rows are checked against the rules of the server
+	if err != nil {
+		return err
+	}
+
+	storage, err := newStorage(cfg) // the schema has to be up to date to import into it
+	if err != nil {
+		return err
+	}
+
+	defer storage.Close()
+
+	report, err := csvimport.Import(context.Background(), storage, input, csvimport.Options{
+		Mapping:  csvimport.MergeMappings(cfg.Import.HeaderMapping, mapping),
+		DryRun:   *dryRun,
+		Atomic:   *atomic,
+		Validate: validate,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printImportReport(report)
+}
+
+// printImportReport prints the outcome of an import and a table of its row errors, and fails if there are any.
+func printImportReport(report csvimport.Report) error {
+	if report.DryRun {
+		fmt.Printf("dry run: %d rows, %d valid\n", report.Rows, report.Valid)
+	} else {
+		fmt.Printf("%d rows, %d valid, %d imported\n", report.Rows, report.Valid, report.Imported)
+	}
+
+	if len(report.IgnoredColumns) > 0 {
+		fmt.Printf("ignored columns: %s\n", strings.Join(report.IgnoredColumns, ", "))
+	}
+
+	if len(report.Errors) == 0 {
+		return nil
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "\nROW\tCOLUMN\tERROR")
+
+	rows := map[int]bool{} // a row can have several errors
+
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(table, "%d\t%s\t%s\n", rowErr.Row, rowErr.Column, rowErr.Message)
+
+		rows[rowErr.Row] = true
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%d rows have errors", len(rows))
+}
@@ -2,18 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
 	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
 	"github.com/AnshSinghSonkhia/golang-students-api/internal/http/handlers/student"
-	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/sqlite"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/http/middleware"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/redact"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/cursor"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/validation"
 )
 
 func main() {
@@ -21,40 +28,156 @@ func main() {
 
 	cfg := config.MustLoad()
 
+	// mask the personal data of students in the logs, unless the environment turns it off
+
+	if cfg.Logging.RedactPII {
+		slog.SetDefault(slog.New(redact.NewHandler(slog.NewTextHandler(os.Stderr, nil), cfg.Logging.PIIKeys))) // the log package writes through it too
+	}
+
+	// run a subcommand instead of the server when one is given, e.g. `migrate up`
+
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(cfg, args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	// database setup
 
-	storage, err := sqlite.New(cfg) // initialize the SQLite database with the configuration
+	storage, err := newStorage(cfg) // initialize the storage backend selected by the configuration
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %s", err.Error()) // log an error if the storage initialization fails
 	}
 
-	// log the storage path
-	slog.Info("Storage initialized", slog.String("storage_path", cfg.StoragePath), slog.String("env", cfg.Env), slog.String("version", "1.0.0"))
+	defer storage.Close() // close the database once the server is shut down
+
+	// log the storage backend
+	slog.Info("Storage initialized", slog.String("storage_driver", cfg.StorageDriver), slog.String("env", cfg.Env), slog.String("version", "1.0.0"))
+
+	// pagination cursors are signed so that clients cannot forge them
+
+	if cfg.CursorSecret == "" {
+		slog.Warn("cursor_secret is not set, pagination cursors will not survive a restart")
+	}
+
+	cursors, err := cursor.New([]byte(cfg.CursorSecret))
+	if err != nil {
+		log.Fatalf("Failed to initialize cursor codec: %s", err.Error())
+	}
+
+	// every handler validates students with the same validator, set up with the rules of the configuration
+
+	validate, err := validation.New(cfg.Validation)
+	if err != nil {
+		log.Fatalf("Invalid validation rules: %s", err.Error())
+	}
 
-	// setup router
+	// setup router, every route requires a permission of the roles of the caller, see auth.Policy
 
 	router := http.NewServeMux()
 
-	// register the student handler for POST requests to /api/students
-	router.HandleFunc("POST /api/students", student.New(storage))
+	// register the student handler for POST requests to /api/students, retries with the same Idempotency-Key get the response to the first request
+	router.Handle("POST /api/students", middleware.Require(auth.PermissionWrite, middleware.Idempotency(student.New(storage, validate), storage, cfg.Idempotency.TTL)))
+
+	// register the student handler for POST requests to /api/students/bulk
+	router.Handle("POST /api/students/bulk", middleware.Require(auth.PermissionWrite, student.Bulk(storage, validate)))
+
+	// register the student import handler for POST requests to /api/students/import
+	router.Handle("POST /api/students/import", middleware.Require(auth.PermissionWrite, student.Import(storage, cfg.Import.HeaderMapping, validate)))
+
+	// register the student export handler for GET requests to /api/students/export
+	router.Handle("GET /api/students/export", middleware.Require(auth.PermissionRead, student.Export(storage)))
+
+	// register the student search handler for GET requests to /api/students/search
+	router.Handle("GET /api/students/search", middleware.Require(auth.PermissionRead, student.Search(storage)))
 
 	// register the student handler for GET requests to /api/students/{id}
-	router.HandleFunc("GET /api/students/{id}", student.GetByID(storage))
+	router.Handle("GET /api/students/{id}", middleware.Require(auth.PermissionRead, student.GetByID(storage)))
 
 	// register the student handler for GET requests to /api/students
-	router.HandleFunc("GET /api/students", student.GetList(storage))
+	router.Handle("GET /api/students", middleware.Require(auth.PermissionRead, student.GetList(storage, cursors)))
 
 	// register the student handler for PUT requests to /api/students/{id}
-	router.HandleFunc("PUT /api/students/{id}", student.Update(storage))
+	router.Handle("PUT /api/students/{id}", middleware.Require(auth.PermissionWrite, student.Update(storage, validate)))
+
+	// register the student handler for PATCH requests to /api/students/{id}
+	router.Handle("PATCH /api/students/{id}", middleware.Require(auth.PermissionWrite, student.Patch(storage, validate)))
 
 	// register the student handler for DELETE requests to /api/students/{id}
-	router.HandleFunc("DELETE /api/students/{id}", student.Delete(storage))
+	router.Handle("DELETE /api/students/{id}", middleware.Require(auth.PermissionDelete, student.Delete(storage)))
+
+	// register the student handler for GET requests to /api/students/trash
+	router.Handle("GET /api/students/trash", middleware.Require(auth.PermissionRead, student.Trash(storage, cursors)))
+
+	// register the student handler for POST requests to /api/students/{id}/restore
+	router.Handle("POST /api/students/{id}/restore", middleware.Require(auth.PermissionWrite, student.Restore(storage)))
+
+	// register the student handler for DELETE requests to /api/students/trash/{id}
+	router.Handle("DELETE /api/students/trash/{id}", middleware.Require(auth.PermissionDelete, student.Purge(storage)))
+
+	// register the duplicate report handler for GET requests to /api/students/duplicates
+	router.Handle("GET /api/students/duplicates", middleware.Require(auth.PermissionRead, student.Duplicates(storage)))
+
+	// register the student merge handler for POST requests to /api/students/merge
+	router.Handle("POST /api/students/merge", middleware.Require(auth.PermissionDelete, student.Merge(storage)))
+
+	// register the student handler for GET requests to /api/students/{id}/history
+	router.Handle("GET /api/students/{id}/history", middleware.Require(auth.PermissionRead, student.History(storage)))
+
+	// register the student handler for GET requests to /api/students/{id}/diff
+	router.Handle("GET /api/students/{id}/diff", middleware.Require(auth.PermissionRead, student.Diff(storage)))
+
+	// register the audit log handler for GET requests to /api/audit
+	router.Handle("GET /api/audit", middleware.Require(auth.PermissionRead, student.Audit(storage)))
 
 	// setup server
 
+	// every request context derives from baseCtx, cancelling it aborts the storage queries of in-flight requests
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+
+	defer cancelRequests()
+
+	// expired idempotency keys are deleted in the background for as long as the server runs
+	go collectIdempotencyKeys(baseCtx, storage, cfg.Idempotency.CleanupInterval)
+
+	// every request gets an actor for the audit log and a response format from its Accept header
+	var handler http.Handler = middleware.Audit(middleware.Negotiate(router, "/api/students/export"))
+
+	// the roles of the caller grant it the permissions the routes require
+	policy, err := auth.NewPolicy(cfg.Auth.Roles)
+	if err != nil {
+		log.Fatalf("Invalid auth roles: %s", err.Error())
+	}
+
+	handler = middleware.Authorize(handler, policy)
+
+	// requests must carry an API key or a JWT unless authentication is disabled
+	if cfg.Auth.Enabled {
+		tokens, err := auth.NewTokenVerifier(cfg.Auth.JWT)
+		if err != nil {
+			log.Fatalf("Failed to load JWT keys: %s", err.Error())
+		}
+
+		if tokens == nil {
+			slog.Info("auth.jwt is not set, only API keys are accepted")
+		}
+
+		handler = middleware.Authenticate(handler, auth.New(storage, tokens))
+	} else {
+		slog.Warn("Authentication is disabled, anyone who can reach the server can change students")
+	}
+
 	server := http.Server{
-		Addr:    cfg.Addr,
-		Handler: router,
+		Addr:        cfg.Addr,
+		Handler:     middleware.RequestID(handler), // every request gets an ID, before anything else can fail
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
 
 	slog.Info("Server started", slog.String("address", cfg.Addr)) // log the server address
@@ -68,7 +191,7 @@ func main() {
 
 	go func() { // run server in a goroutine
 		err := server.ListenAndServe()
-		if err != nil {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) { // ErrServerClosed only means Shutdown was called
 			log.Fatalf("Failed to start server: %s", err.Error())
 		}
 	}()
@@ -85,7 +208,25 @@ func main() {
 	err = server.Shutdown(ctx) // shutdown the server gracefully
 	if err != nil {
 		slog.Error("Failed to shutdown server", slog.String("error", err.Error()))
+
+		cancelRequests() // requests still running after the grace period have their queries cancelled
+
+		server.Close()
 	} // log any error that occurs during shutdown
 
 	slog.Info("Server shutdown successfully")
 }
+
+// runCommand runs the subcommand named by the first argument.
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "import":
+		return runImport(cfg, args[1:])
+	case "apikey":
+		return runAPIKey(cfg, args[1:])
+	default:
+		return errors.New("unknown command " + args[0] + ", available commands: migrate, import, apikey")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/migrate"
+)
+
+const migrateUsage = `usage: golang-students-api [-config path] migrate <command>
+
+commands:
+  status        list every migration and whether it is applied
+  up            apply every pending migration
+  down          roll back the most recently applied migration
+  to <version>  migrate up or down to the given version, 0 rolls back everything`
+
+// runMigrate implements the migrate subcommand, which manages the database schema.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	storage, err := openStorage(cfg) // open the database without migrating it
+	if err != nil {
+		return err
+	}
+
+	defer storage.Close()
+
+	schema, ok := storage.(migratable)
+	if !ok {
+		return fmt.Errorf("the %s storage has no schema to migrate", cfg.StorageDriver)
+	}
+
+	migrator, err := schema.Migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	var ran []migrate.Migration
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	case "up":
+		ran, err = migrator.Up(ctx)
+	case "down":
+		ran, err = migrator.Down(ctx)
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+
+		ran, err = migrator.To(ctx, version)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	for _, migration := range ran {
+		fmt.Printf("ran %04d_%s\n", migration.Version, migration.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(ran) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+
+	return nil
+}
+
+// printMigrationStatus prints a table of every migration and its state.
+func printMigrationStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	for _, status := range statuses {
+		state, appliedAt := "pending", ""
+
+		if status.Applied {
+			state, appliedAt = "applied", status.AppliedAt.Local().Format(time.DateTime)
+		}
+
+		if status.Modified {
+			state = "applied, modified since"
+		}
+
+		if status.Missing {
+			state = "applied, file missing"
+		}
+
+		fmt.Fprintf(table, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+
+	return table.Flush()
+}
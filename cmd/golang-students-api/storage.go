@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/memory"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/migrate"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/postgres"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/sqlite"
+)
+
+// backend is a storage implementation as seen by main: the Storage used by the handlers,
+// plus what is needed to shut it down.
+type backend interface {
+	storage.Storage
+
+	Close() error
+}
+
+// migratable is implemented by the backends that have a database schema to manage.
+type migratable interface {
+	Migrator() (*migrate.Migrator, error)
+}
+
+// newStorage opens the storage backend selected by cfg.StorageDriver, ready to serve requests.
+func newStorage(cfg *config.Config) (backend, error) {
+	switch cfg.StorageDriver {
+	case "sqlite":
+		return sqlite.New(cfg)
+	case "postgres":
+		return postgres.New(cfg)
+	case "memory":
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// openStorage opens the storage backend selected by cfg.StorageDriver without migrating it.
+func openStorage(cfg *config.Config) (backend, error) {
+	switch cfg.StorageDriver {
+	case "sqlite":
+		return sqlite.Open(cfg.StoragePath)
+	case "postgres":
+		return postgres.Open(cfg.DSN)
+	case "memory":
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// collectIdempotencyKeys deletes the expired idempotency keys of the storage every interval until ctx is done.
+func collectIdempotencyKeys(ctx context.Context, store storage.Storage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleted, err := store.DeleteExpiredIdempotencyKeys(ctx, now)
+			if err != nil {
+				slog.Error("Failed to delete expired idempotency keys", slog.String("error", err.Error()))
+
+				continue
+			}
+
+			if deleted > 0 {
+				slog.Info("Deleted expired idempotency keys", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// apiKeyPrefix starts every API key, which tells them apart from JWTs in Bearer headers.
+const apiKeyPrefix = "sk_"
+
+// apiKeyDisplayLength is how many characters of a key are kept in its record to tell keys apart.
+const apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+// GenerateAPIKey returns a new random API key and the record to store for it, named name.
+func GenerateAPIKey(name string, now time.Time) (string, types.APIKey) {
+	secret := make([]byte, 32)
+	rand.Read(secret) // crypto/rand.Read never fails
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, types.APIKey{Name: name, Prefix: key[:apiKeyDisplayLength], Hash: HashAPIKey(key), CreatedAt: now}
+}
+
+// HashAPIKey returns the hash under which an API key is stored. Keys are random and long, so a fast hash is enough
+// to keep them from being recovered from a copy of the database.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(hash[:])
+}
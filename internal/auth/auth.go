@@ -0,0 +1,105 @@
+// Package auth authenticates the clients of the API, by static API keys or JWT bearer tokens, and carries the
+// authenticated principal in request contexts.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+)
+
+// APIKeyHeader carries an API key, as an alternative to an Authorization: Bearer header.
+const APIKeyHeader = "X-API-Key"
+
+// Methods a principal authenticated with, used as values of Principal.Method.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// ErrUnauthenticated is returned for requests without valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	Subject string   // name of the API key, or sub claim of the token
+	Method  string   // one of the Method* constants
+	Roles   []string // roles of the API key, or roles claim of the token, see Policy
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal of a request.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, if the request it belongs to was authenticated.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+
+	return principal, ok
+}
+
+// Authenticator checks the credentials of requests against the API keys of the storage and, when it has one,
+// a token verifier.
+type Authenticator struct {
+	keys   storage.Storage
+	tokens *TokenVerifier // nil when JWTs are not accepted
+}
+
+// New returns an authenticator of the API keys of keys and of the tokens verified by tokens, which may be nil.
+func New(keys storage.Storage, tokens *TokenVerifier) *Authenticator {
+	return &Authenticator{keys: keys, tokens: tokens}
+}
+
+// Authenticate returns the principal of the credentials of a request: an X-API-Key header, or an Authorization
+// header with a Bearer API key or JWT. Missing or invalid credentials return an error wrapping ErrUnauthenticated;
+// other errors mean the credentials could not be checked.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(r.Context(), key)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return Principal{}, fmt.Errorf("%w: an Authorization: Bearer or %s header is required", ErrUnauthenticated, APIKeyHeader)
+	}
+
+	scheme, credentials, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") || credentials == "" {
+		return Principal{}, fmt.Errorf("%w: the Authorization header must use the Bearer scheme", ErrUnauthenticated)
+	}
+
+	if strings.HasPrefix(credentials, apiKeyPrefix) {
+		return a.authenticateAPIKey(r.Context(), credentials)
+	}
+
+	if a.tokens == nil {
+		return Principal{}, fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+	}
+
+	return a.tokens.Verify(credentials)
+}
+
+// authenticateAPIKey looks up the principal of an API key by its hash.
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, key string) (Principal, error) {
+	stored, err := a.keys.GetAPIKeyByHash(ctx, HashAPIKey(key))
+	if errors.Is(err, storage.ErrNotFound) {
+		return Principal{}, fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+	}
+
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if stored.RevokedAt != nil {
+		return Principal{}, fmt.Errorf("%w: API key %s was revoked", ErrUnauthenticated, stored.Prefix)
+	}
+
+	return Principal{Subject: stored.Name, Method: MethodAPIKey, Roles: stored.Roles}, nil
+}
@@ -0,0 +1,211 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/memory"
+)
+
+var b64 = base64.RawURLEncoding
+
+// token encodes a JWT with the given header and claims, signed by sign.
+func token(t *testing.T, header, claims map[string]any, sign func(signed []byte) []byte) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return b64.EncodeToString(data)
+	}
+
+	signed := encode(header) + "." + encode(claims)
+
+	return signed + "." + b64.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+
+		return mac.Sum(nil)
+	}
+}
+
+func rs256(t *testing.T, key *rsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signature
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "use": "sig", "n": b64.EncodeToString(key.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(key.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1"}, // unsupported keys are skipped
+	}})
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := auth.NewTokenVerifier(config.JWT{Secret: "hs-secret", JWKSFile: path, Issuer: "school", Audience: "students-api", RolesClaim: "groups"})
+	if err != nil {
+		t.Fatalf("NewTokenVerifier: %v", err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := map[string]any{"sub": "alice", "iss": "school", "aud": []string{"other", "students-api"}, "exp": exp, "groups": []string{"teacher", "registrar"}}
+
+	tests := []struct {
+		name   string
+		header map[string]any
+		claims map[string]any
+		sign   func([]byte) []byte
+		ok     bool
+	}{
+		{"HS256", map[string]any{"alg": "HS256"}, valid, hs256("hs-secret"), true},
+		{"RS256", map[string]any{"alg": "RS256", "kid": "rsa-1"}, valid, rs256(t, key), true},
+		{"RS256 without kid", map[string]any{"alg": "RS256"}, valid, rs256(t, key), true},
+		{"single audience and role", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "iss": "school", "aud": "students-api", "exp": exp, "groups": "teacher"}, hs256("hs-secret"), true},
+		{"invalid roles", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "iss": "school", "aud": "students-api", "exp": exp, "groups": 1}, hs256("hs-secret"), false},
+		{"wrong secret", map[string]any{"alg": "HS256"}, valid, hs256("other"), false},
+		{"unknown kid", map[string]any{"alg": "RS256", "kid": "rsa-2"}, valid, rs256(t, key), false},
+		{"alg none", map[string]any{"alg": "none"}, valid, func([]byte) []byte { return nil }, false},
+		{"expired", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "iss": "school", "aud": "students-api", "exp": time.Now().Add(-time.Hour).Unix()}, hs256("hs-secret"), false},
+		{"no exp", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "iss": "school", "aud": "students-api"}, hs256("hs-secret"), false},
+		{"not yet valid", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "iss": "school", "aud": "students-api", "exp": exp, "nbf": exp}, hs256("hs-secret"), false},
+		{"wrong issuer", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "iss": "other", "aud": "students-api", "exp": exp}, hs256("hs-secret"), false},
+		{"wrong audience", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "iss": "school", "aud": "other", "exp": exp}, hs256("hs-secret"), false},
+		{"no subject", map[string]any{"alg": "HS256"}, map[string]any{"iss": "school", "aud": "students-api", "exp": exp}, hs256("hs-secret"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifier.Verify(token(t, tt.header, tt.claims, tt.sign))
+
+			if !tt.ok {
+				if !errors.Is(err, auth.ErrUnauthenticated) {
+					t.Fatalf("Verify returned %+v, %v, want ErrUnauthenticated", principal, err)
+				}
+
+				return
+			}
+
+			if err != nil || principal.Subject != "alice" || principal.Method != auth.MethodJWT || len(principal.Roles) == 0 || principal.Roles[0] != "teacher" {
+				t.Fatalf("Verify returned %+v, %v, want alice with the roles of the token", principal, err)
+			}
+		})
+	}
+
+	if _, err := verifier.Verify("not-a-token"); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("Verify of a malformed token returned %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	store := memory.New()
+
+	key, record := auth.GenerateAPIKey("ci", time.Now())
+	record.Roles = []string{"registrar"}
+	if _, err := store.CreateAPIKey(t.Context(), record); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := auth.New(store, nil)
+
+	authenticate := func(header, value string) (auth.Principal, error) {
+		req := httptest.NewRequest("GET", "/api/students", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		return authenticator.Authenticate(req)
+	}
+
+	for _, credentials := range [][2]string{{"Authorization", "Bearer " + key}, {"X-API-Key", key}} {
+		principal, err := authenticate(credentials[0], credentials[1])
+		if err != nil || principal.Subject != "ci" || principal.Method != auth.MethodAPIKey || !slices.Equal(principal.Roles, []string{"registrar"}) {
+			t.Fatalf("Authenticate with %s returned %+v, %v, want ci", credentials[0], principal, err)
+		}
+	}
+
+	for _, credentials := range [][2]string{{"", ""}, {"Authorization", "Basic " + key}, {"Authorization", "Bearer sk_wrong"}, {"Authorization", "Bearer a.b.c"}} {
+		if _, err := authenticate(credentials[0], credentials[1]); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("Authenticate with %q returned %v, want ErrUnauthenticated", credentials[1], err)
+		}
+	}
+
+	if err := store.RevokeAPIKey(t.Context(), "ci", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authenticate("X-API-Key", key); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("Authenticate with a revoked key returned %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy, err := auth.NewPolicy(nil)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	tests := []struct {
+		roles []string
+		want  []string
+	}{
+		{nil, nil},
+		{[]string{"unknown"}, nil},
+		{[]string{"teacher"}, []string{auth.PermissionRead}},
+		{[]string{"teacher", "registrar"}, []string{auth.PermissionPII, auth.PermissionRead, auth.PermissionWrite}},
+		{[]string{"admin"}, []string{auth.PermissionDelete, auth.PermissionPII, auth.PermissionRead, auth.PermissionWrite}},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Permissions(auth.Principal{Roles: tt.roles}); !slices.Equal(got, tt.want) {
+			t.Errorf("Permissions of %v = %v, want %v", tt.roles, got, tt.want)
+		}
+	}
+
+	if _, err := auth.NewPolicy(map[string][]string{"auditor": {"students:raed"}}); err == nil {
+		t.Fatal("NewPolicy accepted an unknown permission")
+	}
+
+	ctx := auth.WithPermissions(t.Context(), []string{auth.PermissionRead})
+
+	if !auth.Allowed(ctx, auth.PermissionRead) || auth.Allowed(ctx, auth.PermissionWrite) || auth.Allowed(t.Context(), auth.PermissionRead) {
+		t.Fatal("Allowed does not report the permissions of the context")
+	}
+}
@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+)
+
+// Signing algorithms of the tokens that are accepted.
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+)
+
+const (
+	minRSABits = 2048
+	clockSkew  = time.Minute // allowed difference between the clocks of the issuer and the server
+)
+
+// TokenVerifier verifies JWT bearer tokens signed with HS256 or RS256 keys.
+type TokenVerifier struct {
+	hmacKeys   map[string][]byte         // by key ID, "" for the configured secret
+	rsaKeys    map[string]*rsa.PublicKey // by key ID
+	issuer     string
+	audience   string
+	rolesClaim string
+	now        func() time.Time
+}
+
+// NewTokenVerifier returns a verifier of the tokens signed with the secret or the keys of the JWKS file of the
+// configuration, or nil if neither is set.
+func NewTokenVerifier(cfg config.JWT) (*TokenVerifier, error) {
+	if cfg.Secret == "" && cfg.JWKSFile == "" {
+		return nil, nil
+	}
+
+	v := &TokenVerifier{
+		hmacKeys:   map[string][]byte{},
+		rsaKeys:    map[string]*rsa.PublicKey{},
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		rolesClaim: cfg.RolesClaim,
+		now:        time.Now,
+	}
+
+	if cfg.Secret != "" {
+		v.hmacKeys[""] = []byte(cfg.Secret)
+	}
+
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWKS: %w", err)
+		}
+
+		if err := v.addJWKS(data); err != nil {
+			return nil, fmt.Errorf("reading JWKS %s: %w", cfg.JWKSFile, err)
+		}
+	}
+
+	return v, nil
+}
+
+// jwk is a JSON Web Key (RFC 7517) of the kinds that are supported: RSA public keys and symmetric keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"` // RSA modulus
+	E   string `json:"e"` // RSA exponent
+	K   string `json:"k"` // symmetric key
+}
+
+// addJWKS adds the signing keys of a JSON Web Key Set. Keys of other types or uses are skipped.
+func (v *TokenVerifier) addJWKS(data []byte) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	added := 0
+
+	for i, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		switch {
+		case key.Kty == "RSA" && (key.Alg == "" || key.Alg == algRS256):
+			public, err := key.rsaPublicKey()
+			if err != nil {
+				return fmt.Errorf("key %d (%q): %w", i, key.Kid, err)
+			}
+
+			v.rsaKeys[key.Kid] = public
+		case key.Kty == "oct" && (key.Alg == "" || key.Alg == algHS256):
+			secret, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("key %d (%q): invalid k", i, key.Kid)
+			}
+
+			v.hmacKeys[key.Kid] = secret
+		default:
+			continue
+		}
+
+		added++
+	}
+
+	if added == 0 {
+		return errors.New("no RS256 or HS256 signing keys")
+	}
+
+	return nil
+}
+
+// rsaPublicKey decodes the modulus and exponent of an RSA key.
+func (key jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, errors.New("invalid n")
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid e")
+	}
+
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+	if public.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA keys must have at least %d bits", minRSABits)
+	}
+
+	return public, nil
+}
+
+// stringList is a claim that is either a string or an array of strings, like aud.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = stringList{single}
+
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// claims holds the registered claims of a token that are checked.
+type claims struct {
+	Subject   string     `json:"sub"`
+	Issuer    string     `json:"iss"`
+	Audience  stringList `json:"aud"`
+	ExpiresAt *float64   `json:"exp"`
+	NotBefore *float64   `json:"nbf"`
+}
+
+// Verify checks the signature and claims of a token and returns its principal, with the roles of the roles claim
+// of the configuration. Tokens must have an exp and a sub claim, and the iss and aud claims of the configuration if
+// it sets them.
+func (v *TokenVerifier) Verify(token string) (Principal, error) {
+	header, payload, signature, err := splitToken(token)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeSegment(header, &head); err != nil {
+		return Principal{}, fmt.Errorf("%w: invalid token header", ErrUnauthenticated)
+	}
+
+	if !v.verifySignature(head.Alg, head.Kid, token[:len(header)+1+len(payload)], signature) {
+		return Principal{}, fmt.Errorf("%w: invalid token signature", ErrUnauthenticated)
+	}
+
+	var c claims
+	var all map[string]json.RawMessage // the roles claim is named by the configuration
+
+	if decodeSegment(payload, &c) != nil || decodeSegment(payload, &all) != nil {
+		return Principal{}, fmt.Errorf("%w: invalid token claims", ErrUnauthenticated)
+	}
+
+	if err := v.checkClaims(c); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	var roles stringList
+
+	if raw, ok := all[v.rolesClaim]; ok && v.rolesClaim != "" {
+		if err := json.Unmarshal(raw, &roles); err != nil {
+			return Principal{}, fmt.Errorf("%w: the %s claim must be a string or an array of strings", ErrUnauthenticated, v.rolesClaim)
+		}
+	}
+
+	return Principal{Subject: c.Subject, Method: MethodJWT, Roles: roles}, nil
+}
+
+// splitToken splits a compact JWS into its encoded header and payload and its decoded signature.
+func splitToken(token string) (string, string, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", "", nil, errors.New("malformed token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", nil, errors.New("malformed token signature")
+	}
+
+	return parts[0], parts[1], signature, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature reports whether the signature of the signed part of a token was made with the key of the given ID,
+// or with any key of the algorithm when the token names none. The algorithm picks the kind of key, so that tokens
+// cannot be verified with a key of another kind.
+func (v *TokenVerifier) verifySignature(alg, kid, signed string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case algHS256:
+		for id, secret := range v.hmacKeys {
+			if kid != "" && id != kid {
+				continue
+			}
+
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(signed))
+
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return true
+			}
+		}
+	case algRS256:
+		for id, public := range v.rsaKeys {
+			if kid != "" && id != kid {
+				continue
+			}
+
+			if rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		}
+	}
+
+	return false // including alg none and every other algorithm
+}
+
+// checkClaims checks the expiry, issuer and audience of a token.
+func (v *TokenVerifier) checkClaims(c claims) error {
+	now := v.now()
+
+	switch {
+	case c.Subject == "":
+		return errors.New("token has no sub claim")
+	case c.ExpiresAt == nil:
+		return errors.New("token has no exp claim")
+	case now.After(unixTime(*c.ExpiresAt).Add(clockSkew)):
+		return errors.New("token expired")
+	case c.NotBefore != nil && now.Add(clockSkew).Before(unixTime(*c.NotBefore)):
+		return errors.New("token is not valid yet")
+	case v.issuer != "" && c.Issuer != v.issuer:
+		return fmt.Errorf("token is not issued by %s", v.issuer)
+	case v.audience != "" && !slices.Contains(c.Audience, v.audience):
+		return fmt.Errorf("token is not meant for %s", v.audience)
+	}
+
+	return nil
+}
+
+// unixTime converts a NumericDate claim, in seconds since the epoch, to a time.
+func unixTime(seconds float64) time.Time {
+	return time.UnixMilli(int64(seconds * 1000))
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Permissions granted by roles and required by the routes of the API.
+const (
+	PermissionRead   = "students:read"   // read students and their history
+	PermissionWrite  = "students:write"  // create, update, restore and import students
+	PermissionDelete = "students:delete" // move students to the trash, merge and purge them
+	PermissionPII    = "students:pii"    // see the email addresses of students, which are masked otherwise
+)
+
+// Permissions lists every permission.
+var Permissions = []string{PermissionRead, PermissionWrite, PermissionDelete, PermissionPII}
+
+// DefaultRoles are the roles of a policy when the configuration defines none.
+var DefaultRoles = map[string][]string{
+	"teacher":   {PermissionRead},
+	"registrar": {PermissionRead, PermissionWrite, PermissionPII},
+	"admin":     {PermissionRead, PermissionWrite, PermissionDelete, PermissionPII},
+}
+
+// Policy maps the roles of principals to the permissions they grant.
+type Policy struct {
+	roles map[string][]string
+}
+
+// NewPolicy returns the policy of the given roles, or of DefaultRoles when there are none. Roles granting unknown
+// permissions are an error, as they are most likely typos.
+func NewPolicy(roles map[string][]string) (*Policy, error) {
+	if len(roles) == 0 {
+		roles = DefaultRoles
+	}
+
+	for role, permissions := range roles {
+		for _, permission := range permissions {
+			if !slices.Contains(Permissions, permission) {
+				return nil, fmt.Errorf("role %s grants unknown permission %q, expected one of %v", role, permission, Permissions)
+			}
+		}
+	}
+
+	return &Policy{roles: roles}, nil
+}
+
+// Permissions returns the permissions granted to a principal by its roles, sorted. Unknown roles grant nothing.
+func (p *Policy) Permissions(principal Principal) []string {
+	var granted []string
+
+	for _, role := range principal.Roles {
+		for _, permission := range p.roles[role] {
+			if !slices.Contains(granted, permission) {
+				granted = append(granted, permission)
+			}
+		}
+	}
+
+	slices.Sort(granted)
+
+	return granted
+}
+
+type permissionsKey struct{}
+
+// WithPermissions returns a copy of ctx carrying the permissions granted to a request.
+func WithPermissions(ctx context.Context, permissions []string) context.Context {
+	return context.WithValue(ctx, permissionsKey{}, permissions)
+}
+
+// Allowed reports whether the request ctx belongs to was granted a permission. Requests that were not authorized
+// are granted nothing.
+func Allowed(ctx context.Context, permission string) bool {
+	permissions, _ := ctx.Value(permissionsKey{}).([]string)
+
+	return slices.Contains(permissions, permission)
+}
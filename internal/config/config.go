@@ -4,6 +4,7 @@ import (
 	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -13,11 +14,87 @@ type HTTPServer struct {
 	Addr string `yaml:"address" env-required:"true"`
 }
 
+// Import holds the configuration of CSV imports.
+type Import struct {
+	// HeaderMapping maps CSV headers to the student fields name, email and age, e.g. "Full Name: name".
+	// Columns named like the fields themselves are always recognized.
+	HeaderMapping map[string]string `yaml:"header_mapping"`
+}
+
+// Validation holds the rules students are validated against. A maximum of 0 means no upper bound.
+type Validation struct {
+	NameMinLength int `yaml:"name_min_length" env-default:"1"`   // in characters
+	NameMaxLength int `yaml:"name_max_length" env-default:"100"` // in characters
+	MinAge        int `yaml:"min_age" env-default:"0"`
+	MaxAge        int `yaml:"max_age" env-default:"150"`
+
+	// EmailDomains lists the domains, along with their subdomains, that email addresses may use; any domain is
+	// allowed when it is empty. Domains are compared as written, without DNS lookups.
+	EmailDomains []string `yaml:"email_domains"`
+}
+
+// Idempotency holds the configuration of the Idempotency-Key header.
+type Idempotency struct {
+	TTL             time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`                          // how long the response to a key is replayed to retries
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"IDEMPOTENCY_CLEANUP_INTERVAL" env-default:"1h"` // how often expired keys are deleted
+}
+
+// Logging holds the configuration of the logs.
+type Logging struct {
+	// RedactPII masks the personal data of students in every log record: the values of the PIIKeys attributes, and
+	// the email addresses found anywhere else. It is meant to be turned off in local environments only.
+	RedactPII bool     `yaml:"redact_pii" env:"LOG_REDACT_PII" env-default:"true"`
+	PIIKeys   []string `yaml:"pii_keys" env:"LOG_PII_KEYS" env-default:"name,email"` // names of the attributes holding personal data
+}
+
+// Auth holds the configuration of authentication.
+type Auth struct {
+	// Enabled requires every request to carry an API key or a JWT. When false, requests are let through and name
+	// their actor with the X-Actor header.
+	Enabled bool `yaml:"enabled" env:"AUTH_ENABLED" env-default:"true"`
+	JWT     JWT  `yaml:"jwt"`
+
+	// Roles maps each role to the permissions it grants, e.g. "registrar: [students:read, students:write]". The
+	// default teacher, registrar and admin roles are used when neither Roles nor PolicyFile are set.
+	Roles map[string][]string `yaml:"roles"`
+
+	// PolicyFile is the path of a YAML file with a roles mapping, for policies shared between deployments. It
+	// cannot be combined with Roles.
+	PolicyFile string `yaml:"policy_file" env:"AUTH_POLICY_FILE"`
+}
+
+// Policy is the content of a policy file.
+type Policy struct {
+	Roles map[string][]string `yaml:"roles"`
+}
+
+// JWT holds the keys and expected claims of JWT bearer tokens, which are accepted when a secret or a JWKS file is
+// set. Tokens are signed with HS256 or RS256.
+type JWT struct {
+	Secret   string `yaml:"secret" env:"JWT_SECRET"`       // HS256 key
+	JWKSFile string `yaml:"jwks_file" env:"JWT_JWKS_FILE"` // path of a JSON Web Key Set of RS256 and HS256 keys
+	Issuer   string `yaml:"issuer" env:"JWT_ISSUER"`       // required iss claim, any when empty
+	Audience string `yaml:"audience" env:"JWT_AUDIENCE"`   // required aud claim, any when empty
+
+	RolesClaim string `yaml:"roles_claim" env:"JWT_ROLES_CLAIM" env-default:"roles"` // claim listing the roles of the subject
+}
+
 // Config holds the application configuration.
 type Config struct {
-	Env         string `yaml:"env" env:"ENV" env-required:"true" env-default:"production"`
-	StoragePath string `yaml:"storage_path" env-required:"true"`
-	HTTPServer  `yaml:"http_server"`
+	Env           string        `yaml:"env" env:"ENV" env-required:"true" env-default:"production"`
+	StorageDriver string        `yaml:"storage_driver" env:"STORAGE_DRIVER" env-default:"sqlite"` // storage backend: sqlite, postgres or memory
+	StoragePath   string        `yaml:"storage_path" env:"STORAGE_PATH"`                          // path of the SQLite database file
+	DSN           string        `yaml:"dsn" env:"DATABASE_DSN"`                                   // connection string of the PostgreSQL database
+	AutoMigrate   bool          `yaml:"auto_migrate" env:"AUTO_MIGRATE" env-default:"true"`       // apply pending schema migrations at startup
+	QueryTimeout  time.Duration `yaml:"query_timeout" env:"QUERY_TIMEOUT" env-default:"5s"`       // upper bound for a single storage query, 0 disables it
+	ExportTimeout time.Duration `yaml:"export_timeout" env:"EXPORT_TIMEOUT" env-default:"10m"`    // upper bound for streaming the students of an export, 0 disables it
+	CursorSecret  string        `yaml:"cursor_secret" env:"CURSOR_SECRET"`                        // key used to sign pagination cursors, a random one is used when empty
+	HTTPServer    `yaml:"http_server"`
+	Import        Import      `yaml:"import"`
+	Validation    Validation  `yaml:"validation"`
+	Idempotency   Idempotency `yaml:"idempotency"`
+	Auth          Auth        `yaml:"auth"`
+	Logging       Logging     `yaml:"logging"`
 }
 
 // MustLoad reads the configuration from a file specified by the CONFIG_PATH environment variable or command line flag.
@@ -54,5 +131,40 @@ func MustLoad() *Config {
 		log.Fatalf("Failed to read configuration file: %s", err.Error())
 	}
 
+	// Check that the selected storage driver has what it needs to connect
+	switch cfg.StorageDriver {
+	case "sqlite":
+		if cfg.StoragePath == "" {
+			log.Fatal("storage_path is required when storage_driver is sqlite")
+		}
+	case "postgres":
+		if cfg.DSN == "" {
+			log.Fatal("dsn is required when storage_driver is postgres")
+		}
+	case "memory":
+		// Nothing to connect to, students only live as long as the process
+	default:
+		log.Fatalf("Unknown storage_driver %q, expected sqlite, postgres or memory", cfg.StorageDriver)
+	}
+
+	if cfg.Idempotency.TTL <= 0 || cfg.Idempotency.CleanupInterval <= 0 {
+		log.Fatal("idempotency.ttl and idempotency.cleanup_interval must be positive")
+	}
+
+	// Read the roles from the policy file, so that the rest of the program only has to look at cfg.Auth.Roles
+	if cfg.Auth.PolicyFile != "" {
+		if len(cfg.Auth.Roles) > 0 {
+			log.Fatal("auth.roles and auth.policy_file cannot both be set")
+		}
+
+		var policy Policy
+
+		if err := cleanenv.ReadConfig(cfg.Auth.PolicyFile, &policy); err != nil {
+			log.Fatalf("Failed to read policy file: %s", err.Error())
+		}
+
+		cfg.Auth.Roles = policy.Roles
+	}
+
 	return &cfg // Return a pointer to the loaded configuration struct
 }
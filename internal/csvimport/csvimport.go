@@ -0,0 +1,303 @@
+// Package csvimport imports students from CSV rosters, as exported by spreadsheets. It is shared by the import
+// endpoint and the import subcommand.
+package csvimport
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+	"github.com/go-playground/validator/v10"
+)
+
+// Student fields a CSV column can be mapped to.
+const (
+	FieldName  = "name"
+	FieldEmail = "email"
+	FieldAge   = "age"
+)
+
+// fields lists every student field an import needs a column for.
+var fields = []string{FieldName, FieldEmail, FieldAge}
+
+// ErrInvalidCSV is returned when the CSV as a whole cannot be imported, e.g. because it has no header
+// or a field has no column. Problems with single rows are reported in the Report instead.
+var ErrInvalidCSV = errors.New("invalid CSV")
+
+// Options controls an import.
+type Options struct {
+	// Mapping maps CSV headers to student fields, on top of the field names themselves.
+	// Headers are matched case-insensitively and ignoring surrounding spaces.
+	Mapping map[string]string
+
+	DryRun bool // validate every row without writing anything
+	Atomic bool // import nothing unless every row is valid and can be written
+
+	Validate *validator.Validate // validator of the rows, from the validation package
+}
+
+// RowError is a problem with one row of an import.
+type RowError struct {
+	Row     int    `json:"row"`              // line of the row in the file, the header is on line 1
+	Column  string `json:"column,omitempty"` // header of the offending column, if the problem is with one field
+	Field   string `json:"field,omitempty"`  // student field of that column
+	Message string `json:"message"`
+}
+
+// CreatedRow links a row of an import to the student created from it.
+type CreatedRow struct {
+	Row int   `json:"row"`
+	ID  int64 `json:"id"`
+}
+
+// Report is the row-level outcome of an import.
+type Report struct {
+	DryRun         bool         `json:"dry_run"`
+	Rows           int          `json:"rows"`                      // data rows read, not counting the header
+	Valid          int          `json:"valid"`                     // rows that passed validation
+	Imported       int          `json:"imported"`                  // students created, 0 in a dry run
+	Created        []CreatedRow `json:"created,omitempty"`         // the row and ID of every created student
+	IgnoredColumns []string     `json:"ignored_columns,omitempty"` // headers that are not mapped to any field
+	Errors         []RowError   `json:"errors"`
+}
+
+// ParseMapping reads header mappings written as "Header=field", as taken by the import endpoint and subcommand.
+func ParseMapping(specs []string) (map[string]string, error) {
+	mapping := map[string]string{}
+
+	for _, spec := range specs {
+		header, field, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("invalid mapping %q, use Header=field", spec)
+		}
+
+		mapping[header] = strings.TrimSpace(field)
+	}
+
+	return mapping, nil
+}
+
+// MergeMappings returns a mapping with the entries of every given mapping, later ones taking precedence.
+func MergeMappings(mappings ...map[string]string) map[string]string {
+	merged := map[string]string{}
+
+	for _, mapping := range mappings {
+		maps.Copy(merged, mapping)
+	}
+
+	return merged
+}
+
+// normalizeHeader returns the form headers are compared in.
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.TrimSpace(header))
+}
+
+// columns maps the columns of a CSV file to student fields.
+type columns struct {
+	header map[string]string // student field -> header of its column
+	index  map[string]int    // student field -> column index
+}
+
+// mapColumns resolves the header row of a CSV file against the mapping.
+func mapColumns(header []string, mapping map[string]string) (columns, []string, error) {
+	lookup := map[string]string{}
+
+	for _, field := range fields {
+		lookup[field] = field
+	}
+
+	for name, field := range mapping {
+		field = strings.ToLower(field)
+
+		switch field {
+		case FieldName, FieldEmail, FieldAge:
+			lookup[normalizeHeader(name)] = field
+		default:
+			return columns{}, nil, fmt.Errorf("%w: header %q is mapped to unknown field %q", ErrInvalidCSV, name, field)
+		}
+	}
+
+	cols := columns{header: map[string]string{}, index: map[string]int{}}
+
+	var ignored []string
+
+	for i, name := range header {
+		field, ok := lookup[normalizeHeader(name)]
+		if !ok {
+			ignored = append(ignored, name)
+
+			continue
+		}
+
+		if _, taken := cols.index[field]; taken {
+			return columns{}, nil, fmt.Errorf("%w: columns %q and %q are both mapped to %s", ErrInvalidCSV, cols.header[field], name, field)
+		}
+
+		cols.header[field], cols.index[field] = name, i
+	}
+
+	for _, field := range fields {
+		if _, ok := cols.index[field]; !ok {
+			return columns{}, nil, fmt.Errorf("%w: no column for field %s", ErrInvalidCSV, field)
+		}
+	}
+
+	return cols, ignored, nil
+}
+
+// row is a data row that passed validation.
+type row struct {
+	line    int
+	student types.Student
+}
+
+// Import reads students from a CSV file whose first row is a header, validates every row with the rules of the
+// student endpoints and, unless it is a dry run, creates a student for every valid row in one batch.
+func Import(ctx context.Context, s storage.Storage, r io.Reader, opts Options) (Report, error) {
+	report := Report{DryRun: opts.DryRun, Errors: []RowError{}}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // short rows are reported as missing fields instead of failing the whole import
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return report, fmt.Errorf("%w: the file is empty", ErrInvalidCSV)
+	}
+
+	if err != nil {
+		return report, fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+	}
+
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // spreadsheets like to start UTF-8 files with a byte order mark
+
+	cols, ignored, err := mapColumns(header, opts.Mapping)
+	if err != nil {
+		return report, err
+	}
+
+	report.IgnoredColumns = ignored
+
+	var valid []row
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		report.Rows++
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Errors = append(report.Errors, RowError{Row: parseErr.StartLine, Message: parseErr.Err.Error()})
+
+			continue // the reader carries on with the next row
+		}
+
+		if err != nil {
+			return report, err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		student, rowErrors := parseRow(line, record, cols, opts.Validate)
+		if len(rowErrors) > 0 {
+			report.Errors = append(report.Errors, rowErrors...)
+
+			continue
+		}
+
+		valid = append(valid, row{line: line, student: student})
+	}
+
+	report.Valid = len(valid)
+
+	if opts.DryRun || len(valid) == 0 || (opts.Atomic && len(report.Errors) > 0) {
+		return report, nil // nothing to write
+	}
+
+	operations := make([]types.BatchOperation, len(valid))
+	for i, row := range valid {
+		operations[i] = types.BatchOperation{Op: types.BatchCreate, Student: row.student}
+	}
+
+	results, err := s.ApplyBatch(ctx, operations, opts.Atomic)
+	if err != nil {
+		return report, err
+	}
+
+	for i, result := range results {
+		switch {
+		case errors.Is(result.Err, storage.ErrBatchAborted):
+			// Rolled back because of another row, which is reported
+		case result.Err != nil:
+			report.Errors = append(report.Errors, RowError{Row: valid[i].line, Message: result.Err.Error()})
+		default:
+			report.Created = append(report.Created, CreatedRow{Row: valid[i].line, ID: result.ID})
+		}
+	}
+
+	report.Imported = len(report.Created)
+
+	return report, nil
+}
+
+// parseRow turns a CSV record into a student and validates it like the student endpoints do.
+func parseRow(line int, record []string, cols columns, validate *validator.Validate) (types.Student, []RowError) {
+	value := func(field string) string {
+		if i := cols.index[field]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+
+		return "" // a short row, reported as a missing value
+	}
+
+	var student types.Student
+	var rowErrors []RowError
+
+	student.Name = value(FieldName)
+	student.Email = value(FieldEmail)
+
+	if raw := value(FieldAge); raw != "" {
+		age, err := strconv.Atoi(raw)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: line, Column: cols.header[FieldAge], Field: FieldAge, Message: fmt.Sprintf("age %q is not an integer", raw)})
+		}
+
+		student.Age = age
+	}
+
+	if err := validate.Struct(student); err != nil {
+		var validateErrs validator.ValidationErrors
+		if !errors.As(err, &validateErrs) {
+			return student, []RowError{{Row: line, Message: err.Error()}}
+		}
+
+		for _, fieldErr := range validateErrs {
+			field := fieldErr.Field()
+
+			if field == FieldAge && len(rowErrors) > 0 {
+				continue // the age could not be read, which is already reported
+			}
+
+			rowErrors = append(rowErrors, RowError{
+				Row:     line,
+				Column:  cols.header[field],
+				Field:   field,
+				Message: response.ValidationErrors(validator.ValidationErrors{fieldErr})[0].Message, // same wording as the endpoints
+			})
+		}
+	}
+
+	return student, rowErrors
+}
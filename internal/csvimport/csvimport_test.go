@@ -0,0 +1,112 @@
+package csvimport_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/csvimport"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/memory"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/validation"
+)
+
+const roster = "\ufeffFull Name,E-Mail,Age,Homeroom\n" +
+	"Alice,alice@example.com,20,A\n" +
+	"Bob,,21,B\n" +
+	"Carol,carol@example.com,twenty,C\n" +
+	"Dave,dave@example.com,22\n"
+
+var mapping = map[string]string{"full name": "name", "E-Mail": "email"}
+
+var validate, _ = validation.New(config.Validation{NameMinLength: 1, MaxAge: 150})
+
+// rowsWithErrors returns the rows of the report errors.
+func rowsWithErrors(report csvimport.Report) []int {
+	var rows []int
+
+	for _, rowErr := range report.Errors {
+		rows = append(rows, rowErr.Row)
+	}
+
+	return rows
+}
+
+func TestImport(t *testing.T) {
+	s := memory.New()
+
+	report, err := csvimport.Import(t.Context(), s, strings.NewReader(roster), csvimport.Options{Mapping: mapping, Validate: validate})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if report.Rows != 4 || report.Valid != 2 || report.Imported != 2 {
+		t.Errorf("got %d rows, %d valid, %d imported, want 4, 2 and 2", report.Rows, report.Valid, report.Imported)
+	}
+
+	if got := rowsWithErrors(report); !slices.Equal(got, []int{3, 4}) {
+		t.Errorf("got errors on rows %v, want rows 3 and 4", got)
+	}
+
+	if rowErr := report.Errors[0]; rowErr.Column != "E-Mail" || rowErr.Field != "email" {
+		t.Errorf("error of row 3 is %+v, want it on the E-Mail column", rowErr)
+	}
+
+	if !slices.Equal(report.IgnoredColumns, []string{"Homeroom"}) {
+		t.Errorf("ignored columns are %v, want [Homeroom]", report.IgnoredColumns)
+	}
+
+	students, total, err := s.GetStudents(t.Context(), types.StudentFilter{})
+	if err != nil {
+		t.Fatalf("GetStudents: %v", err)
+	}
+
+	if total != 2 || students[0].Name != "Alice" || students[1].Name != "Dave" || students[1].Age != 22 {
+		t.Errorf("imported students are %+v", students)
+	}
+
+	if report.Created[1] != (csvimport.CreatedRow{Row: 5, ID: students[1].Id}) {
+		t.Errorf("second created row is %+v, want row 5 with ID %d", report.Created[1], students[1].Id)
+	}
+}
+
+func TestImportDryRunAndAtomic(t *testing.T) {
+	for _, opts := range []csvimport.Options{{Mapping: mapping, DryRun: true, Validate: validate}, {Mapping: mapping, Atomic: true, Validate: validate}} {
+		s := memory.New()
+
+		report, err := csvimport.Import(t.Context(), s, strings.NewReader(roster), opts)
+		if err != nil {
+			t.Fatalf("Import(%+v): %v", opts, err)
+		}
+
+		if report.Valid != 2 || report.Imported != 0 || len(report.Errors) != 2 {
+			t.Errorf("Import(%+v): got %d valid, %d imported and %d errors, want 2, 0 and 2", opts, report.Valid, report.Imported, len(report.Errors))
+		}
+
+		if _, total, _ := s.GetStudents(t.Context(), types.StudentFilter{}); total != 0 {
+			t.Errorf("Import(%+v) wrote %d students", opts, total)
+		}
+	}
+}
+
+func TestImportInvalidCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		mapping map[string]string
+	}{
+		{"empty", "", nil},
+		{"missing column", "name,email\nAlice,alice@example.com\n", nil},
+		{"duplicate column", "name,Full Name,email,age\n", map[string]string{"Full Name": "name"}},
+		{"unknown field", "name,email,age\n", map[string]string{"Homeroom": "room"}},
+	}
+
+	for _, tt := range tests {
+		_, err := csvimport.Import(t.Context(), memory.New(), strings.NewReader(tt.csv), csvimport.Options{Mapping: tt.mapping, Validate: validate})
+		if !errors.Is(err, csvimport.ErrInvalidCSV) {
+			t.Errorf("%s: got %v, want ErrInvalidCSV", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+// Package dedupe finds students that were likely entered more than once, by fuzzy matching of their names and
+// emails, for the duplicates report.
+package dedupe
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultThreshold is the score from which a pair of students is reported when the caller does not pick one.
+const DefaultThreshold = 0.85
+
+// Reasons a pair of students is reported, listed in DuplicateMatch.Reasons.
+const (
+	ReasonSameEmail    = "same_email"    // the same address, compared case-insensitively
+	ReasonSimilarEmail = "similar_email" // the same address without dots and +tags in the local part, or one typo apart
+	ReasonSimilarName  = "similar_name"  // names at least as similar as the threshold
+)
+
+// Scores of the email matches. Names alone score at most nameWeight, so that pairs sharing an email rank above
+// namesakes, who are often different people.
+const (
+	sameEmailScore    = 1.0
+	aliasEmailScore   = 0.95
+	typoEmailScore    = 0.9
+	nameWeight        = 0.9
+	minTypoLocalBytes = 5 // shorter local parts are too often one letter apart by chance
+)
+
+// Blocks with more than maxBlockSize candidates, such as the block of every "J S" student, are not compared
+// pairwise: their candidates are sorted by the key of the block and each is compared with its blockWindow next
+// ones only, so that the comparisons grow linearly with the roster.
+const (
+	maxBlockSize = 50
+	blockWindow  = 10
+)
+
+// candidate is a student along with the normalized forms its matching works on.
+type candidate struct {
+	student types.Student
+	key     string // storage.EmailKey of the email
+	local   string // local part of the email without dots and +tag
+	domain  string
+	name    string // lowercased name without accents or punctuation, with its words sorted
+}
+
+func newCandidate(student types.Student) candidate {
+	c := candidate{student: student, key: storage.EmailKey(student.Email), name: normalizeName(student.Name)}
+
+	local, domain, _ := strings.Cut(c.key, "@")
+	local, _, _ = strings.Cut(local, "+")
+
+	c.local = strings.ReplaceAll(local, ".", "")
+	c.domain = domain
+
+	return c
+}
+
+// normalizeName lowercases a name, strips its accents and punctuation and sorts its words, so that "Zoë O'Brien"
+// and "OBrien, Zoe" compare equal.
+func normalizeName(name string) string {
+	var b strings.Builder
+
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r), r == '\'', r == '’', r == '.':
+			// accents and punctuation within words are dropped
+		case unicode.IsLetter(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	slices.Sort(words)
+
+	return strings.Join(words, " ")
+}
+
+// blocks returns the keys of the blocks a candidate is compared within: only candidates sharing a block are
+// compared, which keeps the report from comparing every pair of a large roster.
+func (c candidate) blocks() []string {
+	var initials []byte
+
+	for _, word := range strings.Fields(c.name) {
+		initials = append(initials, word[0])
+	}
+
+	local := c.local
+	if len(local) > 2 {
+		local = local[:2]
+	}
+
+	return []string{"email:" + c.local + "@" + c.domain, "domain:" + c.domain + ":" + local, "name:" + string(initials)}
+}
+
+// sortKey returns what the candidates of an oversized block are sorted by, so that the neighbours a candidate is
+// compared with are the closest ones on what the block groups them by.
+func (c candidate) sortKey(block string) string {
+	if strings.HasPrefix(block, "name:") {
+		return c.name
+	}
+
+	return c.local
+}
+
+// Find returns the groups of students that match each other with at least the given score, between 0 and 1.
+// Students are linked into a group by a chain of matches; groups come best match first.
+func Find(students []types.Student, threshold float64) []types.DuplicateGroup {
+	groups, _ := find(students, threshold)
+
+	return groups
+}
+
+// find is Find, also returning the number of pairs it compared.
+func find(students []types.Student, threshold float64) ([]types.DuplicateGroup, int) {
+	candidates := make([]candidate, len(students))
+	blocks := map[string][]int{}
+
+	for i, student := range students {
+		candidates[i] = newCandidate(student)
+
+		for _, block := range candidates[i].blocks() {
+			blocks[block] = append(blocks[block], i)
+		}
+	}
+
+	type pair struct{ a, b int }
+
+	compared := map[pair]bool{}
+	groups := newUnionFind(len(students))
+
+	var matches []types.DuplicateMatch
+	var matchPairs []pair
+
+	for block, members := range blocks {
+		window := len(members)
+
+		if len(members) > maxBlockSize {
+			slices.SortFunc(members, func(a, b int) int {
+				return cmp.Or(cmp.Compare(candidates[a].sortKey(block), candidates[b].sortKey(block)), cmp.Compare(a, b))
+			})
+
+			window = blockWindow
+		}
+
+		for x, a := range members {
+			for _, b := range members[x+1 : min(len(members), x+1+window)] {
+				p := pair{min(a, b), max(a, b)}
+				if compared[p] {
+					continue
+				}
+
+				compared[p] = true
+
+				match, ok := compare(candidates[p.a], candidates[p.b], threshold)
+				if !ok {
+					continue
+				}
+
+				groups.union(p.a, p.b)
+				matches = append(matches, match)
+				matchPairs = append(matchPairs, p)
+			}
+		}
+	}
+
+	byRoot := map[int]*types.DuplicateGroup{}
+
+	for i, match := range matches {
+		root := groups.find(matchPairs[i].a)
+
+		group, ok := byRoot[root]
+		if !ok {
+			group = &types.DuplicateGroup{}
+			byRoot[root] = group
+		}
+
+		group.Matches = append(group.Matches, match)
+	}
+
+	for i, c := range candidates {
+		if group, ok := byRoot[groups.find(i)]; ok {
+			group.Students = append(group.Students, c.student)
+		}
+	}
+
+	result := make([]types.DuplicateGroup, 0, len(byRoot))
+
+	for _, group := range byRoot {
+		slices.SortFunc(group.Students, func(a, b types.Student) int { return cmp.Compare(a.Id, b.Id) })
+		slices.SortFunc(group.Matches, compareMatches)
+
+		result = append(result, *group)
+	}
+
+	slices.SortFunc(result, func(a, b types.DuplicateGroup) int {
+		if c := compareMatches(a.Matches[0], b.Matches[0]); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Students[0].Id, b.Students[0].Id)
+	})
+
+	return result, len(compared)
+}
+
+// compareMatches orders matches best first, then by the IDs of their students.
+func compareMatches(a, b types.DuplicateMatch) int {
+	if c := cmp.Compare(b.Score, a.Score); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(a.IDs[0], b.IDs[0]); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.IDs[1], b.IDs[1])
+}
+
+// compare scores a pair of candidates and reports whether they match with at least the threshold.
+func compare(a, b candidate, threshold float64) (types.DuplicateMatch, bool) {
+	var score float64
+
+	reasons := []string{}
+
+	switch {
+	case a.key == b.key:
+		score = sameEmailScore
+		reasons = append(reasons, ReasonSameEmail)
+	case a.domain == b.domain && a.local == b.local:
+		score = aliasEmailScore
+		reasons = append(reasons, ReasonSimilarEmail)
+	case a.domain == b.domain && min(len(a.local), len(b.local)) >= minTypoLocalBytes && editDistance(a.local, b.local) == 1:
+		score = typoEmailScore
+		reasons = append(reasons, ReasonSimilarEmail)
+	}
+
+	if similarity := jaroWinkler(a.name, b.name); similarity >= threshold {
+		score = max(score, similarity*nameWeight)
+		reasons = append(reasons, ReasonSimilarName)
+	}
+
+	if score < threshold || score == 0 {
+		return types.DuplicateMatch{}, false
+	}
+
+	ids := [2]int64{a.student.Id, b.student.Id}
+	if ids[0] > ids[1] {
+		ids[0], ids[1] = ids[1], ids[0]
+	}
+
+	return types.DuplicateMatch{IDs: ids, Score: float64(int(score*1000+0.5)) / 1000, Reasons: reasons}, true
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 for nothing in common to 1 for equal strings.
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+
+	if len(s) == 0 || len(t) == 0 {
+		if len(s) == len(t) {
+			return 1
+		}
+
+		return 0
+	}
+
+	window := max(len(s), len(t))/2 - 1
+	window = max(window, 0)
+
+	sMatched := make([]bool, len(s))
+	tMatched := make([]bool, len(t))
+	matches := 0
+
+	for i := range s {
+		for j := max(0, i-window); j < min(len(t), i+window+1); j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+
+				break
+			}
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+
+		for !tMatched[j] {
+			j++
+		}
+
+		if s[i] != t[j] {
+			transpositions++
+		}
+
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// editDistance returns the number of single byte insertions, deletions, substitutions and swaps of adjacent bytes
+// turning a into b (the optimal string alignment distance), swaps being the most common typo.
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return rows[len(a)][len(b)]
+}
+
+// unionFind tracks which candidates are linked into the same group.
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	parents := make(unionFind, n)
+
+	for i := range parents {
+		parents[i] = i
+	}
+
+	return parents
+}
+
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]] // halve the path on the way up
+		i = u[i]
+	}
+
+	return i
+}
+
+func (u unionFind) union(a, b int) {
+	u[u.find(a)] = u.find(b)
+}
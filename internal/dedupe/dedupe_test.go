@@ -0,0 +1,115 @@
+package dedupe_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/dedupe"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+func TestFind(t *testing.T) {
+	students := []types.Student{
+		{Id: 1, Name: "Ansh Singh", Email: "ansh@example.com"},
+		{Id: 2, Name: "Singh, Ansh", Email: "ANSH@example.com"},     // same email, words swapped
+		{Id: 3, Name: "Ansh S.", Email: "an.sh+school@example.com"}, // email alias of 1
+		{Id: 4, Name: "Zoë O'Brien", Email: "zoe.obrien@school.edu"},
+		{Id: 5, Name: "Zoe OBrien", Email: "zoe.obrein@school.edu"},       // typo in the email, accents dropped
+		{Id: 6, Name: "Carol Danvers", Email: "carol@example.com"},        // unrelated
+		{Id: 7, Name: "Bob", Email: "bob@example.com"},                    // unrelated
+		{Id: 8, Name: "Robert Tables", Email: "bobby.tables@example.org"}, // unrelated
+	}
+
+	groups := dedupe.Find(students, dedupe.DefaultThreshold)
+
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(groups), groups)
+	}
+
+	tests := []struct {
+		ids     []int64
+		best    [2]int64
+		score   float64
+		reasons []string
+	}{
+		{[]int64{1, 2, 3}, [2]int64{1, 2}, 1, []string{dedupe.ReasonSameEmail, dedupe.ReasonSimilarName}},
+		{[]int64{4, 5}, [2]int64{4, 5}, 0.9, []string{dedupe.ReasonSimilarEmail, dedupe.ReasonSimilarName}},
+	}
+
+	for i, test := range tests {
+		group := groups[i]
+
+		var ids []int64
+		for _, student := range group.Students {
+			ids = append(ids, student.Id)
+		}
+
+		if !slices.Equal(ids, test.ids) {
+			t.Errorf("group %d has students %v, want %v", i, ids, test.ids)
+		}
+
+		best := group.Matches[0]
+
+		if best.IDs != test.best || best.Score != test.score || !slices.Equal(best.Reasons, test.reasons) {
+			t.Errorf("group %d has best match %+v, want %v scoring %v for %v", i, best, test.best, test.score, test.reasons)
+		}
+	}
+}
+
+func TestFindThreshold(t *testing.T) {
+	students := []types.Student{
+		{Id: 1, Name: "Jonathan Smith", Email: "jsmith@example.com"},
+		{Id: 2, Name: "Jonathon Smith", Email: "jonathon@example.com"},
+	}
+
+	if groups := dedupe.Find(students, 0.99); len(groups) != 0 {
+		t.Errorf("got %+v with a strict threshold, want no groups", groups)
+	}
+
+	groups := dedupe.Find(students, 0.8)
+
+	if len(groups) != 1 || !slices.Equal(groups[0].Matches[0].Reasons, []string{dedupe.ReasonSimilarName}) {
+		t.Errorf("got %+v with a loose threshold, want the similar names", groups)
+	}
+}
+
+func TestFindBoundsComparisons(t *testing.T) {
+	// letters returns a distinct word of three letters for every i below 26³
+	letters := func(i int) string {
+		return string([]byte{byte('a' + i/676%26), byte('a' + i/26%26), byte('a' + i%26)})
+	}
+
+	// a roster of students who all share the initials J S and the email domain, so that they all fall in the
+	// same name and domain blocks
+	const n = 3000
+
+	students := make([]types.Student, 0, n+2)
+
+	for i := range n {
+		students = append(students, types.Student{Id: int64(i + 1), Name: "Jo" + letters(i) + " S" + letters(i*7), Email: "student" + letters(i) + "@school.edu"})
+	}
+
+	students = append(students,
+		types.Student{Id: n + 1, Name: "Jane Smith", Email: "jane.smith@school.edu"},
+		types.Student{Id: n + 2, Name: "Jane Smyth", Email: "jane.smyth@school.edu"}, // the same student, mistyped
+	)
+
+	groups, comparisons := dedupe.FindCounting(students, dedupe.DefaultThreshold)
+
+	// every student is in three blocks and compared with at most BlockWindow others in each of them
+	if limit := 3 * len(students) * dedupe.BlockWindow; comparisons > limit {
+		t.Fatalf("compared %d pairs of %d students sharing their initials, want at most %d", comparisons, len(students), limit)
+	}
+
+	found := false
+
+	for _, group := range groups {
+		if len(group.Students) == 2 && group.Students[0].Id == n+1 && group.Students[1].Id == n+2 {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatalf("the mistyped student was not found among %d groups", len(groups))
+	}
+}
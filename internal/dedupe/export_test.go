@@ -0,0 +1,7 @@
+package dedupe
+
+// FindCounting exposes find to the tests, along with the number of pairs it compared.
+var FindCounting = find
+
+// BlockWindow is the number of neighbours a candidate of an oversized block is compared with.
+const BlockWindow = blockWindow
@@ -0,0 +1,138 @@
+// Package export writes students as downloadable files, one student at a time, so that exports can be streamed
+// straight from the storage to the client.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// Writer writes students in an export format. Nothing is written to the underlying writer before the first call
+// to Write or Close, and the file is only complete once Close has been called.
+type Writer interface {
+	Write(student types.Student) error
+	Close() error
+}
+
+// Format is a file format students can be exported in.
+type Format struct {
+	Name        string // value of the format query parameter
+	ContentType string
+	Extension   string // file name extension, without the dot
+	NewWriter   func(w io.Writer) Writer
+}
+
+// Formats lists every supported export format, the first one being the default.
+var Formats = []Format{
+	{Name: "csv", ContentType: "text/csv; charset=utf-8", Extension: "csv", NewWriter: newCSVWriter},
+	{Name: "ndjson", ContentType: "application/x-ndjson", Extension: "ndjson", NewWriter: newNDJSONWriter},
+	{Name: "xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: "xlsx", NewWriter: newXLSXWriter},
+}
+
+// Lookup returns the format with the given name.
+func Lookup(name string) (Format, bool) {
+	for _, format := range Formats {
+		if format.Name == name {
+			return format, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// columns are the headers of the tabular formats, in the order of row.
+var columns = []string{"id", "name", "email", "age", "version", "deleted_at"}
+
+// row returns the fields of a student as the cells of a tabular export.
+func row(student types.Student) []string {
+	deletedAt := ""
+	if student.DeletedAt != nil {
+		deletedAt = student.DeletedAt.UTC().Format(time.RFC3339)
+	}
+
+	return []string{
+		strconv.FormatInt(student.Id, 10),
+		student.Name,
+		student.Email,
+		strconv.Itoa(student.Age),
+		strconv.FormatInt(student.Version, 10),
+		deletedAt,
+	}
+}
+
+// csvWriter writes students as CSV with a header row.
+type csvWriter struct {
+	w       *csv.Writer
+	started bool
+}
+
+func newCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// start writes the header row on first use.
+func (c *csvWriter) start() error {
+	if c.started {
+		return nil
+	}
+
+	c.started = true
+
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) Write(student types.Student) error {
+	if err := c.start(); err != nil {
+		return err
+	}
+
+	cells := row(student)
+	for i, cell := range cells {
+		cells[i] = escapeFormula(cell)
+	}
+
+	return c.w.Write(cells) // csv.Writer buffers a few KB at a time before passing them on
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+// escapeFormula keeps spreadsheets from running cells that look like formulas, a classic CSV injection,
+// by prefixing them with a quote.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+
+	return cell
+}
+
+// ndjsonWriter writes students as newline-delimited JSON, one object per line.
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) Writer {
+	return &ndjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) Write(student types.Student) error {
+	return n.encoder.Encode(student) // Encode ends every value with a newline
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
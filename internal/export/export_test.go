@@ -0,0 +1,132 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/export"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+var deletedAt = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+var students = []types.Student{
+	{Id: 1, Name: "Alice", Email: "alice@example.com", Age: 20, Version: 1},
+	{Id: 2, Name: "=HYPERLINK(\"x\") & <b>", Email: "bob@example.com", Age: 21, Version: 3, DeletedAt: &deletedAt},
+}
+
+// write exports the students in the named format.
+func write(t *testing.T, name string, students []types.Student) []byte {
+	t.Helper()
+
+	format, ok := export.Lookup(name)
+	if !ok {
+		t.Fatalf("format %s is not supported", name)
+	}
+
+	var buf bytes.Buffer
+
+	w := format.NewWriter(&buf)
+
+	for _, student := range students {
+		if err := w.Write(student); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	got := string(write(t, "csv", students))
+	want := "id,name,email,age,version,deleted_at\n" +
+		"1,Alice,alice@example.com,20,1,\n" +
+		"2,\"'=HYPERLINK(\"\"x\"\") & <b>\",bob@example.com,21,3,2025-03-01T12:00:00Z\n"
+
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if got := string(write(t, "csv", nil)); got != "id,name,email,age,version,deleted_at\n" {
+		t.Errorf("empty export is %q, want only the header", got)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(string(write(t, "ndjson", students)), "\n"), "\n")
+	if len(lines) != len(students) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(students))
+	}
+
+	var student types.Student
+
+	if err := json.Unmarshal([]byte(lines[1]), &student); err != nil {
+		t.Fatalf("line 2 is not a student: %v", err)
+	}
+
+	if student.Name != students[1].Name || !student.DeletedAt.Equal(deletedAt) {
+		t.Errorf("line 2 decodes to %+v", student)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	file := write(t, "xlsx", students)
+
+	archive, err := zip.NewReader(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("export is not a zip archive: %v", err)
+	}
+
+	parts := map[string]*zip.File{}
+	for _, f := range archive.File {
+		parts[f.Name] = f
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if parts[name] == nil {
+			t.Errorf("part %s is missing", name)
+		}
+	}
+
+	sheet, err := parts["xl/worksheets/sheet1.xml"].Open()
+	if err != nil {
+		t.Fatalf("opening the sheet: %v", err)
+	}
+
+	defer sheet.Close()
+
+	var worksheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+
+	content, _ := io.ReadAll(sheet)
+
+	if err := xml.Unmarshal(content, &worksheet); err != nil {
+		t.Fatalf("sheet is not valid XML: %v", err)
+	}
+
+	if len(worksheet.Rows) != 3 {
+		t.Fatalf("sheet has %d rows, want a header and 2 students", len(worksheet.Rows))
+	}
+
+	cells := worksheet.Rows[2].Cells
+	if cells[0].Value != "2" || cells[1].Ref != "B3" || cells[1].Inline != students[1].Name || cells[5].Inline != "2025-03-01T12:00:00Z" {
+		t.Errorf("second student row is %+v", cells)
+	}
+}
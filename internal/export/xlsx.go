@@ -0,0 +1,126 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// The parts of a minimal XLSX workbook with a single sheet. The sheet itself is streamed by xlsxWriter.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Students" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// numericColumns are the columns of row written as numbers rather than text.
+var numericColumns = map[int]bool{0: true, 3: true, 4: true}
+
+// xlsxWriter writes students as an XLSX workbook. The zip archive is written as it goes, so the sheet is streamed
+// row by row; strings are stored inline in the cells, which spares a shared string table built up in memory.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+func newXLSXWriter(w io.Writer) Writer {
+	return &xlsxWriter{zip: zip.NewWriter(w)}
+}
+
+// start writes the workbook parts and opens the sheet on first use.
+func (x *xlsxWriter) start() error {
+	if x.sheet != nil {
+		return nil
+	}
+
+	for _, part := range xlsxParts {
+		w, err := x.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+
+	w, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	x.sheet = bufio.NewWriter(w)
+
+	x.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	return x.writeRow(columns, nil)
+}
+
+// writeRow writes a row of cells to the sheet, as numbers for the columns in numeric.
+func (x *xlsxWriter) writeRow(cells []string, numeric map[int]bool) error {
+	x.rows++
+
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows)
+
+	for i, cell := range cells {
+		ref := fmt.Sprintf("%c%d", 'A'+i, x.rows) // there are fewer than 26 columns
+
+		switch {
+		case cell == "":
+			continue // empty cells are left out
+		case numeric[i]:
+			fmt.Fprintf(x.sheet, `<c r="%s"><v>%s</v></c>`, ref, cell)
+		default:
+			fmt.Fprintf(x.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+
+			if err := xml.EscapeText(x.sheet, []byte(cell)); err != nil {
+				return err
+			}
+
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+
+	_, err := x.sheet.WriteString(`</row>`)
+
+	return err
+}
+
+func (x *xlsxWriter) Write(student types.Student) error {
+	if err := x.start(); err != nil {
+		return err
+	}
+
+	return x.writeRow(row(student), numericColumns)
+}
+
+func (x *xlsxWriter) Close() error {
+	if err := x.start(); err != nil {
+		return err
+	}
+
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+
+	return x.zip.Close()
+}
@@ -0,0 +1,73 @@
+package student
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// History(storage storage.Storage) returns a handler function that lists the audit log entries of one student,
+// newest first, with limit/offset or page/page_size pagination. The history of purged students is kept.
+
+func History(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.PathValue("id") // get the ID from the URL path parameters
+
+		slog.Info("Retrieving history of student with ID: ", slog.String("id", id)) // log the ID whose history is retrieved
+
+		intTd, err := strconv.ParseInt(id, 10, 64) // convert the ID from string to int64
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
+		}
+
+		limit, offset, err := parsePage(r.URL.Query())
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if the pagination parameters are invalid, respond with a 400 Bad Request status code
+
+			return
+		}
+
+		writeAuditPage(w, r, storage, types.AuditFilter{StudentID: intTd, Limit: limit, Offset: offset})
+	}
+}
+
+// Audit(storage storage.Storage) returns a handler function that lists the audit log of all students, newest first.
+// It can be filtered by student_id, actor, action, request_id and a since/until time range.
+
+func Audit(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		slog.Info("Retrieving audit log") // log the action of retrieving the audit log
+
+		filter, err := parseAuditFilter(r.URL.Query()) // read filter and pagination options from the query string
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if the query parameters are invalid, respond with a 400 Bad Request status code
+
+			return
+		}
+
+		writeAuditPage(w, r, storage, filter)
+	}
+}
+
+// writeAuditPage writes the page of audit entries matching the filter.
+func writeAuditPage(w http.ResponseWriter, r *http.Request, storage storage.Storage, filter types.AuditFilter) {
+	entries, total, err := storage.GetAuditEntries(r.Context(), filter) // call the GetAuditEntries method on the storage interface to retrieve one page of entries
+	if err != nil {
+		slog.Error("Error retrieving audit log", slog.Any("error", err)) // log the error if there is an issue retrieving the entries
+
+		response.WriteError(w, r, http.StatusInternalServerError, err) // if there is an error, respond with a 500 Internal Server Error status code
+
+		return
+	}
+
+	redactAuditEntries(r.Context(), entries) // the snapshots of the students hold their emails
+
+	response.Write(w, r, http.StatusOK, types.AuditPage{Entries: entries, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
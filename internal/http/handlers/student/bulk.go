@@ -0,0 +1,304 @@
+package student
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/http/middleware"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxBulkOperations is the largest number of operations a single bulk request may hold.
+const maxBulkOperations = 1000
+
+// maxBulkBytes is the largest body accepted by POST /api/students/bulk, room for maxBulkOperations of about 1 KiB.
+const maxBulkBytes = 1 << 20
+
+// Media types accepted by POST /api/students/bulk besides application/json, for newline-delimited JSON bodies.
+var ndjsonMediaTypes = []string{"application/x-ndjson", "application/ndjson"}
+
+// bulkOperation is one operation of a bulk request: the op and the fields of the student it writes.
+type bulkOperation struct {
+	Op string `json:"op"` // create, update or delete, defaults to create
+	types.Student
+}
+
+// bulkResult is the outcome of one operation of a bulk request.
+type bulkResult struct {
+	Index   int    `json:"index"`  // position of the operation in the request
+	Op      string `json:"op"`     // operation that was requested
+	Status  int    `json:"status"` // HTTP status code the operation would have had as a single request
+	ID      int64  `json:"id,omitempty"`
+	Version int64  `json:"version,omitempty"`
+
+	// Code, Error, Errors and ConflictingID describe failed operations like the problem of a single request would.
+	Code          string                `json:"code,omitempty"`
+	Error         string                `json:"error,omitempty"`
+	Errors        []response.FieldError `json:"errors,omitempty"`
+	ConflictingID int64                 `json:"conflicting_id,omitempty"`
+}
+
+// fail records the failure of the operation, with the invalid fields when it fails validation.
+func (b *bulkResult) fail(status int, err error) {
+	b.Status, b.Code, b.Error = status, response.StatusCode(status), err.Error()
+
+	var validateErrs validator.ValidationErrors
+	if errors.As(err, &validateErrs) {
+		problem := response.ValidationProblem(validateErrs)
+
+		b.Code, b.Error, b.Errors = problem.Code, problem.Detail, problem.Errors
+	}
+
+	var duplicate *storage.DuplicateEmailError
+	if errors.As(err, &duplicate) {
+		b.ConflictingID = duplicate.ID
+	}
+}
+
+// bulkResponse is the body of the response to a bulk request.
+type bulkResponse struct {
+	Atomic    bool         `json:"atomic"`
+	Succeeded int          `json:"succeeded"` // number of operations that were applied
+	Failed    int          `json:"failed"`    // number of operations that were not
+	Results   []bulkResult `json:"results"`   // one result per operation, in request order
+}
+
+// Bulk(storage storage.Storage, validate *validator.Validate) returns a handler function that creates, updates and deletes many students at once.
+// The body is a JSON array of operations, or one operation per line with an NDJSON content type. Each operation has
+// an op of create (the default), update or delete and the fields of the student; updates and deletes take an id and
+// optionally the version the student is expected to be at. Operations are validated like single requests and run in
+// one transaction. With atomic=true, the default, nothing is written unless every operation succeeds; with
+// atomic=false each operation succeeds or fails on its own. Deletes fail with 403 unless the caller has the
+// students:delete permission. The response holds the status of every operation and is 200 OK when all of them
+// succeeded, 207 Multi-Status otherwise.
+
+func Bulk(storage storage.Storage, validate *validator.Validate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		slog.Info("Applying bulk student operations")
+
+		atomic := true
+
+		if raw := r.URL.Query().Get("atomic"); raw != "" {
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				response.WriteError(w, r, http.StatusBadRequest, errors.New("atomic must be true or false"))
+
+				return // return early to avoid further processing
+			}
+
+			atomic = value
+		}
+
+		operations, err := decodeBulkOperations(http.MaxBytesReader(w, r.Body, maxBulkBytes), r.Header.Get("Content-Type"))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+
+			if errors.As(err, &tooLarge) {
+				response.WriteError(w, r, http.StatusRequestEntityTooLarge, err)
+
+				return
+			}
+
+			response.WriteError(w, r, http.StatusBadRequest, err) // if the body cannot be read as operations, respond with a 400 Bad Request status code
+
+			return
+		}
+
+		results := make([]bulkResult, len(operations))
+		batch := make([]types.BatchOperation, 0, len(operations))
+		indexes := make([]int, 0, len(operations)) // position in the request of every operation sent to the storage
+
+		for i, operation := range operations {
+			results[i] = bulkResult{Index: i, Op: operation.Op, ID: operation.Id}
+
+			if err := validateBulkOperation(validate, operation); err != nil {
+				results[i].fail(http.StatusBadRequest, err) // invalid operations never reach the storage
+
+				continue
+			}
+
+			// the route only requires students:write, deletions also require students:delete
+			if operation.Op == types.BatchDelete && !auth.Allowed(r.Context(), auth.PermissionDelete) {
+				middleware.LogDenial(r, auth.PermissionDelete)
+
+				results[i].fail(http.StatusForbidden, fmt.Errorf("the %s permission is required", auth.PermissionDelete))
+
+				continue
+			}
+
+			batch = append(batch, types.BatchOperation{Op: operation.Op, Student: operation.Student})
+			indexes = append(indexes, i)
+		}
+
+		if atomic && len(batch) < len(operations) {
+			abortBulk(results) // nothing is written when an atomic batch has invalid operations
+
+			writeBulkResponse(w, r, atomic, results)
+
+			return
+		}
+
+		applied, err := storage.ApplyBatch(r.Context(), batch, atomic) // run every valid operation in one transaction
+		if err != nil {
+			slog.Error("Error applying bulk student operations", slog.Any("error", err))
+
+			response.WriteError(w, r, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		for j, result := range applied {
+			item := &results[indexes[j]]
+
+			if result.Err != nil {
+				item.fail(storageErrorStatus(result.Err), result.Err)
+
+				continue
+			}
+
+			item.ID, item.Version, item.Status = result.ID, result.Version, http.StatusOK
+
+			if item.Op == types.BatchCreate {
+				item.Status = http.StatusCreated
+			}
+		}
+
+		writeBulkResponse(w, r, atomic, results)
+	}
+}
+
+// decodeBulkOperations reads the operations of a bulk request from a JSON array or an NDJSON body, in the format
+// of its Content-Type. Operations are read one at a time, so that it stops at the first one over maxBulkOperations.
+// Operations without an op are creates.
+func decodeBulkOperations(body io.Reader, contentType string) ([]bulkOperation, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	var operations []bulkOperation
+
+	decoder := json.NewDecoder(body)
+
+	// add appends an operation, and fails once there are too many of them
+	add := func(operation bulkOperation) error {
+		if len(operations) == maxBulkOperations {
+			return fmt.Errorf("request has more than %d operations", maxBulkOperations)
+		}
+
+		operations = append(operations, operation)
+
+		return nil
+	}
+
+	if slices.Contains(ndjsonMediaTypes, mediaType) {
+		for { // every operation is a JSON object on a line of its own
+			var operation bulkOperation
+
+			if err := decoder.Decode(&operation); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", len(operations), err)
+			}
+
+			if err := add(operation); err != nil {
+				return nil, err
+			}
+		}
+	} else { // any other content type is read as JSON, like the other endpoints do
+		if delim, err := decoder.Token(); errors.Is(err, io.EOF) {
+			return nil, errors.New("request body is empty")
+		} else if err != nil {
+			return nil, fmt.Errorf("body must be a JSON array of operations: %w", err)
+		} else if delim != json.Delim('[') {
+			return nil, errors.New("body must be a JSON array of operations")
+		}
+
+		for decoder.More() { // the elements of the array are read one at a time
+			var operation bulkOperation
+
+			if err := decoder.Decode(&operation); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", len(operations), err)
+			}
+
+			if err := add(operation); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := decoder.Token(); err != nil { // the closing bracket
+			return nil, fmt.Errorf("body must be a JSON array of operations: %w", err)
+		}
+	}
+
+	if len(operations) == 0 {
+		return nil, errors.New("request has no operations")
+	}
+
+	for i := range operations {
+		if operations[i].Op == "" {
+			operations[i].Op = types.BatchCreate
+		}
+	}
+
+	return operations, nil
+}
+
+// validateBulkOperation checks an operation with the rules of the single student endpoints.
+func validateBulkOperation(validate *validator.Validate, operation bulkOperation) error {
+	switch operation.Op {
+	case types.BatchCreate:
+	case types.BatchUpdate, types.BatchDelete:
+		if operation.Id <= 0 {
+			return fmt.Errorf("%s requires the id of the student", operation.Op)
+		}
+
+		if operation.Op == types.BatchDelete {
+			return nil // deletes have no fields to validate
+		}
+	default:
+		return fmt.Errorf("unknown op %q, use %s, %s or %s", operation.Op, types.BatchCreate, types.BatchUpdate, types.BatchDelete)
+	}
+
+	return validate.Struct(operation.Student)
+}
+
+// abortBulk marks the operations of an atomic bulk request that are not already failed as aborted.
+func abortBulk(results []bulkResult) {
+	for i := range results {
+		if results[i].Error == "" {
+			results[i].fail(storageErrorStatus(storage.ErrBatchAborted), storage.ErrBatchAborted)
+		}
+	}
+}
+
+// writeBulkResponse writes the results of a bulk request, with 207 Multi-Status when some operations failed.
+func writeBulkResponse(w http.ResponseWriter, r *http.Request, atomic bool, results []bulkResult) {
+	body := bulkResponse{Atomic: atomic, Results: results}
+
+	for _, result := range results {
+		if result.Error == "" {
+			body.Succeeded++
+		} else {
+			body.Failed++
+		}
+	}
+
+	status := http.StatusOK
+	if body.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	slog.Info("Bulk student operations applied", slog.Int("succeeded", body.Succeeded), slog.Int("failed", body.Failed), slog.Bool("atomic", atomic))
+
+	response.Write(w, r, status, body)
+}
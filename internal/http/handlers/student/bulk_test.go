@@ -0,0 +1,88 @@
+package student
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// createOperations returns n create operations as JSON objects, each for a student of its own.
+func createOperations(n int) []string {
+	operations := make([]string, n)
+
+	for i := range operations {
+		operations[i] = fmt.Sprintf(`{"name": "Student", "email": "student%d@example.com", "age": 20}`, i)
+	}
+
+	return operations
+}
+
+func TestBulkLimits(t *testing.T) {
+	const ndjson = "application/x-ndjson"
+
+	huge := `{"name": "` + strings.Repeat("a", maxBulkBytes) + `", "email": "huge@example.com", "age": 20}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		wantCode    string
+		wantDetail  string
+	}{
+		{"array at the limit", "application/json", "[" + strings.Join(createOperations(maxBulkOperations), ",") + "]", http.StatusOK, "", ""},
+		{"NDJSON at the limit", ndjson, strings.Join(createOperations(maxBulkOperations), "\n"), http.StatusOK, "", ""},
+		{"array over the limit", "application/json", "[" + strings.Join(createOperations(maxBulkOperations+1), ",") + "]", http.StatusBadRequest, response.CodeBadRequest, "more than 1000 operations"},
+		// the rest of the body is not read once there are too many operations, so the invalid line is never reached
+		{"NDJSON over the limit", ndjson, strings.Join(createOperations(maxBulkOperations+1), "\n") + "\nnot JSON", http.StatusBadRequest, response.CodeBadRequest, "more than 1000 operations"},
+		{"array over the size limit", "application/json", "[" + huge + "]", http.StatusRequestEntityTooLarge, response.CodePayloadTooLarge, ""},
+		{"NDJSON over the size limit", ndjson, huge, http.StatusRequestEntityTooLarge, response.CodePayloadTooLarge, ""},
+		{"empty body", "application/json", "", http.StatusBadRequest, response.CodeBadRequest, "request body is empty"},
+		{"empty array", "application/json", "[]", http.StatusBadRequest, response.CodeBadRequest, "request has no operations"},
+		{"object instead of an array", "application/json", createOperations(1)[0], http.StatusBadRequest, response.CodeBadRequest, "JSON array"},
+		{"unterminated array", "application/json", "[" + createOperations(1)[0], http.StatusBadRequest, response.CodeBadRequest, "unexpected end"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+
+			rec := serve(Bulk(store, newTestValidator(t)), http.MethodPost, "", map[string]string{"Content-Type": tt.contentType}, tt.body)
+
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %.200s", rec.Code, tt.want, rec.Body)
+			}
+
+			_, total, err := store.GetStudents(t.Context(), types.StudentFilter{Limit: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want == http.StatusOK {
+				if total != maxBulkOperations+1 {
+					t.Fatalf("got %d students, want the %d created and the existing one", total, maxBulkOperations)
+				}
+
+				return
+			}
+
+			if total != 1 {
+				t.Fatalf("a rejected request left %d students", total)
+			}
+
+			var problem response.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+
+			if problem.Code != tt.wantCode || !strings.Contains(problem.Detail, tt.wantDetail) {
+				t.Fatalf("got problem %+v, want the code %s and a detail about %q", problem, tt.wantCode, tt.wantDetail)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package student
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/cursor"
+)
+
+// listCursor is the signed payload behind the next_cursor and prev_cursor tokens of GET /api/students.
+// It pins the sort order and filters of the listing it was issued for, so a cursor cannot be replayed against another listing.
+type listCursor struct {
+	Sort     string          `json:"s"`
+	Desc     bool            `json:"d,omitempty"`
+	Filter   string          `json:"f,omitempty"`
+	ID       int64           `json:"id"`
+	Value    json.RawMessage `json:"v,omitempty"`
+	Backward bool            `json:"b,omitempty"`
+}
+
+// filterFingerprint summarises the filters of a listing so that cursors can be checked against them.
+func filterFingerprint(filter types.StudentFilter) string {
+	bound := func(age *int) string {
+		if age == nil {
+			return ""
+		}
+
+		return strconv.Itoa(*age)
+	}
+
+	asOf := ""
+	if !filter.AsOf.IsZero() {
+		asOf = filter.AsOf.UTC().Format(time.RFC3339Nano)
+	}
+
+	fingerprint, _ := json.Marshal([]string{filter.Name, filter.Email, bound(filter.MinAge), bound(filter.MaxAge), strconv.FormatBool(filter.Deleted), asOf})
+
+	return string(fingerprint)
+}
+
+// encodeCursor returns a cursor token pointing at the given student of a listing.
+func encodeCursor(codec *cursor.Codec, filter types.StudentFilter, student types.Student, backward bool) (string, error) {
+	token := listCursor{
+		Sort:     filter.Sort,
+		Desc:     filter.Desc,
+		Filter:   filterFingerprint(filter),
+		ID:       student.Id,
+		Backward: backward,
+	}
+
+	var value any
+
+	switch filter.Sort {
+	case types.SortByName:
+		value = student.Name
+	case types.SortByEmail:
+		value = student.Email
+	case types.SortByAge:
+		value = student.Age
+	}
+
+	if value != nil {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+
+		token.Value = raw
+	}
+
+	return codec.Encode(token)
+}
+
+// applyCursor decodes a cursor token and positions the filter at it.
+// The filter must already hold the sort order and filters of the request, which have to match the ones of the cursor.
+func applyCursor(codec *cursor.Codec, token string, filter *types.StudentFilter) error {
+	var payload listCursor
+
+	if err := codec.Decode(token, &payload); err != nil {
+		return err
+	}
+
+	if payload.Sort != filter.Sort || payload.Desc != filter.Desc {
+		return errors.New("cursor was issued for a different sort order")
+	}
+
+	if payload.Filter != filterFingerprint(*filter) {
+		return errors.New("cursor was issued for different filters")
+	}
+
+	position := &types.StudentCursor{ID: payload.ID, Backward: payload.Backward}
+
+	switch payload.Sort {
+	case types.SortByName, types.SortByEmail:
+		var value string
+		if err := json.Unmarshal(payload.Value, &value); err != nil {
+			return cursor.ErrInvalid
+		}
+
+		position.Value = value
+	case types.SortByAge:
+		var value int
+		if err := json.Unmarshal(payload.Value, &value); err != nil {
+			return cursor.ErrInvalid
+		}
+
+		position.Value = value
+	case "", types.SortByID:
+	default:
+		return fmt.Errorf("cursor has unsupported sort field %q", payload.Sort)
+	}
+
+	filter.Cursor = position
+	filter.Offset = 0
+
+	return nil
+}
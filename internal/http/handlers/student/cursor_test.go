@@ -0,0 +1,144 @@
+package student
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/memory"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/cursor"
+)
+
+// listPage requests a page of GET /api/students with the given query and returns it along with the IDs it lists.
+func listPage(t *testing.T, handler http.Handler, query url.Values) (types.StudentPage, []int64, int) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/students?"+query.Encode(), nil))
+
+	var page types.StudentPage
+
+	if rec.Code != http.StatusOK {
+		return page, nil, rec.Code
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decoding page: %v", err)
+	}
+
+	ids := make([]int64, len(page.Students))
+	for i, student := range page.Students {
+		ids[i] = student.Id
+	}
+
+	return page, ids, rec.Code
+}
+
+func TestCursorPagination(t *testing.T) {
+	store := memory.New()
+
+	// the ages repeat, so that pages break in the middle of students sharing a sort value
+	for i, age := range []int{20, 30, 20, 20, 30, 25, 20} {
+		if _, err := store.CreateStudent(t.Context(), fmt.Sprintf("Student %d", i+1), fmt.Sprintf("s%d@example.com", i+1), age); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	codec, err := cursor.New([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := GetList(store, codec)
+
+	tests := []struct {
+		sort string
+		want []int64
+	}{
+		{"age", []int64{1, 3, 4, 7, 6, 2, 5}},
+		{"-age", []int64{5, 2, 6, 7, 4, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			query := url.Values{"sort": {tt.sort}, "limit": {"2"}}
+
+			var pages []types.StudentPage
+			var forward []int64
+
+			page, ids, _ := listPage(t, handler, query)
+
+			for {
+				pages = append(pages, page)
+				forward = append(forward, ids...)
+
+				if page.NextCursor == "" {
+					break
+				}
+
+				query.Set("cursor", page.NextCursor)
+				page, ids, _ = listPage(t, handler, query)
+			}
+
+			if !slices.Equal(forward, tt.want) {
+				t.Fatalf("paging forward listed %v, want %v", forward, tt.want)
+			}
+
+			if pages[0].PrevCursor != "" {
+				t.Fatalf("the first page has a prev_cursor")
+			}
+
+			// walk back from the last page, which must give the same pages in reverse
+			for i := len(pages) - 1; i > 0; i-- {
+				query.Set("cursor", pages[i].PrevCursor)
+
+				previous, ids, _ := listPage(t, handler, query)
+
+				want := tt.want[(i-1)*2 : i*2]
+				if !slices.Equal(ids, want) {
+					t.Fatalf("paging back from page %d listed %v, want %v", i+1, ids, want)
+				}
+
+				if (previous.PrevCursor == "") != (i == 1) {
+					t.Fatalf("page %d reached backwards has prev_cursor %q", i, previous.PrevCursor)
+				}
+
+				if previous.NextCursor == "" {
+					t.Fatalf("page %d reached backwards has no next_cursor", i)
+				}
+			}
+		})
+	}
+
+	page, _, _ := listPage(t, handler, url.Values{"sort": {"age"}, "limit": {"2"}})
+
+	foreign, err := cursor.New([]byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, err := encodeCursor(foreign, types.StudentFilter{Sort: types.SortByAge}, types.Student{Id: 3, Age: 20}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := []url.Values{
+		{"sort": {"name"}, "cursor": {page.NextCursor}},                   // issued for another sort order
+		{"sort": {"-age"}, "cursor": {page.NextCursor}},                   // issued for the other direction
+		{"sort": {"age"}, "min_age": {"25"}, "cursor": {page.NextCursor}}, // issued for other filters
+		{"sort": {"age"}, "cursor": {page.NextCursor}, "offset": {"2"}},   // cursors cannot be combined with offsets
+		{"sort": {"age"}, "cursor": {forged}},                             // signed with another secret
+		{"sort": {"age"}, "cursor": {page.NextCursor + "x"}},              // tampered with
+	}
+
+	for _, query := range rejected {
+		if _, _, status := listPage(t, handler, query); status != http.StatusBadRequest {
+			t.Errorf("GET /api/students?%s returned %d, want 400", query.Encode(), status)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package student
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/redact"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// Diff(storage storage.Storage) returns a handler function that compares two versions of a student, given as the from
+// and to query parameters, and lists the fields that changed between them. Versions are read from the history of the
+// student, so versions in the trash and versions of purged students can be compared too.
+
+func Diff(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.PathValue("id") // get the ID from the URL path parameters
+
+		slog.Info("Comparing versions of student with ID: ", slog.String("id", id)) // log the ID whose versions are compared
+
+		intTd, err := strconv.ParseInt(id, 10, 64) // convert the ID from string to int64
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
+		}
+
+		query := r.URL.Query()
+
+		if !query.Has("from") || !query.Has("to") {
+			response.WriteError(w, r, http.StatusBadRequest, errors.New("from and to versions are required"))
+
+			return
+		}
+
+		from, err := intParam(query, "from", 0, 1, -1)
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if a version is not a positive integer, respond with a 400 Bad Request status code
+
+			return
+		}
+
+		to, err := intParam(query, "to", 0, 1, -1)
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err)
+
+			return
+		}
+
+		versions := make([]types.Student, 2)
+
+		for i, version := range []int{from, to} {
+			versions[i], err = storage.GetStudentVersion(r.Context(), intTd, int64(version)) // read both versions from the history of the student
+			if err != nil {
+				slog.Error("Error retrieving student version", slog.String("id", id), slog.Int("version", version), slog.Any("error", err))
+
+				response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found status code if there is no such version
+
+				return
+			}
+		}
+
+		changes := diffStudents(versions[0], versions[1])
+
+		if _, ok := changes["email"]; ok && !auth.Allowed(r.Context(), auth.PermissionPII) {
+			changes["email"] = types.FieldChange{From: redact.Email(versions[0].Email), To: redact.Email(versions[1].Email)} // the versions are compared before their emails are masked, so that a change is still reported
+		}
+
+		response.Write(w, r, http.StatusOK, types.StudentDiff{
+			ID:      intTd,
+			From:    int64(from),
+			To:      int64(to),
+			Changes: changes,
+		})
+	}
+}
+
+// diffStudents returns the fields whose values differ between two versions of a student, keyed by their JSON names.
+func diffStudents(from, to types.Student) map[string]types.FieldChange {
+	changes := map[string]types.FieldChange{}
+
+	if from.Name != to.Name {
+		changes["name"] = types.FieldChange{From: from.Name, To: to.Name}
+	}
+
+	if from.Email != to.Email {
+		changes["email"] = types.FieldChange{From: from.Email, To: to.Email}
+	}
+
+	if from.Age != to.Age {
+		changes["age"] = types.FieldChange{From: from.Age, To: to.Age}
+	}
+
+	if (from.DeletedAt == nil) != (to.DeletedAt == nil) || (from.DeletedAt != nil && !from.DeletedAt.Equal(*to.DeletedAt)) {
+		changes["deleted_at"] = types.FieldChange{From: from.DeletedAt, To: to.DeletedAt}
+	}
+
+	if (from.MergedInto == nil) != (to.MergedInto == nil) || (from.MergedInto != nil && *from.MergedInto != *to.MergedInto) {
+		changes["merged_into"] = types.FieldChange{From: from.MergedInto, To: to.MergedInto}
+	}
+
+	return changes
+}
@@ -0,0 +1,136 @@
+package student
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/dedupe"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// Duplicates(storage storage.Storage) returns a handler function that reports the groups of live students that were
+// likely entered more than once, matching their names and emails fuzzily. The threshold parameter, between 0 and 1,
+// is the lowest score of the reported matches; it defaults to dedupe.DefaultThreshold.
+
+func Duplicates(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		threshold := dedupe.DefaultThreshold
+
+		if value := r.URL.Query().Get("threshold"); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed <= 0 || parsed > 1 {
+				response.WriteError(w, r, http.StatusBadRequest, fmt.Errorf("threshold must be a number above 0 and at most 1, got %q", value)) // if the threshold is invalid, respond with a 400 Bad Request status code
+
+				return // return early to avoid further processing
+			}
+
+			threshold = parsed
+		}
+
+		slog.Info("Finding duplicate students", slog.Float64("threshold", threshold)) // log the threshold of the report
+
+		var students []types.Student
+
+		err := storage.StreamStudents(r.Context(), types.StudentFilter{}, func(student types.Student) error {
+			students = append(students, student) // every live student is compared, so they are all loaded
+
+			return nil
+		})
+		if err != nil {
+			slog.Error("Error listing students", slog.Any("error", err)) // log the error if the students cannot be read
+
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 500 Internal Server Error status code
+
+			return // return early to avoid further processing
+		}
+
+		groups := dedupe.Find(students, threshold)
+
+		for _, group := range groups {
+			redactStudents(r.Context(), group.Students) // the emails are masked once they were compared, unless the caller may see them
+		}
+
+		response.Write(w, r, http.StatusOK, types.DuplicateReport{
+			Groups:    groups,
+			Threshold: threshold,
+			Scanned:   len(students),
+		}) // respond with a 200 OK status code and the groups of likely duplicates
+	}
+}
+
+// mergeRequest is the body of a merge: the student to fold into another one, and the versions both students are
+// expected to be at, 0 to skip the check.
+type mergeRequest struct {
+	SourceID      int64 `json:"source_id" xml:"source_id"`
+	TargetID      int64 `json:"target_id" xml:"target_id"`
+	SourceVersion int64 `json:"source_version,omitempty" xml:"source_version,omitempty"`
+	TargetVersion int64 `json:"target_version,omitempty" xml:"target_version,omitempty"`
+}
+
+// Merge(storage storage.Storage) returns a handler function that folds the student source_id into the student
+// target_id. The source is moved to the trash with merged_into set and keeps its history; the target is returned as
+// it is. Restoring the source from the trash undoes the merge.
+
+func Merge(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var merge mergeRequest
+
+		err := response.Decode(r, &merge) // decode the request body, in the format of its Content-Type
+		if errors.Is(err, response.ErrUnsupportedMediaType) {
+			response.WriteError(w, r, http.StatusUnsupportedMediaType, err) // if the body is in a format that cannot be read, respond with a 415 Unsupported Media Type status code
+
+			return
+		}
+
+		if errors.Is(err, io.EOF) {
+			response.WriteError(w, r, http.StatusBadRequest, errors.New("request body is empty"))
+
+			return
+		}
+
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error decoding the request body, respond with a 400 Bad Request status code
+
+			return // return early to avoid further processing
+		}
+
+		switch {
+		case merge.SourceID <= 0 || merge.TargetID <= 0:
+			err = errors.New("source_id and target_id are required")
+		case merge.SourceID == merge.TargetID:
+			err = errors.New("source_id and target_id must be different students")
+		case merge.SourceVersion < 0 || merge.TargetVersion < 0:
+			err = errors.New("versions cannot be negative")
+		}
+
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err)
+
+			return
+		}
+
+		slog.Info("Merging students", slog.Int64("source_id", merge.SourceID), slog.Int64("target_id", merge.TargetID)) // log the students being merged
+
+		target, err := storage.MergeStudents(r.Context(), merge.SourceID, merge.TargetID, merge.SourceVersion, merge.TargetVersion) // call the MergeStudents method on the storage interface to fold the source into the target
+		if err != nil {
+			slog.Error("Error merging students", slog.Int64("source_id", merge.SourceID), slog.Int64("target_id", merge.TargetID), slog.Any("error", err))
+
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found status code if a student is missing, 412 Precondition Failed if one is at another version
+
+			return // return early to avoid further processing
+		}
+
+		w.Header().Set("ETag", studentETag(target)) // the target keeps its version
+
+		response.Write(w, r, http.StatusOK, redactStudent(r.Context(), target))                                                     // if the students are merged successfully, respond with a 200 OK status code and the target
+		slog.Info("Students merged successfully", slog.Int64("source_id", merge.SourceID), slog.Int64("target_id", merge.TargetID)) // log the successful merge
+	}
+}
@@ -0,0 +1,38 @@
+package student
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// storageErrorStatus maps an error returned by the storage layer to the HTTP status code reported to the client.
+func storageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrVersionMismatch), errors.Is(err, errPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrBatchAborted):
+		return http.StatusFailedDependency // the operation was rolled back along with a failed one of its batch
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// storageProblem returns the problem reported to the client for an error returned by the storage layer, with the
+// status of storageErrorStatus. A duplicate email also names the student that already uses the email.
+func storageProblem(err error) response.Problem {
+	problem := response.NewProblem(storageErrorStatus(err), err)
+
+	var duplicate *storage.DuplicateEmailError
+	if errors.As(err, &duplicate) {
+		problem.ConflictingID = duplicate.ID
+	}
+
+	return problem
+}
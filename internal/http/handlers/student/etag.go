@@ -0,0 +1,101 @@
+package student
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// errPreconditionFailed is returned when the If-Match header of a request cannot match the student.
+var errPreconditionFailed = errors.New("precondition failed")
+
+// studentETag returns the entity tag of a student, a strong tag holding its version.
+func studentETag(student types.Student) string {
+	return `"` + strconv.FormatInt(student.Version, 10) + `"`
+}
+
+// etagVersion returns the version held by an entity tag from studentETag. Weak tags never match,
+// since If-Match uses the strong comparison.
+func etagVersion(tag string) (int64, bool) {
+	unquoted, ok := strings.CutPrefix(tag, `"`)
+	if !ok {
+		return 0, false
+	}
+
+	unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	if !ok {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// ifMatchVersion turns the If-Match header of a write into the version the storage has to find the student at.
+// It returns 0, which skips the check, when the header is missing or "*". When the header lists several tags,
+// current is called to pick the one the student is at.
+func ifMatchVersion(header string, current func() (types.Student, error)) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	var versions []int64
+
+	for tag := range strings.SplitSeq(header, ",") {
+		if version, ok := etagVersion(strings.TrimSpace(tag)); ok {
+			versions = append(versions, version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, fmt.Errorf("%w: If-Match %s matches no version of the student", errPreconditionFailed, header)
+	case 1:
+		return versions[0], nil
+	}
+
+	student, err := current()
+	if err != nil {
+		return 0, err
+	}
+
+	if !slices.Contains(versions, student.Version) {
+		return 0, fmt.Errorf("%w: student %d is at version %d, If-Match is %s", errPreconditionFailed, student.Id, student.Version, header)
+	}
+
+	return student.Version, nil
+}
+
+// deletedStudentVersions is the current function passed to ifMatchVersion for students in the trash, which
+// GetStudentByID does not return. It limits If-Match on them to a single tag.
+func deletedStudentVersions() (types.Student, error) {
+	return types.Student{}, fmt.Errorf("%w: If-Match on a deleted student takes a single entity tag", errPreconditionFailed)
+}
+
+// noneMatch reports whether the If-None-Match header of a request matches the student, in which case a
+// conditional GET is answered with 304 Not Modified. Tags are compared weakly, as If-None-Match requires.
+func noneMatch(header string, student types.Student) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+
+	etag := studentETag(student)
+
+	for tag := range strings.SplitSeq(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,125 @@
+package student
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/memory"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+func TestIfMatch(t *testing.T) {
+	const update = `{"name": "Ann Smith", "email": "ann@example.com", "age": 21}`
+
+	tests := []struct {
+		name    string
+		handler func(t *testing.T, store *memory.Memory) http.Handler
+		method  string
+		body    string
+		deleted bool // whether the student is in the trash, at version 2, before the request
+		ifMatch string
+		want    int
+	}{
+		{"update without If-Match", updateHandler, http.MethodPut, update, false, "", http.StatusOK},
+		{"update with a matching tag", updateHandler, http.MethodPut, update, false, `"1"`, http.StatusOK},
+		{"update with *", updateHandler, http.MethodPut, update, false, `*`, http.StatusOK},
+		{"update with a list holding the version", updateHandler, http.MethodPut, update, false, `"2", "1"`, http.StatusOK},
+		{"update with a stale tag", updateHandler, http.MethodPut, update, false, `"2"`, http.StatusPreconditionFailed},
+		{"update with a weak tag", updateHandler, http.MethodPut, update, false, `W/"1"`, http.StatusPreconditionFailed},
+		{"update with a list without the version", updateHandler, http.MethodPut, update, false, `"2", "3"`, http.StatusPreconditionFailed},
+		{"update with a malformed tag", updateHandler, http.MethodPut, update, false, `version 1`, http.StatusPreconditionFailed},
+		{"update with an invalid body and a stale tag", updateHandler, http.MethodPut, `{"name": ""}`, false, `"2"`, http.StatusBadRequest},
+		{"delete without If-Match", deleteHandler, http.MethodDelete, "", false, "", http.StatusOK},
+		{"delete with a matching tag", deleteHandler, http.MethodDelete, "", false, `"1"`, http.StatusOK},
+		{"delete with a list holding the version", deleteHandler, http.MethodDelete, "", false, `"1", "2"`, http.StatusOK},
+		{"delete with a stale tag", deleteHandler, http.MethodDelete, "", false, `"2"`, http.StatusPreconditionFailed},
+		{"delete with a weak tag", deleteHandler, http.MethodDelete, "", false, `W/"1"`, http.StatusPreconditionFailed},
+		{"delete with a list without the version", deleteHandler, http.MethodDelete, "", false, `"2", "3"`, http.StatusPreconditionFailed},
+		{"restore without If-Match", restoreHandler, http.MethodPost, "", true, "", http.StatusOK},
+		{"restore with a matching tag", restoreHandler, http.MethodPost, "", true, `"2"`, http.StatusOK},
+		{"restore with a stale tag", restoreHandler, http.MethodPost, "", true, `"1"`, http.StatusPreconditionFailed},
+		{"restore with a weak tag", restoreHandler, http.MethodPost, "", true, `W/"2"`, http.StatusPreconditionFailed},
+		{"restore with a list", restoreHandler, http.MethodPost, "", true, `"1", "2"`, http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+
+			if tt.deleted {
+				if err := store.DeleteStudent(t.Context(), 1, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			header := map[string]string{"Content-Type": "application/json"}
+			if tt.ifMatch != "" {
+				header["If-Match"] = tt.ifMatch
+			}
+
+			rec := serve(tt.handler(t, store), tt.method, "1", header, tt.body)
+
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+
+			students, _, err := store.GetStudents(t.Context(), types.StudentFilter{Deleted: tt.deleted, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			unchanged := len(students) == 1 && students[0].Version == map[bool]int64{false: 1, true: 2}[tt.deleted]
+
+			if unchanged != (tt.want != http.StatusOK) {
+				t.Fatalf("got students %+v after status %d", students, rec.Code)
+			}
+		})
+	}
+}
+
+func updateHandler(t *testing.T, store *memory.Memory) http.Handler {
+	return Update(store, newTestValidator(t))
+}
+
+func deleteHandler(_ *testing.T, store *memory.Memory) http.Handler {
+	return Delete(store)
+}
+
+func restoreHandler(_ *testing.T, store *memory.Memory) http.Handler {
+	return Restore(store)
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"no If-None-Match", "", http.StatusOK},
+		{"matching tag", `"1"`, http.StatusNotModified},
+		{"weak matching tag", `W/"1"`, http.StatusNotModified},
+		{"*", `*`, http.StatusNotModified},
+		{"list holding the version", `"3", W/"1"`, http.StatusNotModified},
+		{"other tag", `"2"`, http.StatusOK},
+		{"list without the version", `"2", "3"`, http.StatusOK},
+		{"unquoted tag", `1`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(GetByID(newTestStore(t)), http.MethodGet, "1", map[string]string{"If-None-Match": tt.ifNoneMatch}, "")
+
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.want)
+			}
+
+			if etag := rec.Header().Get("ETag"); etag != `"1"` {
+				t.Fatalf(`got ETag %s, want "1"`, etag)
+			}
+
+			if tt.want == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Fatalf("304 response has a body: %s", rec.Body)
+			}
+		})
+	}
+}
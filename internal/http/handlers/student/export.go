@@ -0,0 +1,104 @@
+package student
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/export"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/http/middleware"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// Export(storage storage.Storage) returns a handler function that downloads every student matching the filters of
+// GET /api/students as a csv (the default), ndjson or xlsx file, picked with the format parameter. Students are
+// written to the response as they are read from the storage, so exports of any size are never held in memory.
+// Pagination parameters are ignored.
+
+func Export(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		query := r.URL.Query()
+
+		name := query.Get("format")
+		if name == "" {
+			name = export.Formats[0].Name
+		}
+
+		slog.Info("Exporting students", slog.String("format", name)) // log the export format
+
+		format, ok := export.Lookup(name)
+		if !ok {
+			names := make([]string, len(export.Formats))
+			for i, format := range export.Formats {
+				names[i] = format.Name
+			}
+
+			response.WriteError(w, r, http.StatusBadRequest, fmt.Errorf("unsupported format %q, use one of %s", name, strings.Join(names, ", ")))
+
+			return // return early to avoid further processing
+		}
+
+		filter, err := parseListFilter(query) // the export takes the same filters and sort order as the list
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if the query parameters are invalid, respond with a 400 Bad Request status code
+
+			return
+		}
+
+		if !emailQueryAllowed(r.Context(), filter) {
+			middleware.Forbid(w, r, auth.PermissionPII) // respond with a 403 Forbidden status code, the emails of the students are masked for this caller
+
+			return
+		}
+
+		started := false // whether the file has started, after which errors can no longer be reported with a status code
+
+		start := func() {
+			if started {
+				return
+			}
+
+			started = true
+
+			filename := fmt.Sprintf("students-%s.%s", time.Now().UTC().Format("20060102-150405"), format.Extension)
+
+			w.Header().Set("Content-Type", format.ContentType)
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		}
+
+		writer := format.NewWriter(w)
+		exported := 0
+
+		err = storage.StreamStudents(r.Context(), filter, func(student types.Student) error {
+			start()
+			exported++
+
+			return writer.Write(redactStudent(r.Context(), student)) // mask the email unless the caller may see it
+		})
+		if err == nil {
+			start() // an export without students still has a header
+			err = writer.Close()
+		}
+
+		if err != nil {
+			slog.Error("Error exporting students", slog.String("format", format.Name), slog.Int("exported", exported), slog.Any("error", err))
+
+			if !started {
+				response.WriteError(w, r, http.StatusInternalServerError, err) // nothing was sent yet, respond with a 500 Internal Server Error status code
+
+				return
+			}
+
+			panic(http.ErrAbortHandler) // the response is under way: abort it so that the client sees a broken download rather than a truncated file
+		}
+
+		slog.Info("Students exported", slog.String("format", format.Name), slog.Int("exported", exported))
+	}
+}
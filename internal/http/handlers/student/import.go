@@ -0,0 +1,91 @@
+package student
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/csvimport"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxImportBytes is the largest CSV file accepted by POST /api/students/import.
+const maxImportBytes = 10 << 20
+
+// Import(storage storage.Storage, mapping map[string]string, validate *validator.Validate) returns a handler function that creates students from
+// a text/csv body whose first row is a header. Columns are matched to student fields by name or by the configured
+// mapping, which map=Header=field query parameters extend. Rows are validated like single requests and the response
+// is a row-level report of what was imported and what was wrong. With dry_run=true nothing is written; with
+// atomic=true, the default, nothing is written unless every row is valid, while atomic=false imports the valid rows.
+// The response is 200 OK when no row has errors and 422 Unprocessable Entity otherwise.
+
+func Import(storage storage.Storage, mapping map[string]string, validate *validator.Validate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		slog.Info("Importing students from CSV")
+
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/csv" {
+			response.WriteError(w, r, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q, use text/csv", r.Header.Get("Content-Type")))
+
+			return // return early to avoid further processing
+		}
+
+		query := r.URL.Query()
+
+		opts := csvimport.Options{Atomic: true, Validate: validate}
+
+		for name, value := range map[string]*bool{"dry_run": &opts.DryRun, "atomic": &opts.Atomic} {
+			if raw := query.Get(name); raw != "" {
+				parsed, err := strconv.ParseBool(raw)
+				if err != nil {
+					response.WriteError(w, r, http.StatusBadRequest, fmt.Errorf("%s must be true or false", name))
+
+					return
+				}
+
+				*value = parsed
+			}
+		}
+
+		requestMapping, err := csvimport.ParseMapping(query["map"])
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if a mapping is malformed, respond with a 400 Bad Request status code
+
+			return
+		}
+
+		opts.Mapping = csvimport.MergeMappings(mapping, requestMapping) // mappings of the request take precedence over the configured ones
+
+		report, err := csvimport.Import(r.Context(), storage, http.MaxBytesReader(w, r.Body, maxImportBytes), opts)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+
+			switch {
+			case errors.Is(err, csvimport.ErrInvalidCSV):
+				response.WriteError(w, r, http.StatusBadRequest, err) // if the file cannot be imported at all, respond with a 400 Bad Request status code
+			case errors.As(err, &tooLarge):
+				response.WriteError(w, r, http.StatusRequestEntityTooLarge, err)
+			default:
+				slog.Error("Error importing students", slog.Any("error", err))
+
+				response.WriteError(w, r, http.StatusInternalServerError, err)
+			}
+
+			return
+		}
+
+		slog.Info("Students imported", slog.Int("rows", report.Rows), slog.Int("imported", report.Imported), slog.Int("errors", len(report.Errors)), slog.Bool("dry_run", report.DryRun))
+
+		status := http.StatusOK
+		if len(report.Errors) > 0 {
+			status = http.StatusUnprocessableEntity
+		}
+
+		response.Write(w, r, status, report)
+	}
+}
@@ -0,0 +1,67 @@
+package student
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestHandlersLogNoPII checks the logs of the handlers that write or search students without the redacting
+// handler, as with logging.redact_pii turned off.
+func TestHandlersLogNoPII(t *testing.T) {
+	var logs bytes.Buffer
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	store := newTestStore(t)
+	validate := newTestValidator(t)
+	json := map[string]string{"Content-Type": "application/json"}
+
+	requests := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		id      string
+		header  map[string]string
+		body    string
+		want    int
+	}{
+		{"create", New(store, validate), http.MethodPost, "", json, `{"name": "Bea Park", "email": "bea@example.com", "age": 22}`, http.StatusCreated},
+		{"failed create", New(store, validate), http.MethodPost, "", json, `{"name": "Cy Park", "email": "ann@example.com", "age": 22}`, http.StatusConflict},
+		{"update", Update(store, validate), http.MethodPut, "1", json, `{"name": "Ann Smith", "email": "ann.smith@example.com", "age": 21}`, http.StatusOK},
+		{"patch", Patch(store, validate), http.MethodPatch, "1", map[string]string{"Content-Type": mergePatchMediaType}, `{"name": "Ann Jones"}`, http.StatusOK},
+	}
+
+	for _, req := range requests {
+		if rec := serve(req.handler, req.method, req.id, req.header, req.body); rec.Code != req.want {
+			t.Fatalf("%s: got status %d, want %d: %s", req.name, rec.Code, req.want, rec.Body)
+		}
+	}
+
+	searchLogs := logs.Len()
+
+	search := httptest.NewRequest(http.MethodGet, "/api/students/search?q="+url.QueryEscape("Bea bea@example.com"), nil)
+	Search(store).ServeHTTP(httptest.NewRecorder(), search)
+
+	got := logs.String()
+
+	for _, leak := range []string{"Bea", "bea@", "Cy Park", "ann@", "Ann Smith", "ann.smith@", "Ann Jones", "Park"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("logs leak %q:\n%s", leak, got)
+		}
+	}
+
+	if n := strings.Count(got, "Student created successfully"); n != 1 {
+		t.Errorf("logged %d successful creates, want 1 for 2 creates of which one failed:\n%s", n, got)
+	}
+
+	if !strings.Contains(got[searchLogs:], "terms=2") {
+		t.Errorf("search logs %q, want the number of terms", got[searchLogs:])
+	}
+}
@@ -0,0 +1,202 @@
+package student
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+const (
+	defaultPageSize = 20  // page size used when the request does not ask for one
+	maxPageSize     = 100 // largest page size a client may ask for
+)
+
+// parseListFilter reads the pagination, filter and sort query parameters of GET /api/students into a StudentFilter.
+//
+// Pages can be requested either with limit/offset or with page/page_size. The sort parameter takes a field name,
+// optionally prefixed with "-" for descending order, e.g. sort=-age. as_of takes an RFC 3339 timestamp and lists
+// the students as they were at that time.
+func parseListFilter(query url.Values) (types.StudentFilter, error) {
+	var filter types.StudentFilter
+
+	filter.Name = strings.TrimSpace(query.Get("name"))
+	filter.Email = strings.TrimSpace(query.Get("email"))
+
+	minAge, err := optionalInt(query, "min_age")
+	if err != nil {
+		return filter, err
+	}
+	filter.MinAge = minAge
+
+	maxAge, err := optionalInt(query, "max_age")
+	if err != nil {
+		return filter, err
+	}
+	filter.MaxAge = maxAge
+
+	if minAge != nil && maxAge != nil && *minAge > *maxAge {
+		return filter, fmt.Errorf("min_age must not be greater than max_age")
+	}
+
+	if sort := strings.TrimSpace(query.Get("sort")); sort != "" {
+		if strings.HasPrefix(sort, "-") {
+			filter.Desc = true
+			sort = sort[1:]
+		}
+
+		switch sort {
+		case types.SortByID, types.SortByName, types.SortByEmail, types.SortByAge:
+			filter.Sort = sort
+		default:
+			return filter, fmt.Errorf("invalid sort field %q", sort)
+		}
+	}
+
+	if filter.AsOf, err = timeParam(query, "as_of"); err != nil {
+		return filter, err
+	}
+
+	limit, offset, err := parsePage(query)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Limit = limit
+	filter.Offset = offset
+
+	return filter, nil
+}
+
+// parseAuditFilter reads the filter and pagination query parameters of GET /api/audit into an AuditFilter.
+// since and until take RFC 3339 timestamps.
+func parseAuditFilter(query url.Values) (types.AuditFilter, error) {
+	var filter types.AuditFilter
+
+	if raw := query.Get("student_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("student_id must be an integer")
+		}
+
+		filter.StudentID = id
+	}
+
+	filter.Actor = strings.TrimSpace(query.Get("actor"))
+	filter.RequestID = strings.TrimSpace(query.Get("request_id"))
+
+	switch action := strings.TrimSpace(query.Get("action")); action {
+	case "", types.AuditCreate, types.AuditUpdate, types.AuditDelete, types.AuditRestore, types.AuditPurge, types.AuditMerge:
+		filter.Action = action
+	default:
+		return filter, fmt.Errorf("invalid action %q", action)
+	}
+
+	var err error
+
+	if filter.Since, err = timeParam(query, "since"); err != nil {
+		return filter, err
+	}
+
+	if filter.Until, err = timeParam(query, "until"); err != nil {
+		return filter, err
+	}
+
+	filter.Limit, filter.Offset, err = parsePage(query)
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// parsePage reads either limit/offset or page/page_size from the query and returns the resulting limit and offset.
+func parsePage(query url.Values) (int, int, error) {
+	if query.Has("page") || query.Has("page_size") {
+		if query.Has("offset") {
+			return 0, 0, fmt.Errorf("offset cannot be combined with page")
+		}
+
+		pageSize, err := intParam(query, "page_size", defaultPageSize, 1, maxPageSize)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		page, err := intParam(query, "page", 1, 1, math.MaxInt/pageSize+1) // bounded so that the offset cannot overflow
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return pageSize, (page - 1) * pageSize, nil
+	}
+
+	limit, err := intParam(query, "limit", defaultPageSize, 1, maxPageSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	offset, err := intParam(query, "offset", 0, 0, -1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return limit, offset, nil
+}
+
+// intParam reads an integer query parameter, falling back to def when it is absent.
+// A negative hi means the parameter has no upper bound.
+func intParam(query url.Values, name string, def, lo, hi int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+
+	if value < lo || (hi >= 0 && value > hi) {
+		if hi >= 0 {
+			return 0, fmt.Errorf("%s must be between %d and %d", name, lo, hi)
+		}
+
+		return 0, fmt.Errorf("%s must be at least %d", name, lo)
+	}
+
+	return value, nil
+}
+
+// optionalInt reads an integer query parameter that may be absent, in which case it returns nil.
+func optionalInt(query url.Values, name string) (*int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+
+	return &value, nil
+}
+
+// timeParam reads an RFC 3339 timestamp query parameter, returning the zero time when it is absent.
+func timeParam(query url.Values, name string) (time.Time, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,50 @@
+package student
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query  string
+		limit  int
+		offset int
+		ok     bool
+	}{
+		{"", defaultPageSize, 0, true},
+		{"limit=10&offset=30", 10, 30, true},
+		{"page=3&page_size=10", 10, 20, true},
+		{"page=2", defaultPageSize, defaultPageSize, true},
+		{"page=0", 0, 0, false},
+		{"page_size=101", 0, 0, false},
+		{"page=2&offset=10", 0, 0, false},
+		{"page=" + strconv.Itoa(math.MaxInt/10+1) + "&page_size=10", 10, math.MaxInt / 10 * 10, true},
+		{"page=" + strconv.Itoa(math.MaxInt/10+2) + "&page_size=10", 0, 0, false}, // the offset would overflow
+		{"page=" + strconv.Itoa(math.MaxInt), 0, 0, false},
+		{"page=99999999999999999999", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		limit, offset, err := parsePage(query)
+
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("parsePage(%q) = %d, %d, want an error", tt.query, limit, offset)
+			}
+
+			continue
+		}
+
+		if err != nil || limit != tt.limit || offset != tt.offset {
+			t.Errorf("parsePage(%q) = %d, %d, %v, want %d, %d", tt.query, limit, offset, err, tt.limit, tt.offset)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package student
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// Media types accepted by PATCH /api/students/{id}.
+const (
+	mergePatchMediaType = "application/merge-patch+json" // RFC 7396 JSON Merge Patch
+	jsonPatchMediaType  = "application/json-patch+json"  // RFC 6902 JSON Patch
+)
+
+var (
+	// errPatchUnprocessable is returned for well-formed patches that cannot be applied to a student,
+	// like removing a required field or changing the ID.
+	errPatchUnprocessable = errors.New("patch cannot be applied")
+
+	// errPatchTestFailed is returned when a JSON Patch test operation does not match the student.
+	errPatchTestFailed = errors.New("patch test failed")
+)
+
+// setPatchField sets the field of the patch named by a JSON member name or JSON Patch path segment.
+func setPatchField(patch *types.StudentPatch, field string, value json.RawMessage) error {
+	if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+		return fmt.Errorf("%w: field %s is required and cannot be removed", errPatchUnprocessable, field)
+	}
+
+	var err error
+
+	switch field {
+	case "name":
+		patch.Name = new(string)
+		err = json.Unmarshal(value, patch.Name)
+	case "email":
+		patch.Email = new(string)
+		err = json.Unmarshal(value, patch.Email)
+	case "age":
+		patch.Age = new(int)
+		err = json.Unmarshal(value, patch.Age)
+	case "id":
+		return fmt.Errorf("%w: field id cannot be changed", errPatchUnprocessable)
+	default:
+		return fmt.Errorf("%w: unknown field %s", errPatchUnprocessable, field)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid value for field %s: %w", field, err)
+	}
+
+	return nil
+}
+
+// parseMergePatch reads an RFC 7396 merge patch. Members present in the document are written, members left out
+// are kept; null would remove a member, which the required student fields do not allow.
+func parseMergePatch(body []byte) (types.StudentPatch, error) {
+	var patch types.StudentPatch
+	var members map[string]json.RawMessage
+
+	if err := json.Unmarshal(body, &members); err != nil {
+		return patch, fmt.Errorf("merge patch must be a JSON object: %w", err)
+	}
+
+	for field, value := range members {
+		if err := setPatchField(&patch, field, value); err != nil {
+			return patch, err
+		}
+	}
+
+	return patch, nil
+}
+
+// jsonPatchOperation is a single operation of an RFC 6902 JSON Patch document.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// parseJSONPatch reads an RFC 6902 JSON Patch and folds its operations into a single patch.
+// Only add, replace and test are supported on /name, /email and /age: the fields are required, so remove and move
+// cannot be applied. Test operations are checked against the student returned by current, which is only called when
+// the document has one, with the earlier operations of the document applied.
+func parseJSONPatch(body []byte, current func() (types.Student, error)) (types.StudentPatch, error) {
+	var patch types.StudentPatch
+	var operations []jsonPatchOperation
+
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return patch, fmt.Errorf("JSON patch must be an array of operations: %w", err)
+	}
+
+	var student *types.Student // state of the student as seen by test operations, loaded on first use
+
+	for i, operation := range operations {
+		field, ok := strings.CutPrefix(operation.Path, "/")
+		if !ok || strings.Contains(field, "/") {
+			return patch, fmt.Errorf("%w: operation %d has unsupported path %q", errPatchUnprocessable, i, operation.Path)
+		}
+
+		if operation.Value == nil && operation.Op != "remove" {
+			return patch, fmt.Errorf("operation %d is missing a value", i)
+		}
+
+		switch operation.Op {
+		case "add", "replace":
+			if err := setPatchField(&patch, field, operation.Value); err != nil {
+				return patch, fmt.Errorf("operation %d: %w", i, err)
+			}
+		case "test":
+			if student == nil {
+				loaded, err := current()
+				if err != nil {
+					return patch, err
+				}
+
+				student = &loaded
+			}
+
+			var expected types.StudentPatch
+
+			if err := setPatchField(&expected, field, operation.Value); err != nil {
+				return patch, fmt.Errorf("operation %d: %w", i, err)
+			}
+
+			if patched := patch.Apply(*student); expected.Apply(patched) != patched {
+				return patch, fmt.Errorf("%w: operation %d, %s does not have the expected value", errPatchTestFailed, i, operation.Path)
+			}
+		case "remove", "move", "copy":
+			return patch, fmt.Errorf("%w: operation %d, %s is not supported on students", errPatchUnprocessable, i, operation.Op)
+		default:
+			return patch, fmt.Errorf("operation %d has unknown op %q", i, operation.Op)
+		}
+	}
+
+	return patch, nil
+}
+
+// patchFields returns the names of the Student struct fields set in a patch, for partial validation.
+func patchFields(patch types.StudentPatch) []string {
+	var fields []string
+
+	if patch.Name != nil {
+		fields = append(fields, "Name")
+	}
+
+	if patch.Email != nil {
+		fields = append(fields, "Email")
+	}
+
+	if patch.Age != nil {
+		fields = append(fields, "Age")
+	}
+
+	return fields
+}
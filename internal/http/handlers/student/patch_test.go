@@ -0,0 +1,142 @@
+package student
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/memory"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/validation"
+	"github.com/go-playground/validator/v10"
+)
+
+// newTestStore returns a memory storage holding student 1, Ann Lee, at version 1.
+func newTestStore(t *testing.T) *memory.Memory {
+	t.Helper()
+
+	store := memory.New()
+
+	if _, err := store.CreateStudent(t.Context(), "Ann Lee", "ann@example.com", 20); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func newTestValidator(t *testing.T) *validator.Validate {
+	t.Helper()
+
+	validate, err := validation.New(config.Validation{NameMinLength: 1, NameMaxLength: 100, MaxAge: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return validate
+}
+
+// serve runs a handler on a request for the student of the given ID, with the given headers and body.
+func serve(handler http.Handler, method, id string, header map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/students/"+id, strings.NewReader(body))
+	req.SetPathValue("id", id)
+
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		contentType string
+		ifMatch     string
+		body        string
+		want        int
+		wantName    string // name of the student after the patch, when it succeeds
+	}{
+		{"merge patch", "1", mergePatchMediaType, "", `{"name": "Ann Smith"}`, http.StatusOK, "Ann Smith"},
+		{"merge patch as application/json", "1", "application/json", "", `{"age": 21}`, http.StatusOK, "Ann Lee"},
+		{"empty merge patch", "1", mergePatchMediaType, "", `{}`, http.StatusOK, "Ann Lee"},
+		{"merge patch removing a field", "1", mergePatchMediaType, "", `{"name": null}`, http.StatusUnprocessableEntity, ""},
+		{"merge patch changing the id", "1", mergePatchMediaType, "", `{"id": 2}`, http.StatusUnprocessableEntity, ""},
+		{"merge patch with an unknown field", "1", mergePatchMediaType, "", `{"grade": "A"}`, http.StatusUnprocessableEntity, ""},
+		{"merge patch that is not an object", "1", mergePatchMediaType, "", `[{"name": "Ann"}]`, http.StatusBadRequest, ""},
+		{"merge patch with a value of the wrong type", "1", mergePatchMediaType, "", `{"age": "twenty"}`, http.StatusBadRequest, ""},
+		{"merge patch with an invalid value", "1", mergePatchMediaType, "", `{"email": "not an email"}`, http.StatusBadRequest, ""},
+		{"JSON patch", "1", jsonPatchMediaType, "", `[{"op": "replace", "path": "/name", "value": "Ann Smith"}]`, http.StatusOK, "Ann Smith"},
+		{"JSON patch with a passing test", "1", jsonPatchMediaType, "", `[{"op": "test", "path": "/name", "value": "Ann Lee"}, {"op": "add", "path": "/name", "value": "Ann Smith"}]`, http.StatusOK, "Ann Smith"},
+		{"JSON patch testing an earlier operation", "1", jsonPatchMediaType, "", `[{"op": "replace", "path": "/age", "value": 30}, {"op": "test", "path": "/age", "value": 30}]`, http.StatusOK, "Ann Lee"},
+		{"JSON patch with a failing test", "1", jsonPatchMediaType, "", `[{"op": "test", "path": "/name", "value": "Bob"}, {"op": "replace", "path": "/name", "value": "Ann Smith"}]`, http.StatusConflict, ""},
+		{"JSON patch test of a missing student", "9", jsonPatchMediaType, "", `[{"op": "test", "path": "/name", "value": "Ann Lee"}]`, http.StatusNotFound, ""},
+		{"JSON patch remove", "1", jsonPatchMediaType, "", `[{"op": "remove", "path": "/age"}]`, http.StatusUnprocessableEntity, ""},
+		{"JSON patch move", "1", jsonPatchMediaType, "", `[{"op": "move", "from": "/name", "path": "/email", "value": 1}]`, http.StatusUnprocessableEntity, ""},
+		{"JSON patch with an unknown op", "1", jsonPatchMediaType, "", `[{"op": "rename", "path": "/name", "value": "Ann"}]`, http.StatusBadRequest, ""},
+		{"JSON patch with a nested path", "1", jsonPatchMediaType, "", `[{"op": "replace", "path": "/name/first", "value": "Ann"}]`, http.StatusUnprocessableEntity, ""},
+		{"JSON patch with a relative path", "1", jsonPatchMediaType, "", `[{"op": "replace", "path": "name", "value": "Ann"}]`, http.StatusUnprocessableEntity, ""},
+		{"JSON patch without a value", "1", jsonPatchMediaType, "", `[{"op": "replace", "path": "/name"}]`, http.StatusBadRequest, ""},
+		{"JSON patch with a null value", "1", jsonPatchMediaType, "", `[{"op": "replace", "path": "/name", "value": null}]`, http.StatusUnprocessableEntity, ""},
+		{"JSON patch that is not an array", "1", jsonPatchMediaType, "", `{"op": "replace"}`, http.StatusBadRequest, ""},
+		{"unsupported content type", "1", "text/plain", "", `name=Ann`, http.StatusUnsupportedMediaType, ""},
+		{"invalid ID", "abc", mergePatchMediaType, "", `{"name": "Ann Smith"}`, http.StatusBadRequest, ""},
+		{"missing student", "9", mergePatchMediaType, "", `{"name": "Ann Smith"}`, http.StatusNotFound, ""},
+		{"matching If-Match", "1", mergePatchMediaType, `"1"`, `{"name": "Ann Smith"}`, http.StatusOK, "Ann Smith"},
+		{"If-Match *", "1", mergePatchMediaType, `*`, `{"name": "Ann Smith"}`, http.StatusOK, "Ann Smith"},
+		{"If-Match list holding the version", "1", mergePatchMediaType, `"3", "1"`, `{"name": "Ann Smith"}`, http.StatusOK, "Ann Smith"},
+		{"stale If-Match", "1", mergePatchMediaType, `"2"`, `{"name": "Ann Smith"}`, http.StatusPreconditionFailed, ""},
+		{"weak If-Match", "1", mergePatchMediaType, `W/"1"`, `{"name": "Ann Smith"}`, http.StatusPreconditionFailed, ""},
+		{"If-Match list without the version", "1", mergePatchMediaType, `"2", "3"`, `{"name": "Ann Smith"}`, http.StatusPreconditionFailed, ""},
+		{"malformed If-Match", "1", mergePatchMediaType, `1`, `{"name": "Ann Smith"}`, http.StatusPreconditionFailed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+
+			header := map[string]string{"Content-Type": tt.contentType}
+			if tt.ifMatch != "" {
+				header["If-Match"] = tt.ifMatch
+			}
+
+			rec := serve(Patch(store, newTestValidator(t)), http.MethodPatch, tt.id, header, tt.body)
+
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+
+			stored, err := store.GetStudentByID(t.Context(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want != http.StatusOK {
+				if stored.Version != 1 {
+					t.Fatalf("a rejected patch changed the student to %+v", stored)
+				}
+
+				return
+			}
+
+			var patched types.Student
+			if err := json.Unmarshal(rec.Body.Bytes(), &patched); err != nil {
+				t.Fatal(err)
+			}
+
+			if patched.Name != tt.wantName || stored.Name != tt.wantName || stored.Email != "ann@example.com" {
+				t.Fatalf("patch returned %+v and stored %+v, want the name %q", patched, stored, tt.wantName)
+			}
+
+			if etag := rec.Header().Get("ETag"); etag != studentETag(stored) {
+				t.Fatalf("got ETag %s, want %s", etag, studentETag(stored))
+			}
+		})
+	}
+}
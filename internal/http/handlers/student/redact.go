@@ -0,0 +1,67 @@
+package student
+
+import (
+	"context"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/redact"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// redactStudent masks the email of a student unless the caller of the request ctx belongs to may see it.
+func redactStudent(ctx context.Context, student types.Student) types.Student {
+	if !auth.Allowed(ctx, auth.PermissionPII) {
+		student.Email = redact.Email(student.Email)
+	}
+
+	return student
+}
+
+// redactStudents masks the emails of students in place unless the caller of the request ctx belongs to may see them.
+func redactStudents(ctx context.Context, students []types.Student) {
+	if auth.Allowed(ctx, auth.PermissionPII) {
+		return
+	}
+
+	for i := range students {
+		students[i].Email = redact.Email(students[i].Email)
+	}
+}
+
+// emailQueryAllowed reports whether the caller of the request ctx belongs to may list students filtered or sorted by
+// email. Both would give masked emails away: the filter tells whether an address is in use, and the order of the
+// students, like the cursors of the listing, follows their emails.
+func emailQueryAllowed(ctx context.Context, filter types.StudentFilter) bool {
+	return auth.Allowed(ctx, auth.PermissionPII) || (filter.Email == "" && filter.Sort != types.SortByEmail)
+}
+
+// redactSearchResults masks the emails of search results in place, highlights included, unless the caller of the
+// request ctx belongs to may see them.
+func redactSearchResults(ctx context.Context, results []types.StudentSearchResult) {
+	if auth.Allowed(ctx, auth.PermissionPII) {
+		return
+	}
+
+	for i := range results {
+		results[i].Student.Email = redact.Email(results[i].Student.Email)
+		results[i].Highlights.Email = results[i].Student.Email // the highlights would give the email away
+	}
+}
+
+// redactAuditEntries masks the emails of the snapshots of audit entries in place unless the caller of the request
+// ctx belongs to may see them.
+func redactAuditEntries(ctx context.Context, entries []types.AuditEntry) {
+	if auth.Allowed(ctx, auth.PermissionPII) {
+		return
+	}
+
+	for i := range entries {
+		for _, snapshot := range []**types.Student{&entries[i].Before, &entries[i].After} {
+			if *snapshot != nil {
+				masked := **snapshot // copied, the snapshot may be shared with the storage
+				masked.Email = redact.Email(masked.Email)
+				*snapshot = &masked
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package student
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+func TestSearchEmails(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		q           string
+		want        []int64
+		wantEmail   string // email of the first result, masked or not
+	}{
+		{"name without PII", []string{auth.PermissionRead}, "ann", []int64{1}, "a***@example.com"},
+		{"email without PII", []string{auth.PermissionRead}, "ann@example.com", nil, ""},
+		{"email domain without PII", []string{auth.PermissionRead}, "example", nil, ""},
+		{"name and email without PII", []string{auth.PermissionRead}, "lee example", nil, ""},
+		{"name with PII", []string{auth.PermissionRead, auth.PermissionPII}, "ann", []int64{1}, "ann@example.com"},
+		{"email with PII", []string{auth.PermissionRead, auth.PermissionPII}, "ann@example.com", []int64{1}, "ann@example.com"},
+		{"email domain with PII", []string{auth.PermissionRead, auth.PermissionPII}, "example", []int64{1}, "ann@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/students/search?q="+url.QueryEscape(tt.q), nil)
+			req = req.WithContext(auth.WithPermissions(req.Context(), tt.permissions))
+
+			rec := httptest.NewRecorder()
+			Search(newTestStore(t)).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+			}
+
+			var results []types.StudentSearchResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int64
+
+			for _, result := range results {
+				got = append(got, result.Student.Id)
+			}
+
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Fatalf("search for %q found %v, want %v", tt.q, got, tt.want)
+			}
+
+			if len(results) == 0 {
+				return
+			}
+
+			if results[0].Student.Email != tt.wantEmail {
+				t.Fatalf("search for %q returned the email %s, want %s", tt.q, results[0].Student.Email, tt.wantEmail)
+			}
+
+			if !auth.Allowed(req.Context(), auth.PermissionPII) && results[0].Highlights.Email != tt.wantEmail {
+				t.Fatalf("search for %q highlighted the email as %s, want it masked", tt.q, results[0].Highlights.Email)
+			}
+		})
+	}
+}
@@ -1,72 +1,86 @@
 package student
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
 	// "github.com/AnshSinghSonkhia/golang-students-api/internal/http/handlers/student"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/http/middleware"
 	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
 	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/cursor"
 	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
 	"github.com/go-playground/validator/v10"
 )
 
 // This file contains the handler for the root endpoint of the Golang Students API.
 
-// New(storage storage.Storage) means -> injecting the storage dependency into the handler function. This allows the handler to access the storage layer for database operations.
+// New(storage storage.Storage, validate *validator.Validate) means -> injecting the storage dependency into the handler function. This allows the handler to access the storage layer for database operations.
+// validate is the shared validator of the validation package, which checks the student against the configured rules.
 
-func New(storage storage.Storage) http.HandlerFunc {
+func New(storage storage.Storage, validate *validator.Validate) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		slog.Info("Creating a student")
 		var student types.Student
 
-		err := json.NewDecoder(r.Body).Decode(&student) // decode the request body into a Student struct
+		err := response.Decode(r, &student) // decode the request body into a Student struct, in the format of its Content-Type
+
+		// if the body is in a format that cannot be read, respond with a 415 Unsupported Media Type status code
+		if errors.Is(err, response.ErrUnsupportedMediaType) {
+			response.WriteError(w, r, http.StatusUnsupportedMediaType, err)
+
+			return
+		}
 
 		// if there is an error decoding the request body, check if it is an EOF error
 		if errors.Is(err, io.EOF) {
-			response.WriteJSON(w, http.StatusBadRequest, response.GeneralError(err))
+			response.WriteError(w, r, http.StatusBadRequest, err)
 
 			return // return early to avoid further processing
 		}
 
 		// if there is an error decoding the request body, respond with a 400 Bad Request status code
 		if err != nil {
-			response.WriteJSON(w, http.StatusBadRequest, response.GeneralError(err)) // if there
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there
 
 			return
 		}
 
 		// Request Validataion
 
-		if err := validator.New().Struct(student); err != nil {
+		if err := validate.Struct(student); err != nil {
 			validateErrs := err.(validator.ValidationErrors) // type assert the error to a ValidationErrors type
 
 			// if there are validation errors, respond with a 400 Bad Request status code and the validation errors
-			response.WriteJSON(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+			response.WriteProblem(w, r, response.ValidationProblem(validateErrs))
 
 			return
 		}
 
-		lastId, err := storage.CreateStudent(student.Name, student.Email, student.Age) // call the CreateStudent method on the storage interface to create a new student
-
-		slog.Info("Student created successfully", slog.Int64("id", lastId), slog.String("name", student.Name), slog.String("email", student.Email), slog.Int("age", student.Age))
-
+		lastId, err := storage.CreateStudent(r.Context(), student.Name, student.Email, student.Age) // call the CreateStudent method on the storage interface to create a new student
 		if err != nil {
-			response.WriteJSON(w, http.StatusInternalServerError, response.GeneralError(err)) // if there is an error creating the student, respond with a 500 Internal Server Error status code
+			response.WriteProblem(w, r, storageProblem(err)) // if there is an error creating the student, respond with a 409 Conflict or 500 Internal Server Error status code
 
 			return // return early to avoid further processing
 		}
 
-		response.WriteJSON(w, http.StatusCreated, map[string]int64{"id": lastId}) // return the last inserted ID in the response
+		slog.Info("Student created successfully", slog.Int64("id", lastId)) // only the ID is logged, the name and email are personal data
+
+		response.Write(w, r, http.StatusCreated, map[string]int64{"id": lastId}) // return the last inserted ID in the response
 	}
 }
 
 // GetByID(storage storage.Storage) returns a handler function that retrieves a student by ID.
+// The version of the student is sent as its ETag; a conditional GET with a matching If-None-Match gets 304 Not Modified.
+// With an as_of RFC 3339 timestamp it returns the student as it was at that time.
 
 func GetByID(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -80,44 +94,226 @@ func GetByID(storage storage.Storage) http.HandlerFunc {
 
 			slog.Error("Error converting ID to int64", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue converting the ID
 
-			response.WriteJSON(w, http.StatusBadRequest, response.GeneralError(err)) // if there is an error converting the ID, respond with a 400 Bad Request status code
-			return                                                                   // return early to avoid further processing
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
 		}
 
-		student, err := storage.GetStudentByID(intTd) // call the GetStudentByID method on the storage interface to retrieve the student by ID
+		asOf, err := timeParam(r.URL.Query(), "as_of") // an optional point in time to read the student at
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if the timestamp is invalid, respond with a 400 Bad Request status code
+
+			return
+		}
+
+		var student types.Student
+
+		if asOf.IsZero() {
+			student, err = storage.GetStudentByID(r.Context(), intTd) // call the GetStudentByID method on the storage interface to retrieve the student by ID
+		} else {
+			student, err = storage.GetStudentAsOf(r.Context(), intTd, asOf) // read the version of the student that was current at that time from its history
+		}
 
 		if err != nil {
 
 			slog.Error("Error retrieving student by ID", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue retrieving the student
 
-			response.WriteJSON(w, http.StatusInternalServerError, response.GeneralError(err)) // if there is any other error, respond with a 500 Internal Server Error status code
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found status code if there is no such student, or a 500 Internal Server Error status code for any other error
 
 			return // return early to avoid further processing
 		}
 
-		response.WriteJSON(w, http.StatusOK, student) // if the student is found, respond with a 200 OK status code and the student data
+		w.Header().Set("ETag", studentETag(student)) // the ETag lets clients make conditional requests against this version of the student
+
+		if noneMatch(r.Header.Get("If-None-Match"), student) {
+			w.WriteHeader(http.StatusNotModified) // the client already has this version of the student
+
+			return
+		}
+
+		response.Write(w, r, http.StatusOK, redactStudent(r.Context(), student)) // if the student is found, respond with a 200 OK status code and the student data, its email masked unless the caller may see it
 	}
 }
 
-func GetList(storage storage.Storage) http.HandlerFunc {
+// GetList(storage storage.Storage, cursors *cursor.Codec) returns a handler function that lists students page by page.
+// It supports limit/offset or page/page_size pagination, name, email, min_age and max_age filters and a sort parameter,
+// and lists the students as they were at an earlier time with an as_of RFC 3339 timestamp.
+// Every page also carries signed next_cursor/prev_cursor tokens; passing one back as ?cursor= walks the listing with
+// keyset pagination, which neither skips nor repeats students that are created or deleted in the meantime.
+
+func GetList(storage storage.Storage, cursors *cursor.Codec) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		slog.Info("Retrieving list of students") // log the action of retrieving the list of students
 
-		students, err := storage.GetStudents() // call the GetStudents method on the storage interface to retrieve the list of students
+		listStudents(w, r, storage, cursors, false)
+	}
+}
+
+// Trash(storage storage.Storage, cursors *cursor.Codec) returns a handler function that lists soft-deleted students
+// page by page, with the same parameters as GetList.
+
+func Trash(storage storage.Storage, cursors *cursor.Codec) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		slog.Info("Retrieving list of deleted students") // log the action of retrieving the trash
+
+		listStudents(w, r, storage, cursors, true)
+	}
+}
+
+// listStudents writes the page of live or, with deleted set, soft-deleted students requested by the query string.
+func listStudents(w http.ResponseWriter, r *http.Request, storage storage.Storage, cursors *cursor.Codec, deleted bool) {
+	query := r.URL.Query()
+
+	filter, err := parseListFilter(query) // read pagination, filter and sort options from the query string
+	if err != nil {
+		response.WriteError(w, r, http.StatusBadRequest, err) // if the query parameters are invalid, respond with a 400 Bad Request status code
+
+		return // return early to avoid further processing
+	}
+
+	if !emailQueryAllowed(r.Context(), filter) {
+		middleware.Forbid(w, r, auth.PermissionPII) // respond with a 403 Forbidden status code, the emails of the students are masked for this caller
+
+		return
+	}
+
+	filter.Deleted = deleted
+
+	if token := query.Get("cursor"); token != "" {
+		if query.Has("offset") || query.Has("page") {
+			response.WriteError(w, r, http.StatusBadRequest, errors.New("cursor cannot be combined with offset or page"))
+
+			return
+		}
+
+		if err := applyCursor(cursors, token, &filter); err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if the cursor is invalid or does not match the listing, respond with a 400 Bad Request status code
+
+			return
+		}
+	}
+
+	pageSize := filter.Limit
+	filter.Limit = pageSize + 1 // fetch one extra student to find out whether there is another page
+
+	students, total, err := storage.GetStudents(r.Context(), filter) // call the GetStudents method on the storage interface to retrieve one page of students
+	if err != nil {
+		slog.Error("Error retrieving list of students", slog.Any("error", err)) // log the error if there is an issue retrieving the list
+
+		response.WriteError(w, r, http.StatusInternalServerError, err) // if there is an error, respond with a 500 Internal Server Error status code
+
+		return // return early to avoid further processing
+	}
+
+	filter.Limit = pageSize
+
+	redactStudents(r.Context(), students) // mask the emails unless the caller may see them, callers who cannot never sort by email, so cursors do not hold masked emails
+
+	page, err := newStudentPage(cursors, filter, students, total)
+	if err != nil {
+		slog.Error("Error encoding list cursors", slog.Any("error", err))
+
+		response.WriteError(w, r, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	response.Write(w, r, http.StatusOK, page) // if the list is retrieved successfully, respond with a 200 OK status code and the page of students
+}
+
+// newStudentPage builds the page returned by GetList from students fetched with one more row than the page size.
+func newStudentPage(cursors *cursor.Codec, filter types.StudentFilter, students []types.Student, total int64) (types.StudentPage, error) {
+	backward := filter.Cursor != nil && filter.Cursor.Backward
+	hasMore := len(students) > filter.Limit
+
+	if hasMore {
+		if backward {
+			students = students[1:] // the extra student comes before the page when walking backwards
+		} else {
+			students = students[:filter.Limit]
+		}
+	}
+
+	page := types.StudentPage{
+		Students:   students,
+		Total:      total,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+		TotalPages: int((total + int64(filter.Limit) - 1) / int64(filter.Limit)),
+	}
+
+	if filter.Cursor == nil {
+		page.Page = filter.Offset/filter.Limit + 1
+	}
+
+	if len(students) == 0 {
+		return page, nil
+	}
+
+	hasNext := backward || hasMore
+	hasPrev := (backward && hasMore) || (!backward && (filter.Cursor != nil || filter.Offset > 0))
+
+	var err error
+
+	if hasNext {
+		page.NextCursor, err = encodeCursor(cursors, filter, students[len(students)-1], false)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	if hasPrev {
+		page.PrevCursor, err = encodeCursor(cursors, filter, students[0], true)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	return page, nil
+}
+
+// Search(storage storage.Storage) returns a handler function that runs a full-text search over student names and emails.
+// The q parameter holds the search terms, each of which matches as a prefix; results are ranked best first.
+
+func Search(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		query := r.URL.Query()
+		q := strings.TrimSpace(query.Get("q")) // get the search terms from the query string
+
+		slog.Info("Searching students", slog.Int("terms", len(strings.Fields(q)))) // the terms themselves are names and emails, only their number is logged
+
+		if q == "" {
+			response.WriteError(w, r, http.StatusBadRequest, errors.New("q is required")) // a search without terms is a bad request
+
+			return // return early to avoid further processing
+		}
+
+		limit, err := intParam(query, "limit", defaultPageSize, 1, maxPageSize)
 		if err != nil {
-			slog.Error("Error retrieving list of students", slog.Any("error", err)) // log the error if there is an issue retrieving the list
+			response.WriteError(w, r, http.StatusBadRequest, err) // if the limit is invalid, respond with a 400 Bad Request status code
 
-			response.WriteJSON(w, http.StatusInternalServerError, response.GeneralError(err)) // if there is an error, respond with a 500 Internal Server Error status code
+			return // return early to avoid further processing
+		}
+
+		// callers that may not see emails only search names, matches on masked emails would tell whether an address is in use
+		results, err := storage.SearchStudents(r.Context(), q, limit, auth.Allowed(r.Context(), auth.PermissionPII))
+		if err != nil {
+			slog.Error("Error searching students", slog.Any("error", err)) // log the error if the search fails
+
+			response.WriteError(w, r, http.StatusInternalServerError, err) // if there is an error, respond with a 500 Internal Server Error status code
 
 			return // return early to avoid further processing
 		}
 
-		response.WriteJSON(w, http.StatusOK, students) // if the list is retrieved successfully, respond with a 200 OK status code and the list of students
+		redactSearchResults(r.Context(), results) // mask the emails unless the caller may see them
+
+		response.Write(w, r, http.StatusOK, results) // respond with a 200 OK status code and the ranked results
 	}
 }
 
-func Update(storage storage.Storage) http.HandlerFunc {
+func Update(storage storage.Storage, validate *validator.Validate) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		id := r.PathValue("id") // get the ID from the URL path parameters
@@ -128,39 +324,159 @@ func Update(storage storage.Storage) http.HandlerFunc {
 		if err != nil {
 			slog.Error("Error converting ID to int64", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue converting the ID
 
-			response.WriteJSON(w, http.StatusBadRequest, response.GeneralError(err)) // if there is an error converting the ID, respond with a 400 Bad Request status code
-			return                                                                   // return early to avoid further processing
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
 		}
 
 		var student types.Student
 
-		err = json.NewDecoder(r.Body).Decode(&student) // decode the request body into a Student struct
+		err = response.Decode(r, &student) // decode the request body into a Student struct, in the format of its Content-Type
+		if errors.Is(err, response.ErrUnsupportedMediaType) {
+			response.WriteError(w, r, http.StatusUnsupportedMediaType, err) // if the body is in a format that cannot be read, respond with a 415 Unsupported Media Type status code
+
+			return
+		}
+
 		if err != nil {
-			response.WriteJSON(w, http.StatusBadRequest, response.GeneralError(err)) // if there is an error decoding the request body, respond with a 400 Bad Request status code
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error decoding the request body, respond with a 400 Bad Request status code
 
 			return // return early to avoid further processing
 		}
 
-		if err := validator.New().Struct(student); err != nil { // validate the student struct
+		if err := validate.Struct(student); err != nil { // validate the student struct
 			validateErrs := err.(validator.ValidationErrors) // type assert the error to a ValidationErrors type
 
-			response.WriteJSON(w, http.StatusBadRequest, response.ValidationError(validateErrs)) // if there are validation errors, respond with a 400 Bad Request status code and the validation errors
+			response.WriteProblem(w, r, response.ValidationProblem(validateErrs)) // if there are validation errors, respond with a 400 Bad Request status code and the validation errors
 
 			return // return early to avoid further processing
 		}
 
-		err = storage.UpdateStudent(intTd, student.Name, student.Email, student.Age) // call the UpdateStudent method on the storage interface to update the student
+		version, err := ifMatchVersion(r.Header.Get("If-Match"), func() (types.Student, error) {
+			return storage.GetStudentByID(r.Context(), intTd)
+		}) // only overwrite the version of the student the client has seen, if it says which one that is
+		if err != nil {
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 412 Precondition Failed status code if If-Match cannot match the student
+
+			return // return early to avoid further processing
+		}
+
+		err = storage.UpdateStudent(r.Context(), intTd, student.Name, student.Email, student.Age, version) // call the UpdateStudent method on the storage interface to update the student
 
 		if err != nil {
 			slog.Error("Error updating student", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue updating the student
 
-			response.WriteJSON(w, http.StatusInternalServerError, response.GeneralError(err)) // if there is an error updating the student, respond with a 500 Internal Server Error status code
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found, 409 Conflict, 412 Precondition Failed or 500 Internal Server Error status code depending on the error
+
+			return // return early to avoid further processing
+		}
+
+		if version != 0 {
+			w.Header().Set("ETag", studentETag(types.Student{Version: version + 1})) // the update bumped the version the client sent
+		}
+
+		response.Write(w, r, http.StatusOK, map[string]string{"message": "Student updated successfully"}) // if the student is updated successfully, respond with a 200 OK status code and a success message
+		slog.Info("Student updated successfully", slog.Int64("id", intTd))                                // log the successful update of the student, by ID only
+	}
+}
+
+// Patch(storage storage.Storage, validate *validator.Validate) returns a handler function that updates some fields of a student.
+// The body is a JSON Merge Patch (application/merge-patch+json, or application/json) or a JSON Patch
+// (application/json-patch+json); only the fields it touches are validated and written.
+
+func Patch(storage storage.Storage, validate *validator.Validate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.PathValue("id") // get the ID from the URL path parameters
+
+		slog.Info("Patching student with ID: ", slog.String("id", id)) // log the ID being patched
+
+		intTd, err := strconv.ParseInt(id, 10, 64) // convert the ID from string to int64
+		if err != nil {
+			slog.Error("Error converting ID to int64", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue converting the ID
+
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")) // the content type selects the patch format
+		if err != nil {
+			mediaType = ""
+		}
+
+		if mediaType != mergePatchMediaType && mediaType != jsonPatchMediaType && mediaType != "application/json" {
+			response.WriteError(w, r, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q, use %s or %s", r.Header.Get("Content-Type"), mergePatchMediaType, jsonPatchMediaType))
+
+			return // return early to avoid further processing
+		}
+
+		body, err := io.ReadAll(r.Body) // read the whole patch document
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err)
+
+			return
+		}
+
+		version, err := ifMatchVersion(r.Header.Get("If-Match"), func() (types.Student, error) {
+			return storage.GetStudentByID(r.Context(), intTd)
+		}) // only patch the version of the student the client has seen, if it says which one that is
+		if err != nil {
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 412 Precondition Failed status code if If-Match cannot match the student
 
 			return // return early to avoid further processing
 		}
 
-		response.WriteJSON(w, http.StatusOK, map[string]string{"message": "Student updated successfully"})                                                                       // if the student is updated successfully, respond with a 200 OK status code and a success message
-		slog.Info("Student updated successfully", slog.Int64("id", intTd), slog.String("name", student.Name), slog.String("email", student.Email), slog.Int("age", student.Age)) // log the successful update of the student
+		var patch types.StudentPatch
+		var loadErr error // error loading the student for JSON Patch test operations
+
+		if mediaType == jsonPatchMediaType {
+			patch, err = parseJSONPatch(body, func() (types.Student, error) {
+				current, err := storage.GetStudentByID(r.Context(), intTd) // test operations are checked against the stored student
+				loadErr = err
+
+				return current, err
+			})
+		} else {
+			patch, err = parseMergePatch(body)
+		}
+
+		switch {
+		case errors.Is(err, errPatchUnprocessable):
+			response.WriteError(w, r, http.StatusUnprocessableEntity, err) // the patch is well-formed but cannot be applied to a student
+			return
+		case errors.Is(err, errPatchTestFailed):
+			response.WriteError(w, r, http.StatusConflict, err) // the student does not have the state the patch expects
+			return
+		case loadErr != nil:
+			response.WriteProblem(w, r, storageProblem(loadErr)) // respond with a 404 Not Found status code if there is no such student
+			return
+		case err != nil:
+			response.WriteError(w, r, http.StatusBadRequest, err) // the patch document is malformed
+			return
+		}
+
+		if fields := patchFields(patch); len(fields) > 0 {
+			if err := validate.StructPartial(patch.Apply(types.Student{}), fields...); err != nil { // validate only the fields being patched
+				validateErrs := err.(validator.ValidationErrors) // type assert the error to a ValidationErrors type
+
+				response.WriteProblem(w, r, response.ValidationProblem(validateErrs)) // if there are validation errors, respond with a 400 Bad Request status code and the validation errors
+
+				return // return early to avoid further processing
+			}
+		}
+
+		student, err := storage.PatchStudent(r.Context(), intTd, patch, version) // call the PatchStudent method on the storage interface to write the patched fields
+		if err != nil {
+			slog.Error("Error patching student", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue patching the student
+
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found, 409 Conflict, 412 Precondition Failed or 500 Internal Server Error status code depending on the error
+
+			return // return early to avoid further processing
+		}
+
+		w.Header().Set("ETag", studentETag(student)) // send the new version of the student
+
+		response.Write(w, r, http.StatusOK, redactStudent(r.Context(), student)) // if the student is patched successfully, respond with a 200 OK status code and the updated student
+		slog.Info("Student patched successfully", slog.Int64("id", intTd))       // log the successful patch of the student, by ID only
 	}
 }
 
@@ -175,21 +491,105 @@ func Delete(storage storage.Storage) http.HandlerFunc {
 		if err != nil {
 			slog.Error("Error converting ID to int64", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue converting the ID
 
-			response.WriteJSON(w, http.StatusBadRequest, response.GeneralError(err)) // if there is an error converting the ID, respond with a 400 Bad Request status code
-			return                                                                   // return early to avoid further processing
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
 		}
 
-		err = storage.DeleteStudent(intTd) // call the DeleteStudent method on the storage interface to delete the student by ID
+		version, err := ifMatchVersion(r.Header.Get("If-Match"), func() (types.Student, error) {
+			return storage.GetStudentByID(r.Context(), intTd)
+		}) // only delete the version of the student the client has seen, if it says which one that is
+		if err != nil {
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 412 Precondition Failed status code if If-Match cannot match the student
+
+			return // return early to avoid further processing
+		}
+
+		err = storage.DeleteStudent(r.Context(), intTd, version) // call the DeleteStudent method on the storage interface to delete the student by ID
 
 		if err != nil {
 			slog.Error("Error deleting student", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue deleting the student
 
-			response.WriteJSON(w, http.StatusInternalServerError, response.GeneralError(err)) // if there is an error deleting the student, respond with a 500 Internal Server Error status code
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found, 412 Precondition Failed or 500 Internal Server Error status code depending on the error
+
+			return // return early to avoid further processing
+		}
+
+		response.Write(w, r, http.StatusOK, map[string]string{"message": "Student moved to trash"}) // if the student is deleted successfully, respond with a 200 OK status code and a success message
+		slog.Info("Student deleted successfully", slog.Int64("id", intTd))                          // log the successful deletion of the student
+	}
+}
+
+// Restore(storage storage.Storage) returns a handler function that moves a soft-deleted student out of the trash.
+
+func Restore(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.PathValue("id") // get the ID from the URL path parameters
+
+		slog.Info("Restoring student with ID: ", slog.String("id", id)) // log the ID being restored
+
+		intTd, err := strconv.ParseInt(id, 10, 64) // convert the ID from string to int64
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
+		}
+
+		version, err := ifMatchVersion(r.Header.Get("If-Match"), deletedStudentVersions) // only restore the version of the student the client has seen, if it says which one that is
+		if err != nil {
+			response.WriteProblem(w, r, storageProblem(err))
+
+			return
+		}
+
+		student, err := storage.RestoreStudent(r.Context(), intTd, version) // call the RestoreStudent method on the storage interface to take the student out of the trash
+		if err != nil {
+			slog.Error("Error restoring student", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue restoring the student
+
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found status code if the student is not in the trash
+
+			return // return early to avoid further processing
+		}
+
+		w.Header().Set("ETag", studentETag(student)) // send the new version of the student
+
+		response.Write(w, r, http.StatusOK, redactStudent(r.Context(), student)) // if the student is restored successfully, respond with a 200 OK status code and the student
+		slog.Info("Student restored successfully", slog.Int64("id", intTd))      // log the successful restore of the student
+	}
+}
+
+// Purge(storage storage.Storage) returns a handler function that permanently deletes a student from the trash.
+// Live students have to be deleted before they can be purged.
+
+func Purge(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.PathValue("id") // get the ID from the URL path parameters
+
+		slog.Info("Purging student with ID: ", slog.String("id", id)) // log the ID being purged
+
+		intTd, err := strconv.ParseInt(id, 10, 64) // convert the ID from string to int64
+		if err != nil {
+			response.WriteError(w, r, http.StatusBadRequest, err) // if there is an error converting the ID, respond with a 400 Bad Request status code
+			return                                                // return early to avoid further processing
+		}
+
+		version, err := ifMatchVersion(r.Header.Get("If-Match"), deletedStudentVersions) // only purge the version of the student the client has seen, if it says which one that is
+		if err != nil {
+			response.WriteProblem(w, r, storageProblem(err))
+
+			return
+		}
+
+		err = storage.PurgeStudent(r.Context(), intTd, version) // call the PurgeStudent method on the storage interface to delete the student for good
+		if err != nil {
+			slog.Error("Error purging student", slog.String("id", id), slog.Any("error", err)) // log the error if there is an issue purging the student
+
+			response.WriteProblem(w, r, storageProblem(err)) // respond with a 404 Not Found status code if the student is not in the trash
 
 			return // return early to avoid further processing
 		}
 
-		response.WriteJSON(w, http.StatusOK, map[string]string{"message": "Student deleted successfully"}) // if the student is deleted successfully, respond with a 200 OK status code and a success message
-		slog.Info("Student deleted successfully", slog.Int64("id", intTd))                                 // log the successful deletion of the student
+		response.Write(w, r, http.StatusOK, map[string]string{"message": "Student purged successfully"}) // if the student is purged successfully, respond with a 200 OK status code and a success message
+		slog.Info("Student purged successfully", slog.Int64("id", intTd))                                // log the successful purge of the student
 	}
 }
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+)
+
+// ActorHeader names the user on whose behalf a request is made, as recorded in the audit log, when authentication
+// is disabled.
+const ActorHeader = "X-Actor"
+
+// AnonymousActor is recorded in the audit log for requests that do not name an actor.
+const AnonymousActor = "anonymous"
+
+// Audit attaches the actor and the request ID of every request to its context, so that the storage records
+// them with the changes the request makes. The actor is the subject of the principal of authenticated requests,
+// which cannot be overridden with X-Actor. It must run inside RequestID and Authenticate.
+func Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(ActorHeader)
+		if actor == "" {
+			actor = AnonymousActor
+		}
+
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			actor = principal.Subject
+		}
+
+		ctx := storage.WithAuditInfo(r.Context(), storage.AuditInfo{
+			Actor:     actor,
+			RequestID: RequestIDFromContext(r.Context()),
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
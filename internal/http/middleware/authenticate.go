@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// Authenticate answers requests without valid credentials with 401 Unauthorized, and attaches the principal of
+// the others to their context, where auth.PrincipalFromContext finds it. It must run inside RequestID.
+func Authenticate(next http.Handler, authenticator *auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			if !errors.Is(err, auth.ErrUnauthenticated) {
+				slog.Error("Failed to authenticate request", slog.String("request_id", RequestIDFromContext(r.Context())), slog.String("error", err.Error()))
+				response.WriteError(w, r, http.StatusInternalServerError, errors.New("credentials could not be checked"))
+
+				return
+			}
+
+			slog.Warn("Rejected unauthenticated request", slog.String("request_id", RequestIDFromContext(r.Context())), slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.String("reason", err.Error()))
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="golang-students-api"`)
+			response.WriteError(w, r, http.StatusUnauthorized, err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
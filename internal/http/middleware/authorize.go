@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// Authorize attaches the permissions the policy grants to the principal of every request to its context, where
+// Require and auth.Allowed check them. Requests without a principal, which only get through with authentication
+// disabled, are granted every permission. It must run inside Authenticate.
+func Authorize(next http.Handler, policy *auth.Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		permissions := auth.Permissions
+
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			permissions = policy.Permissions(principal)
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPermissions(r.Context(), permissions)))
+	})
+}
+
+// Require answers requests that were not granted a permission with 403 Forbidden before their handler runs.
+// It must run inside Authorize.
+func Require(permission string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.Allowed(r.Context(), permission) {
+			Forbid(w, r, permission)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Forbid logs the denial of a permission to the principal of a request and answers it with 403 Forbidden.
+func Forbid(w http.ResponseWriter, r *http.Request, permission string) {
+	LogDenial(r, permission)
+
+	response.WriteError(w, r, http.StatusForbidden, fmt.Errorf("the %s permission is required", permission))
+}
+
+// LogDenial logs the denial of a permission to the principal of a request, for requests that are only partly
+// denied, like bulk operations.
+func LogDenial(r *http.Request, permission string) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	slog.Warn("Denied request",
+		slog.String("request_id", RequestIDFromContext(r.Context())),
+		slog.String("subject", principal.Subject),
+		slog.Any("roles", principal.Roles),
+		slog.String("permission", permission),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path))
+}
@@ -0,0 +1,47 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/http/middleware"
+)
+
+func TestRequire(t *testing.T) {
+	policy, err := auth.NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := middleware.Authorize(middleware.Require(auth.PermissionDelete, ok), policy)
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      int
+	}{
+		{"admin", &auth.Principal{Subject: "ann", Roles: []string{"admin"}}, http.StatusNoContent},
+		{"registrar", &auth.Principal{Subject: "bob", Roles: []string{"registrar"}}, http.StatusForbidden},
+		{"no roles", &auth.Principal{Subject: "eve"}, http.StatusForbidden},
+		{"authentication disabled", nil, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/students/1", nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tt.principal))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/auth"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// IdempotencyKeyHeader carries a key chosen by the client for a request it may retry. Retries with the same key get
+// the response to the first request instead of being carried out again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set to true on responses replayed to a retry.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodyBytes  = 1 << 20
+
+	// idempotencyClaimTimeout is how long a key stays claimed by a request that never completes, e.g. because the
+	// server stopped while running it. Retries are turned away with 409 Conflict until then.
+	idempotencyClaimTimeout = time.Minute
+)
+
+// replayedHeaders lists the response headers stored along with the status and body of a response.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Vary"}
+
+// Idempotency makes requests with an Idempotency-Key header safe to retry. The response to the first request
+// with a key is stored for ttl and replayed to retries of the request, which must have the same method, path,
+// Content-Type and body; reusing the key for another request is answered with 422 Unprocessable Entity.
+// Responses with a 5xx status are not stored, so that the request can be retried. Requests without the header
+// are passed through.
+func Idempotency(next http.Handler, store storage.Storage, ttl time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if !validIdempotencyKey(key) {
+			response.WriteError(w, r, http.StatusBadRequest, fmt.Errorf("header %s must be 1 to %d printable ASCII characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				response.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("request bodies with an %s are limited to %d bytes", IdempotencyKeyHeader, tooLarge.Limit))
+
+				return
+			}
+
+			response.WriteError(w, r, http.StatusBadRequest, err)
+
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body)) // the handler reads the body again
+
+		// Keys are chosen by clients, each authenticated client has keys of its own
+		storedKey := key
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			storedKey = principal.Method + ":" + principal.Subject + ":" + key
+		}
+
+		now := time.Now()
+
+		claim := types.IdempotencyRecord{
+			Key:         storedKey,
+			Fingerprint: fingerprint(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(idempotencyClaimTimeout),
+		}
+
+		existing, claimed, err := store.ClaimIdempotencyKey(r.Context(), claim)
+		if err != nil {
+			response.WriteError(w, r, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		if !claimed {
+			replay(w, r, key, existing, claim.Fingerprint)
+
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// The outcome is stored even when the client went away in the meantime, its retry is waiting for it
+		ctx := context.WithoutCancel(r.Context())
+		completed := false
+
+		defer func() {
+			if completed {
+				return
+			}
+
+			// Requests that failed or panicked did not happen as far as retries are concerned
+			if err := store.ReleaseIdempotencyKey(ctx, storedKey); err != nil {
+				slog.Error("Failed to release idempotency key", slog.String("key", key), slog.Any("error", err))
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		claim.Status = recorder.status
+		claim.Header = recorder.replayedHeader()
+		claim.Body = recorder.body.Bytes()
+		claim.ExpiresAt = time.Now().Add(ttl)
+
+		// The request was carried out either way, a failure keeps the key claimed until the claim times out
+		if err := store.CompleteIdempotencyKey(ctx, claim); err != nil {
+			slog.Error("Failed to store idempotent response", slog.String("key", key), slog.Any("error", err))
+		}
+
+		completed = true
+	})
+}
+
+// replay answers a request whose key is already claimed, with the stored response if it is a retry of the request
+// that claimed the key.
+func replay(w http.ResponseWriter, r *http.Request, key string, stored types.IdempotencyRecord, fingerprint string) {
+	if stored.Fingerprint != fingerprint {
+		response.WriteProblem(w, r, response.Problem{
+			Status: http.StatusUnprocessableEntity,
+			Code:   response.CodeIdempotencyKeyReused,
+			Detail: fmt.Sprintf("%s %q was already used for a different request", IdempotencyKeyHeader, key),
+		})
+
+		return
+	}
+
+	if stored.Status == 0 {
+		retryAfter := max(int(time.Until(stored.ExpiresAt).Seconds()), 1)
+
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		response.WriteProblem(w, r, response.Problem{
+			Status: http.StatusConflict,
+			Code:   response.CodeRequestInProgress,
+			Detail: fmt.Sprintf("the request with %s %q is still being processed", IdempotencyKeyHeader, key),
+		})
+
+		return
+	}
+
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+// validIdempotencyKey reports whether a key is short and made of printable ASCII characters, so that it can be
+// logged and stored as is.
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for _, c := range key {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fingerprint hashes what makes a request the same as the one it retries: its method, path, content type and
+// body. The Accept header is left out, retries get the response in the format of the first request.
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+
+	for _, part := range []string{r.Method, r.URL.Path, r.Header.Get("Content-Type")} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes a response through to the client while keeping its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true // an implicit 200 OK
+
+	rec.body.Write(p)
+
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap gives http.ResponseController access to the underlying response writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// replayedHeader returns the headers of the response that are replayed to retries.
+func (rec *responseRecorder) replayedHeader() map[string][]string {
+	header := map[string][]string{}
+
+	for _, name := range replayedHeaders {
+		if values := rec.Header().Values(name); len(values) > 0 {
+			header[name] = values
+		}
+	}
+
+	return header
+}
@@ -0,0 +1,90 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/http/middleware"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/memory"
+)
+
+func TestIdempotency(t *testing.T) {
+	var calls atomic.Int64
+
+	handler := middleware.Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.RawQuery, "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Not-Replayed", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":` + strconv.FormatInt(calls.Add(1), 10) + `}`))
+	}), memory.New(), time.Hour)
+
+	send := func(key, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	first := send("a", "/api/students", `{"name":"Ann"}`)
+	if first.Code != http.StatusCreated || first.Body.String() != `{"id":1}` || first.Header().Get(middleware.IdempotentReplayedHeader) != "" {
+		t.Fatalf("first request got %d %q, want 201 {\"id\":1}", first.Code, first.Body)
+	}
+
+	retry := send("a", "/api/students", `{"name":"Ann"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != `{"id":1}` || retry.Header().Get(middleware.IdempotentReplayedHeader) != "true" {
+		t.Fatalf("retry got %d %q, want the first response replayed", retry.Code, retry.Body)
+	}
+
+	if got := retry.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("replayed Content-Type is %q, want application/json", got)
+	}
+
+	if got := retry.Header().Get("X-Not-Replayed"); got != "" {
+		t.Fatalf("replayed X-Not-Replayed is %q, want it left out", got)
+	}
+
+	if reused := send("a", "/api/students", `{"name":"Bob"}`); reused.Code != http.StatusUnprocessableEntity || !strings.Contains(reused.Body.String(), "idempotency_key_reused") {
+		t.Fatalf("reusing the key for another body got %d %q, want 422 idempotency_key_reused", reused.Code, reused.Body)
+	}
+
+	if other := send("b", "/api/students", `{"name":"Ann"}`); other.Body.String() != `{"id":2}` {
+		t.Fatalf("request with another key got %q, want it carried out", other.Body)
+	}
+
+	if plain := send("", "/api/students", `{"name":"Ann"}`); plain.Body.String() != `{"id":3}` {
+		t.Fatalf("request without a key got %q, want it carried out", plain.Body)
+	}
+
+	// Failed requests are not stored, their retries are carried out
+	if failed := send("c", "/api/students?fail", ""); failed.Code != http.StatusInternalServerError {
+		t.Fatalf("failing request got %d, want 500", failed.Code)
+	}
+
+	if retried := send("c", "/api/students?fail", ""); retried.Code != http.StatusInternalServerError || retried.Header().Get(middleware.IdempotentReplayedHeader) != "" {
+		t.Fatalf("retry of a failed request got %d, want it carried out again", retried.Code)
+	}
+
+	if invalid := send(strings.Repeat("k", 256), "/api/students", ""); invalid.Code != http.StatusBadRequest {
+		t.Fatalf("overlong key got %d, want 400", invalid.Code)
+	}
+
+	if calls.Load() != 3 {
+		t.Fatalf("handler created %d students, want 3", calls.Load())
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+)
+
+// Negotiate answers requests whose Accept header rules out every format of the response package with
+// 406 Not Acceptable before their handler runs, so that writes are not carried out for clients that cannot read
+// the outcome. Requests for the exempt paths, whose handlers pick their own formats, are let through.
+func Negotiate(next http.Handler, exempt ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := response.Negotiate(r.Header.Get("Accept")); !ok && !slices.Contains(exempt, r.URL.Path) {
+			response.WriteError(w, r, http.StatusNotAcceptable, fmt.Errorf("none of the accepted media types can be produced, use one of %s", strings.Join(response.MediaTypes(), ", "))) // written in the default format
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+// Package middleware holds the HTTP middleware wrapped around the API router.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader carries the ID of a request. A valid ID sent by the client (e.g. a proxy) is kept,
+// otherwise one is generated; either way it is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID assigns every request an ID, available to handlers through RequestIDFromContext.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext returns the ID of the request a context belongs to, or an empty string outside of requests.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// validRequestID reports whether a client supplied request ID is short and made of safe characters only,
+// so that it can be logged and stored as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id) // crypto/rand.Read never fails
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,121 @@
+// Package redact masks the personal data of students: in API responses, for callers that may not see it, and in
+// log records, through a slog handler.
+package redact
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask replaces the values of log attributes that hold personal data other than email addresses.
+const Mask = "[REDACTED]"
+
+// emailPattern matches the email addresses found in free text, such as log messages and errors.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+
+// Email masks the local part of an email address but its first character, e.g. j***@example.com. Values without
+// an @ are masked entirely.
+func Email(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return "***"
+	}
+
+	first, size := utf8.DecodeRuneInString(local)
+	if size == 0 {
+		return "***@" + domain
+	}
+
+	return string(first) + "***@" + domain
+}
+
+// Text masks every email address found in s.
+func Text(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, Email)
+}
+
+// Handler is a slog handler that masks personal data before passing records on: the values of the attributes
+// named by its keys, and the email addresses found in messages and in every other attribute.
+type Handler struct {
+	next slog.Handler
+	keys map[string]bool
+}
+
+// NewHandler returns a handler that passes the records it masks on to next. Attributes named like one of keys, at
+// any level of groups, are masked whatever their value.
+func NewHandler(next slog.Handler, keys []string) *Handler {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+
+	return &Handler{next: next, keys: set}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	masked := slog.NewRecord(record.Time, record.Level, Text(record.Message), record.PC)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		masked.AddAttrs(h.attr(attr))
+
+		return true
+	})
+
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		masked[i] = h.attr(attr)
+	}
+
+	return &Handler{next: h.next.WithAttrs(masked), keys: h.keys}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), keys: h.keys}
+}
+
+// attr returns an attribute with its personal data masked.
+func (h *Handler) attr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+
+	switch {
+	case value.Kind() == slog.KindGroup:
+		group := value.Group()
+		masked := make([]any, len(group))
+
+		for i, member := range group {
+			masked[i] = h.attr(member)
+		}
+
+		return slog.Group(attr.Key, masked...)
+	case h.keys[attr.Key]:
+		if text := value.String(); strings.Contains(text, "@") {
+			return slog.String(attr.Key, Email(text))
+		}
+
+		return slog.String(attr.Key, Mask)
+	case value.Kind() == slog.KindString:
+		return slog.String(attr.Key, Text(value.String()))
+	case value.Kind() == slog.KindAny:
+		// errors and other values are only replaced by their text when it holds an email address, so that the
+		// handler below still gets them as they are otherwise
+		text := fmt.Sprint(value.Any())
+
+		if masked := Text(text); masked != text {
+			return slog.String(attr.Key, masked)
+		}
+	}
+
+	return slog.Attr{Key: attr.Key, Value: value}
+}
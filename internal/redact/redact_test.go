@@ -0,0 +1,62 @@
+package redact_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/redact"
+)
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"john@example.com", "j***@example.com"},
+		{"é.leroy@école.fr", "é***@école.fr"},
+		{"@example.com", "***@example.com"},
+		{"not an email", "***"},
+		{"", "***"},
+	}
+
+	for _, tt := range tests {
+		if got := redact.Email(tt.email); got != tt.want {
+			t.Errorf("Email(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+
+	if got, want := redact.Text("email ann.lee+x@mail.example.org is already used by student 1"), "email a***@mail.example.org is already used by student 1"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := slog.New(redact.NewHandler(slog.NewTextHandler(&buf, nil), []string{"name", "email"}))
+
+	logger.With(slog.String("email", "ann@example.com")).WithGroup("student").Info("Created ann@example.com",
+		slog.String("name", "Ann Lee"),
+		slog.Int("age", 20),
+		slog.Any("error", fmt.Errorf("saving: %w", errors.New("bob@example.com is taken"))),
+		slog.Any("roles", []string{"teacher"}),
+		slog.Group("previous", slog.String("email", "carol@example.com")))
+
+	got := buf.String()
+
+	for _, leak := range []string{"ann@", "Ann Lee", "bob@", "carol@"} {
+		if strings.Contains(got, leak) {
+			t.Errorf("log record %q leaks %q", got, leak)
+		}
+	}
+
+	for _, kept := range []string{`msg="Created a***@example.com"`, "email=a***@example.com", "student.name=[REDACTED]", "student.age=20", `student.error="saving: b***@example.com is taken"`, "student.roles=[teacher]", "student.previous.email=c***@example.com"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("log record %q does not contain %q", got, kept)
+		}
+	}
+}
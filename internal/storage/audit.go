@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// SystemActor is the actor recorded for changes made without an AuditInfo in their context, e.g. from the command line.
+const SystemActor = "system"
+
+// AuditInfo identifies who makes the changes done with a context, for the audit log.
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo returns a context whose changes are recorded in the audit log under the given actor and request ID.
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+// AuditInfoFromContext returns the audit information of a context, with SystemActor as the actor if it has none.
+func AuditInfoFromContext(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+
+	if info.Actor == "" {
+		info.Actor = SystemActor
+	}
+
+	return info
+}
+
+// NewAuditEntry builds the audit entry of a change made with ctx at the given time. Backends store it in the same
+// transaction as the change itself.
+func NewAuditEntry(ctx context.Context, action string, studentID int64, before, after *types.Student, at time.Time) types.AuditEntry {
+	info := AuditInfoFromContext(ctx)
+
+	return types.AuditEntry{
+		StudentID: studentID,
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		Timestamp: at.UTC(),
+		Before:    before,
+		After:     after,
+	}
+}
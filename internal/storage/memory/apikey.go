@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+func (m *Memory) CreateAPIKey(ctx context.Context, key types.APIKey) (types.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return types.APIKey{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.apiKeys {
+		if existing.Name == key.Name || existing.Hash == key.Hash {
+			return types.APIKey{}, fmt.Errorf("%w: API key %q already exists", storage.ErrConflict, key.Name)
+		}
+	}
+
+	key.ID = int64(len(m.apiKeys)) + 1 // keys are never deleted, so IDs are the position plus one
+	key.Roles = append([]string{}, key.Roles...)
+	key.RevokedAt = nil
+
+	m.apiKeys = append(m.apiKeys, key)
+
+	return key, nil
+}
+
+func (m *Memory) GetAPIKeyByHash(ctx context.Context, hash string) (types.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return types.APIKey{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.apiKeys {
+		if key.Hash == hash {
+			return cloneAPIKey(key), nil
+		}
+	}
+
+	return types.APIKey{}, fmt.Errorf("%w: no such API key", storage.ErrNotFound)
+}
+
+func (m *Memory) GetAPIKeys(ctx context.Context) ([]types.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]types.APIKey, len(m.apiKeys))
+
+	for i, key := range m.apiKeys {
+		keys[i] = cloneAPIKey(key)
+	}
+
+	return keys, nil
+}
+
+func (m *Memory) RevokeAPIKey(ctx context.Context, name string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, key := range m.apiKeys {
+		if key.Name == name && key.RevokedAt == nil {
+			m.apiKeys[i].RevokedAt = &at
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: no API key named %q that is not revoked", storage.ErrNotFound, name)
+}
+
+func (m *Memory) SetAPIKeyRoles(ctx context.Context, name string, roles []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, key := range m.apiKeys {
+		if key.Name == name {
+			m.apiKeys[i].Roles = append([]string{}, roles...)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: no API key named %q", storage.ErrNotFound, name)
+}
+
+// cloneAPIKey copies the roles of a key, so that stored keys are not shared with callers.
+func cloneAPIKey(key types.APIKey) types.APIKey {
+	key.Roles = append([]string{}, key.Roles...)
+
+	return key
+}
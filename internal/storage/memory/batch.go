@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// ApplyBatch runs a batch of writes under one lock. Operations that fail change nothing, so only atomic batches
+// have to be undone, which is done by restoring a copy of the storage taken before the batch.
+func (m *Memory) ApplyBatch(ctx context.Context, operations []types.BatchOperation, atomic bool) ([]storage.BatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var restore func()
+	if atomic {
+		restore = m.snapshot()
+	}
+
+	results := make([]storage.BatchResult, len(operations))
+
+	for i, operation := range operations {
+		student, err := m.apply(ctx, operation)
+		if err != nil {
+			if atomic {
+				restore()
+
+				return storage.AbortBatch(results, i, err), nil
+			}
+
+			results[i].Err = err
+
+			continue
+		}
+
+		results[i] = storage.BatchResult{ID: student.Id, Version: student.Version}
+	}
+
+	return results, nil
+}
+
+// apply runs one operation of a batch and returns the student afterwards. The caller must hold the lock.
+func (m *Memory) apply(ctx context.Context, operation types.BatchOperation) (types.Student, error) {
+	student := operation.Student
+
+	switch operation.Op {
+	case types.BatchCreate:
+		return m.create(ctx, student.Name, student.Email, student.Age)
+	case types.BatchUpdate:
+		return m.update(ctx, student.Id, student.Name, student.Email, student.Age, student.Version)
+	case types.BatchDelete:
+		return m.delete(ctx, student.Id, student.Version)
+	default:
+		return types.Student{}, fmt.Errorf("unsupported batch operation %q", operation.Op)
+	}
+}
+
+// snapshot copies the state of the storage and returns a function that puts it back. The caller must hold the lock.
+func (m *Memory) snapshot() func() {
+	students := maps.Clone(m.students)
+	lastID := m.lastID
+	audit := len(m.audit) // The audit log is only appended to
+
+	history := make(map[int64][]studentVersion, len(m.history))
+	for id, versions := range m.history {
+		history[id] = slices.Clone(versions) // Versions are closed in place, so they are copied
+	}
+
+	return func() {
+		m.students = students
+		m.lastID = lastID
+		m.audit = m.audit[:audit]
+		m.history = history
+	}
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// studentVersion is one version of a student, valid from validFrom up to but excluding validTo.
+// The current version has a zero validTo.
+type studentVersion struct {
+	student   types.Student
+	validFrom time.Time
+	validTo   time.Time
+}
+
+// validAt reports whether the version was the current one at the given time.
+func (v studentVersion) validAt(at time.Time) bool {
+	return !v.validFrom.After(at) && (v.validTo.IsZero() || v.validTo.After(at))
+}
+
+// recordVersion closes the current version of a student at the given time and, unless the student was purged and
+// after is nil, opens the version after the change. The caller must hold the lock.
+func (m *Memory) recordVersion(id int64, after *types.Student, at time.Time) {
+	versions := m.history[id]
+
+	if n := len(versions); n > 0 && versions[n-1].validTo.IsZero() {
+		versions[n-1].validTo = at
+	}
+
+	if after != nil {
+		versions = append(versions, studentVersion{student: *after, validFrom: at})
+	}
+
+	m.history[id] = versions
+}
+
+// studentsAsOf returns the students as they were at the given time, including those in the trash at that time.
+// The caller must hold the lock.
+func (m *Memory) studentsAsOf(at time.Time) map[int64]types.Student {
+	students := map[int64]types.Student{}
+
+	for id, versions := range m.history {
+		for _, version := range versions {
+			if version.validAt(at) {
+				students[id] = version.student
+				break
+			}
+		}
+	}
+
+	return students
+}
+
+func (m *Memory) GetStudentAsOf(ctx context.Context, id int64, at time.Time) (types.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Student{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, version := range m.history[id] {
+		if version.validAt(at) && version.student.DeletedAt == nil {
+			return version.student, nil
+		}
+	}
+
+	return types.Student{}, fmt.Errorf("%w: no student with ID %d at %s", storage.ErrNotFound, id, at.UTC().Format(time.RFC3339))
+}
+
+func (m *Memory) GetStudentVersion(ctx context.Context, id int64, version int64) (types.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Student{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, v := range m.history[id] {
+		if v.student.Version == version {
+			return v.student, nil
+		}
+	}
+
+	return types.Student{}, fmt.Errorf("%w: no version %d of student with ID %d", storage.ErrNotFound, version, id)
+}
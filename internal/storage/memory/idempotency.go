@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+func (m *Memory) ClaimIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) (types.IdempotencyRecord, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return types.IdempotencyRecord{}, false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.idempotency[record.Key]; ok && stored.ExpiresAt.After(record.CreatedAt) {
+		return cloneRecord(stored), false, nil
+	}
+
+	record.Status, record.Header, record.Body = 0, nil, nil
+
+	m.idempotency[record.Key] = record
+
+	return record, true, nil
+}
+
+func (m *Memory) CompleteIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.idempotency[record.Key]
+	if !ok || stored.Status != 0 || stored.Fingerprint != record.Fingerprint {
+		return fmt.Errorf("%w: idempotency key %q is not claimed by a request being processed", storage.ErrNotFound, record.Key)
+	}
+
+	stored.Status = record.Status
+	stored.Header = record.Header
+	stored.Body = record.Body
+	stored.ExpiresAt = record.ExpiresAt
+
+	m.idempotency[record.Key] = cloneRecord(stored) // The caller keeps its copy of the response
+
+	return nil
+}
+
+func (m *Memory) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.idempotency[key]; ok && stored.Status == 0 {
+		delete(m.idempotency, key)
+	}
+
+	return nil
+}
+
+func (m *Memory) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+
+	for key, stored := range m.idempotency {
+		if !stored.ExpiresAt.After(before) {
+			delete(m.idempotency, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// cloneRecord copies the header and body of a record, so that stored records are not shared with callers like
+// the rows of a database are not.
+func cloneRecord(record types.IdempotencyRecord) types.IdempotencyRecord {
+	if record.Header != nil {
+		header := maps.Clone(record.Header)
+
+		for name, values := range header {
+			header[name] = slices.Clone(values)
+		}
+
+		record.Header = header
+	}
+
+	record.Body = bytes.Clone(record.Body)
+
+	return record
+}
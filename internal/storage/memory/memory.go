@@ -0,0 +1,576 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/textsearch"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// Memory is a storage.Storage that keeps students in memory. It behaves like the SQL backends
+// (auto-increment IDs that are never reused, not-found errors, ordering) and is safe for concurrent use,
+// which makes it suitable for tests and throwaway demo instances. Everything is lost when the process exits.
+// Operations never block, so contexts are only checked for cancellation before starting.
+type Memory struct {
+	mu       sync.RWMutex
+	students map[int64]types.Student
+	lastID   int64
+	audit    []types.AuditEntry         // oldest first, IDs are the position plus one
+	history  map[int64][]studentVersion // versions of each student, oldest first
+
+	idempotency map[string]types.IdempotencyRecord // by key
+	apiKeys     []types.APIKey                     // oldest first, IDs are the position plus one
+}
+
+// New creates an empty in-memory storage.
+func New() *Memory {
+	return &Memory{
+		students: map[int64]types.Student{},
+		history:  map[int64][]studentVersion{},
+
+		idempotency: map[string]types.IdempotencyRecord{},
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) CreateStudent(ctx context.Context, name string, email string, age int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created, err := m.create(ctx, name, email, age)
+
+	return created.Id, err
+}
+
+// create adds a new student. The caller must hold the lock.
+func (m *Memory) create(ctx context.Context, name string, email string, age int) (types.Student, error) {
+	if err := m.claimEmail(0, email); err != nil {
+		return types.Student{}, err
+	}
+
+	m.lastID++ // IDs keep increasing even after deletions, like SQLite's AUTOINCREMENT
+
+	created := types.Student{Id: m.lastID, Name: name, Email: email, Age: age, Version: 1}
+
+	m.students[m.lastID] = created
+	m.record(ctx, types.AuditCreate, m.lastID, nil, &created, time.Now())
+
+	return created, nil
+}
+
+// claimEmail checks that no live student other than the one with the given ID uses an email, compared by
+// storage.EmailKey. The caller must hold the lock.
+func (m *Memory) claimEmail(id int64, email string) error {
+	key := storage.EmailKey(email)
+
+	for _, student := range m.students {
+		if student.Id != id && student.DeletedAt == nil && storage.EmailKey(student.Email) == key {
+			return &storage.DuplicateEmailError{Email: email, ID: student.Id}
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) GetStudentByID(ctx context.Context, id int64) (types.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Student{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	student, ok := m.students[id]
+	if !ok || student.DeletedAt != nil {
+		return types.Student{}, fmt.Errorf("%w: no student with ID %d", storage.ErrNotFound, id)
+	}
+
+	return student, nil
+}
+
+// matches reports whether a student passes the filters of a listing.
+func matches(student types.Student, filter types.StudentFilter) bool {
+	if (student.DeletedAt != nil) != filter.Deleted {
+		return false // Listings show either live students or the trash
+	}
+
+	if filter.Name != "" && !containsFold(student.Name, filter.Name) {
+		return false
+	}
+
+	if filter.Email != "" && !containsFold(student.Email, filter.Email) {
+		return false
+	}
+
+	if filter.MinAge != nil && student.Age < *filter.MinAge {
+		return false
+	}
+
+	if filter.MaxAge != nil && student.Age > *filter.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+// containsFold reports whether substr is within s, ignoring case.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// compareBy returns the ordering of a listing sorted by the given field, with the ID as a tie-breaker.
+func compareBy(sort string) (func(a, b types.Student) int, error) {
+	var compareField func(a, b types.Student) int
+
+	switch sort {
+	case "", types.SortByID:
+		compareField = func(a, b types.Student) int { return 0 }
+	case types.SortByName:
+		compareField = func(a, b types.Student) int { return strings.Compare(a.Name, b.Name) }
+	case types.SortByEmail:
+		compareField = func(a, b types.Student) int { return strings.Compare(a.Email, b.Email) }
+	case types.SortByAge:
+		compareField = func(a, b types.Student) int { return cmp.Compare(a.Age, b.Age) }
+	default:
+		return nil, fmt.Errorf("unsupported sort field %q", sort)
+	}
+
+	return func(a, b types.Student) int {
+		if c := compareField(a, b); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Id, b.Id)
+	}, nil
+}
+
+// cursorStudent turns a cursor back into a student that can be compared with the ordering of the listing.
+func cursorStudent(sort string, cursor *types.StudentCursor) (types.Student, error) {
+	student := types.Student{Id: cursor.ID}
+
+	var ok bool
+
+	switch sort {
+	case "", types.SortByID:
+		ok = true
+	case types.SortByName:
+		student.Name, ok = cursor.Value.(string)
+	case types.SortByEmail:
+		student.Email, ok = cursor.Value.(string)
+	case types.SortByAge:
+		student.Age, ok = cursor.Value.(int)
+	}
+
+	if !ok {
+		return student, fmt.Errorf("cursor value %v does not match sort field %q", cursor.Value, sort)
+	}
+
+	return student, nil
+}
+
+func (m *Memory) GetStudents(ctx context.Context, filter types.StudentFilter) ([]types.Student, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	compare, err := compareBy(filter.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Desc {
+		ascending := compare
+		compare = func(a, b types.Student) int { return ascending(b, a) }
+	}
+
+	m.mu.RLock()
+
+	current := m.students
+	if !filter.AsOf.IsZero() {
+		current = m.studentsAsOf(filter.AsOf) // List the students as they were at that time
+	}
+
+	students := []types.Student{}
+
+	for _, student := range current {
+		if matches(student, filter) {
+			students = append(students, student)
+		}
+	}
+
+	m.mu.RUnlock()
+
+	slices.SortFunc(students, compare)
+
+	total := int64(len(students))
+
+	if filter.Cursor != nil {
+		position, err := cursorStudent(filter.Sort, filter.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// The students around the cursor are found by binary search, the cursor itself may have been deleted
+		at, found := slices.BinarySearchFunc(students, position, compare)
+
+		if filter.Cursor.Backward {
+			students = students[:at]
+
+			if filter.Limit > 0 && len(students) > filter.Limit {
+				students = students[len(students)-filter.Limit:]
+			}
+
+			return students, total, nil
+		}
+
+		if found {
+			at++
+		}
+
+		students = students[at:]
+	} else {
+		students = students[min(filter.Offset, len(students)):]
+	}
+
+	if filter.Limit > 0 && len(students) > filter.Limit {
+		students = students[:filter.Limit]
+	}
+
+	return students, total, nil
+}
+
+// StreamStudents calls fn with every student matching the filter. The students are in memory already, so they are
+// listed first and fn is called without holding the lock.
+func (m *Memory) StreamStudents(ctx context.Context, filter types.StudentFilter, fn func(types.Student) error) error {
+	filter.Limit, filter.Offset, filter.Cursor = 0, 0, nil
+
+	students, _, err := m.GetStudents(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, student := range students {
+		if err := fn(student); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) SearchStudents(ctx context.Context, query string, limit int, withEmails bool) ([]types.StudentSearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []types.StudentSearchResult{}, nil
+	}
+
+	matcher := textsearch.NewMatcher(terms)
+	results := []types.StudentSearchResult{}
+
+	m.mu.RLock()
+
+	for _, student := range m.students {
+		if student.DeletedAt == nil && matchesTerms(student, terms, withEmails) {
+			results = append(results, matcher.Result(student, withEmails))
+		}
+
+		if len(results) == 2*limit { // only the best limit results are kept, so that a search never holds every student
+			textsearch.Sort(results)
+			results = results[:limit]
+		}
+	}
+
+	m.mu.RUnlock()
+
+	textsearch.Sort(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// matchesTerms reports whether every search term appears in the name, or with withEmails the email, of a student,
+// like the LIKE based search of the SQL backends.
+func matchesTerms(student types.Student, terms []string, withEmails bool) bool {
+	for _, term := range terms {
+		if !containsFold(student.Name, term) && (!withEmails || !containsFold(student.Email, term)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookup returns the live (or, with deleted set, soft-deleted) student with the given ID, checking that it is
+// at the expected version unless that is 0. The caller must hold the lock.
+func (m *Memory) lookup(id int64, version int64, deleted bool) (types.Student, error) {
+	student, ok := m.students[id]
+	if !ok || (student.DeletedAt != nil) != deleted {
+		if deleted {
+			return types.Student{}, fmt.Errorf("%w: no deleted student with ID %d", storage.ErrNotFound, id)
+		}
+
+		return types.Student{}, fmt.Errorf("%w: no student with ID %d", storage.ErrNotFound, id)
+	}
+
+	if version != 0 && student.Version != version {
+		return types.Student{}, fmt.Errorf("%w: student %d is at version %d, not %d", storage.ErrVersionMismatch, id, student.Version, version)
+	}
+
+	return student, nil
+}
+
+func (m *Memory) UpdateStudent(ctx context.Context, id int64, name string, email string, age int, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.update(ctx, id, name, email, age, version)
+
+	return err
+}
+
+// update replaces the fields of a live student. The caller must hold the lock.
+func (m *Memory) update(ctx context.Context, id int64, name string, email string, age int, version int64) (types.Student, error) {
+	student, err := m.lookup(id, version, false)
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	if err := m.claimEmail(id, email); err != nil {
+		return types.Student{}, err
+	}
+
+	updated := types.Student{Id: id, Name: name, Email: email, Age: age, Version: student.Version + 1}
+
+	m.students[id] = updated
+	m.record(ctx, types.AuditUpdate, id, &student, &updated, time.Now())
+
+	return updated, nil
+}
+
+func (m *Memory) PatchStudent(ctx context.Context, id int64, patch types.StudentPatch, version int64) (types.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Student{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	student, err := m.lookup(id, version, false)
+	if err != nil || patch.IsEmpty() {
+		return student, err // An empty patch writes nothing and keeps the version
+	}
+
+	if patch.Email != nil {
+		if err := m.claimEmail(id, *patch.Email); err != nil {
+			return types.Student{}, err
+		}
+	}
+
+	patched := patch.Apply(student)
+	patched.Version++
+
+	m.students[id] = patched
+	m.record(ctx, types.AuditUpdate, id, &student, &patched, time.Now())
+
+	return patched, nil
+}
+
+// DeleteStudent soft-deletes a student by ID, moving it to the trash.
+func (m *Memory) DeleteStudent(ctx context.Context, id int64, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.delete(ctx, id, version)
+
+	return err
+}
+
+// delete moves a live student to the trash. The caller must hold the lock.
+func (m *Memory) delete(ctx context.Context, id int64, version int64) (types.Student, error) {
+	student, err := m.lookup(id, version, false)
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	deletedAt := time.Now().UTC()
+
+	deleted := student
+	deleted.DeletedAt = &deletedAt
+	deleted.Version++
+
+	m.students[id] = deleted
+	m.record(ctx, types.AuditDelete, id, &student, &deleted, deletedAt)
+
+	return deleted, nil
+}
+
+func (m *Memory) RestoreStudent(ctx context.Context, id int64, version int64) (types.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Student{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	student, err := m.lookup(id, version, true)
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	if err := m.claimEmail(id, student.Email); err != nil {
+		return types.Student{}, err // The email was taken while the student was in the trash
+	}
+
+	restored := student
+	restored.DeletedAt = nil
+	restored.MergedInto = nil // Restoring a merged student undoes the merge
+	restored.Version++
+
+	m.students[id] = restored
+	m.record(ctx, types.AuditRestore, id, &student, &restored, time.Now())
+
+	return restored, nil
+}
+
+func (m *Memory) PurgeStudent(ctx context.Context, id int64, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	student, err := m.lookup(id, version, true)
+	if err != nil {
+		return err
+	}
+
+	delete(m.students, id)
+	m.record(ctx, types.AuditPurge, id, &student, nil, time.Now())
+
+	return nil
+}
+
+// MergeStudents folds the live student sourceID into the live student targetID: the source is moved to the trash
+// with MergedInto set, and both students get an audit entry for the merge.
+func (m *Memory) MergeStudents(ctx context.Context, sourceID int64, targetID int64, sourceVersion int64, targetVersion int64) (types.Student, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Student{}, err
+	}
+
+	if sourceID == targetID {
+		return types.Student{}, fmt.Errorf("%w: cannot merge student %d into itself", storage.ErrConflict, sourceID)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, err := m.lookup(targetID, targetVersion, false)
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	source, err := m.lookup(sourceID, sourceVersion, false)
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	mergedAt := time.Now().UTC()
+
+	merged := source
+	merged.DeletedAt = &mergedAt
+	merged.MergedInto = &targetID
+	merged.Version++
+
+	m.students[sourceID] = merged
+	m.record(ctx, types.AuditMerge, sourceID, &source, &merged, mergedAt)
+
+	// The target itself is unchanged, only its audit log records the merge
+	entry := storage.NewAuditEntry(ctx, types.AuditMerge, targetID, &target, &target, mergedAt)
+	entry.ID = int64(len(m.audit)) + 1
+
+	m.audit = append(m.audit, entry)
+
+	return target, nil
+}
+
+// record appends the audit entry of a change to a student and the version it results in to the history of the
+// student. The caller must hold the lock, which makes the change, its entry and its version atomic.
+func (m *Memory) record(ctx context.Context, action string, studentID int64, before, after *types.Student, at time.Time) {
+	entry := storage.NewAuditEntry(ctx, action, studentID, before, after, at)
+	entry.ID = int64(len(m.audit)) + 1
+
+	m.audit = append(m.audit, entry)
+	m.recordVersion(studentID, after, at)
+}
+
+// matchesAudit reports whether an audit entry passes the filters of a listing.
+func matchesAudit(entry types.AuditEntry, filter types.AuditFilter) bool {
+	switch {
+	case filter.StudentID != 0 && entry.StudentID != filter.StudentID,
+		filter.Actor != "" && entry.Actor != filter.Actor,
+		filter.Action != "" && entry.Action != filter.Action,
+		filter.RequestID != "" && entry.RequestID != filter.RequestID,
+		!filter.Since.IsZero() && entry.Timestamp.Before(filter.Since),
+		!filter.Until.IsZero() && !entry.Timestamp.Before(filter.Until):
+		return false
+	}
+
+	return true
+}
+
+func (m *Memory) GetAuditEntries(ctx context.Context, filter types.AuditFilter) ([]types.AuditEntry, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []types.AuditEntry{}
+
+	for i := len(m.audit) - 1; i >= 0; i-- { // Newest first
+		if matchesAudit(m.audit[i], filter) {
+			entries = append(entries, m.audit[i])
+		}
+	}
+
+	total := int64(len(entries))
+
+	entries = entries[min(filter.Offset, len(entries)):]
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	return entries, total, nil
+}
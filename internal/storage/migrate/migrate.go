@@ -0,0 +1,483 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Errors returned by the Migrator.
+var (
+	// ErrChecksumMismatch is returned when an applied migration was edited after it ran.
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+	// ErrMissingMigration is returned when the database has a migration applied that is not known anymore.
+	ErrMissingMigration = errors.New("applied migration is missing")
+
+	// ErrUnknownVersion is returned when migrating to a version that does not exist.
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// Migration is a numbered schema change with the SQL to apply it and to roll it back.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // hex encoded SHA-256 of the up and down SQL
+}
+
+// Status describes a migration and whether it is applied to the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // the migration was applied with a different checksum than the one of its files
+	Missing   bool // the migration is applied but its files are gone
+}
+
+// Dialect describes the database a Migrator runs on: how it writes query placeholders, and how migrators of several
+// processes are kept from migrating at once.
+type Dialect int
+
+const (
+	SQLite     Dialect = iota // ?, ?, ... placeholders, locked with a BEGIN IMMEDIATE transaction
+	PostgreSQL                // $1, $2, ... placeholders, locked with an advisory lock
+)
+
+func (d Dialect) placeholder(n int) string {
+	if d == PostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+// advisoryLockID is the key of the PostgreSQL advisory lock held while migrating, the same in every process.
+const advisoryLockID = 5_407_613_027_413_129
+
+// querier runs the queries of the migrator: the database, or the connection holding the migration lock.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// fileName matches migration files such as 0001_create_students.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the root directory of fsys. Every migration needs an up and a down file
+// named <version>_<name>.up.sql and <version>_<name>.down.sql, versions have to be unique.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		parts := fileName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(parts[1])
+
+		sql, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+
+		if parts[3] == "up" {
+			migration.Up = string(sql)
+		} else {
+			migration.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d must have both an up and a down file", migration.Version)
+		}
+
+		sum := sha256.Sum256([]byte(migration.Up + "\x00" + migration.Down))
+		migration.Checksum = hex.EncodeToString(sum[:])
+
+		migrations = append(migrations, *migration)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations, recording the applied ones in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New creates a Migrator for the migrations in the root directory of fsys.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Latest returns the highest known migration version, or 0 when there are no migrations.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+type appliedMigration struct {
+	checksum  string
+	name      string
+	appliedAt time.Time
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, q querier) error {
+	_, err := q.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]appliedMigration, error) {
+	if err := m.ensureTable(ctx, q); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+
+	for rows.Next() {
+		var version int
+		var record appliedMigration
+		var appliedAt string
+
+		if err := rows.Scan(&version, &record.name, &record.checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		record.appliedAt, err = time.Parse(time.RFC3339, appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("migration %d has an invalid applied_at: %w", version, err)
+		}
+
+		applied[version] = record
+	}
+
+	return applied, rows.Err()
+}
+
+// Status lists every known migration and every applied one, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	return m.status(ctx, m.db)
+}
+
+func (m *Migrator) status(ctx context.Context, q querier) ([]Status, error) {
+	applied, err := m.applied(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.appliedAt
+			status.Modified = record.checksum != migration.Checksum
+
+			delete(applied, migration.Version)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for version, record := range applied {
+		statuses = append(statuses, Status{
+			Migration: Migration{Version: version, Name: record.name, Checksum: record.checksum},
+			Applied:   true,
+			AppliedAt: record.appliedAt,
+			Missing:   true,
+		})
+	}
+
+	slices.SortFunc(statuses, func(a, b Status) int { return a.Version - b.Version })
+
+	return statuses, nil
+}
+
+// verify checks that the applied migrations are known and unchanged, and returns the current version.
+func (m *Migrator) verify(ctx context.Context, q querier) ([]Status, int, error) {
+	statuses, err := m.status(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	current := 0
+
+	for _, status := range statuses {
+		switch {
+		case status.Missing:
+			return nil, 0, fmt.Errorf("%w: version %d (%s)", ErrMissingMigration, status.Version, status.Name)
+		case status.Modified:
+			return nil, 0, fmt.Errorf("%w: version %d (%s) was edited after it was applied", ErrChecksumMismatch, status.Version, status.Name)
+		case status.Applied:
+			current = max(current, status.Version)
+		}
+	}
+
+	return statuses, current, nil
+}
+
+// Version returns the highest applied migration version, or 0 when none is applied.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	_, current, err := m.verify(ctx, m.db)
+
+	return current, err
+}
+
+// Pending returns the migrations that are not applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, _, err := m.verify(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up applies every pending migration in order and returns the applied ones.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration and returns it, or nil when nothing is applied.
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		statuses, current, err := m.verify(ctx, conn)
+		if err != nil || current == 0 {
+			return err
+		}
+
+		target := 0
+
+		for _, status := range statuses {
+			if status.Applied && status.Version < current {
+				target = status.Version
+			}
+		}
+
+		ran, err = m.to(ctx, conn, statuses, target)
+
+		return err
+	})
+
+	return ran, err
+}
+
+// To migrates up or down until the given version is the latest applied one and returns the migrations it ran.
+// Version 0 rolls back every migration.
+func (m *Migrator) To(ctx context.Context, version int) ([]Migration, error) {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == version }) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	var ran []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		statuses, _, err := m.verify(ctx, conn) // checked once the lock is held, another process may just have migrated
+		if err != nil {
+			return err
+		}
+
+		ran, err = m.to(ctx, conn, statuses, version)
+
+		return err
+	})
+
+	return ran, err
+}
+
+// to runs the migrations that bring the database from statuses to the given version.
+func (m *Migrator) to(ctx context.Context, conn *sql.Conn, statuses []Status, version int) ([]Migration, error) {
+	var ran []Migration
+
+	// Roll back applied migrations above the target, newest first
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if status := statuses[i]; status.Applied && status.Version > version {
+			if err := m.run(ctx, conn, status.Migration, false); err != nil {
+				return ran, err
+			}
+
+			ran = append(ran, status.Migration)
+		}
+	}
+
+	// Apply pending migrations up to the target, oldest first
+	for _, status := range statuses {
+		if !status.Applied && status.Version <= version {
+			if err := m.run(ctx, conn, status.Migration, true); err != nil {
+				return ran, err
+			}
+
+			ran = append(ran, status.Migration)
+		}
+	}
+
+	return ran, nil
+}
+
+// locked runs fn on a connection holding the migration lock of the database, so that migrators of other processes,
+// like servers starting together with auto_migrate, wait for it instead of running the same migrations.
+// PostgreSQL takes a session advisory lock. SQLite has none: fn runs in a BEGIN IMMEDIATE transaction, which holds
+// the write lock of the database, and every migration in a savepoint of it.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	lock, unlock, args := "BEGIN IMMEDIATE", "COMMIT", []any(nil) // migrations that ran before a failing one stay applied
+	if m.dialect == PostgreSQL {
+		lock, unlock, args = "SELECT pg_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", []any{int64(advisoryLockID)}
+	}
+
+	if _, err := conn.ExecContext(ctx, lock, args...); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+
+	fnErr := fn(conn)
+
+	if _, err := conn.ExecContext(context.WithoutCancel(ctx), unlock, args...); err != nil {
+		// the connection may still hold the lock, so it is discarded rather than returned to the pool
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+
+		return errors.Join(fnErr, fmt.Errorf("unlock migrations: %w", err))
+	}
+
+	return fnErr
+}
+
+// run applies or rolls back a single migration together with its schema_migrations record, in a transaction of
+// its own, or in a savepoint of the transaction holding the lock of an SQLite database.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	if m.dialect == SQLite {
+		if _, err := conn.ExecContext(ctx, "SAVEPOINT migration"); err != nil {
+			return err
+		}
+
+		if err := m.apply(ctx, conn, migration, up); err != nil {
+			conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK TO migration; RELEASE migration")
+
+			return err
+		}
+
+		_, err := conn.ExecContext(ctx, "RELEASE migration")
+
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback() // No-op once the transaction is committed
+
+	if err := m.apply(ctx, tx, migration, up); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// apply runs the up or down SQL of a migration and records it in schema_migrations.
+func (m *Migrator) apply(ctx context.Context, q querier, migration Migration, up bool) error {
+	query, direction := migration.Down, "down"
+	if up {
+		query, direction = migration.Up, "up"
+	}
+
+	if _, err := q.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("migration %d (%s) %s: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	var err error
+
+	if up {
+		_, err = q.ExecContext(ctx,
+			fmt.Sprintf("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (%s, %s, %s, %s)",
+				m.dialect.placeholder(1), m.dialect.placeholder(2), m.dialect.placeholder(3), m.dialect.placeholder(4)),
+			migration.Version, migration.Name, migration.Checksum, time.Now().UTC().Format(time.RFC3339))
+	} else {
+		_, err = q.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = "+m.dialect.placeholder(1), migration.Version)
+	}
+
+	if err != nil {
+		return fmt.Errorf("record migration %d: %w", migration.Version, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,328 @@
+package migrate_test
+
+import (
+	"database/sql"
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/migrate"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// files returns migrations creating and dropping one table each, named after their version.
+func files(tables ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+
+	for i, table := range tables {
+		name := []byte{'0', '0', '0', byte('1' + i)}
+
+		fsys[string(name)+"_create_"+table+".up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY);")}
+		fsys[string(name)+"_create_"+table+".down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE " + table + ";")}
+	}
+
+	return fsys
+}
+
+// open returns an in-memory SQLite database, on a single connection so that every query sees the same database.
+func open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS) *migrate.Migrator {
+	t.Helper()
+
+	migrator, err := migrate.New(db, migrate.SQLite, fsys)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return migrator
+}
+
+func versions(migrations []migrate.Migration) []int {
+	var got []int
+
+	for _, migration := range migrations {
+		got = append(got, migration.Version)
+	}
+
+	return got
+}
+
+// tables returns the tables of the database other than schema_migrations.
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	var names []string
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"invalid name", fstest.MapFS{"create_a.up.sql": {}, "create_a.down.sql": {}}},
+		{"no down file", fstest.MapFS{"0001_create_a.up.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")}}},
+		{"different names", fstest.MapFS{"0001_create_a.up.sql": {Data: []byte("x")}, "0001_make_a.down.sql": {Data: []byte("x")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := migrate.Load(tt.files); err == nil {
+				t.Fatal("Load succeeded, want an error")
+			}
+		})
+	}
+
+	fsys := files("a", "b")
+	fsys["README.md"] = &fstest.MapFile{} // other files are ignored
+
+	migrations, err := migrate.Load(fsys)
+	if err != nil || !slices.Equal(versions(migrations), []int{1, 2}) || migrations[0].Name != "create_a" || migrations[0].Checksum == "" {
+		t.Fatalf("Load returned %+v, %v, want migrations 1 and 2", migrations, err)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db := open(t)
+	migrator := newMigrator(t, db, files("a", "b", "c"))
+
+	steps := []struct {
+		name    string
+		run     func() ([]migrate.Migration, error)
+		ran     []int
+		err     error
+		version int
+		tables  []string
+	}{
+		{"up", func() ([]migrate.Migration, error) { return migrator.Up(t.Context()) }, []int{1, 2, 3}, nil, 3, []string{"a", "b", "c"}},
+		{"up again", func() ([]migrate.Migration, error) { return migrator.Up(t.Context()) }, nil, nil, 3, []string{"a", "b", "c"}},
+		{"to 1", func() ([]migrate.Migration, error) { return migrator.To(t.Context(), 1) }, []int{3, 2}, nil, 1, []string{"a"}},
+		{"down", func() ([]migrate.Migration, error) { return migrator.Down(t.Context()) }, []int{1}, nil, 0, nil},
+		{"down with nothing applied", func() ([]migrate.Migration, error) { return migrator.Down(t.Context()) }, nil, nil, 0, nil},
+		{"to 2", func() ([]migrate.Migration, error) { return migrator.To(t.Context(), 2) }, []int{1, 2}, nil, 2, []string{"a", "b"}},
+		{"to unknown version", func() ([]migrate.Migration, error) { return migrator.To(t.Context(), 9) }, nil, migrate.ErrUnknownVersion, 2, []string{"a", "b"}},
+		{"down from 2", func() ([]migrate.Migration, error) { return migrator.Down(t.Context()) }, []int{2}, nil, 1, []string{"a"}},
+	}
+
+	for _, step := range steps {
+		ran, err := step.run()
+
+		if !errors.Is(err, step.err) {
+			t.Fatalf("%s: got error %v, want %v", step.name, err, step.err)
+		}
+
+		if !slices.Equal(versions(ran), step.ran) {
+			t.Fatalf("%s: ran %v, want %v", step.name, versions(ran), step.ran)
+		}
+
+		if version, err := migrator.Version(t.Context()); err != nil || version != step.version {
+			t.Fatalf("%s: Version = %d, %v, want %d", step.name, version, err, step.version)
+		}
+
+		if got := tables(t, db); !slices.Equal(got, step.tables) {
+			t.Fatalf("%s: tables are %v, want %v", step.name, got, step.tables)
+		}
+	}
+
+	statuses, err := migrator.Status(t.Context())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+
+	if len(statuses) != 3 || !statuses[0].Applied || statuses[0].AppliedAt.IsZero() || statuses[1].Applied || statuses[2].Applied {
+		t.Fatalf("Status returned %+v, want only migration 1 applied", statuses)
+	}
+
+	pending, err := migrator.Pending(t.Context())
+	if err != nil || !slices.Equal(versions(pending), []int{2, 3}) {
+		t.Fatalf("Pending returned %v, %v, want 2 and 3", versions(pending), err)
+	}
+}
+
+func TestMigratorRejectsChangedHistory(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(fsys fstest.MapFS)
+		err    error
+		status func(statuses []migrate.Status) bool
+	}{
+		{
+			name: "edited",
+			change: func(fsys fstest.MapFS) {
+				fsys["0002_create_b.up.sql"].Data = []byte("CREATE TABLE b (id INTEGER, name TEXT);")
+			},
+			err:    migrate.ErrChecksumMismatch,
+			status: func(statuses []migrate.Status) bool { return statuses[1].Modified },
+		},
+		{
+			name:   "deleted",
+			change: func(fsys fstest.MapFS) { delete(fsys, "0002_create_b.up.sql"); delete(fsys, "0002_create_b.down.sql") },
+			err:    migrate.ErrMissingMigration,
+			status: func(statuses []migrate.Status) bool { return len(statuses) == 2 && statuses[1].Missing },
+		},
+		{
+			name: "renumbered",
+			change: func(fsys fstest.MapFS) {
+				fsys["0005_create_b.up.sql"], fsys["0005_create_b.down.sql"] = fsys["0002_create_b.up.sql"], fsys["0002_create_b.down.sql"]
+				delete(fsys, "0002_create_b.up.sql")
+				delete(fsys, "0002_create_b.down.sql")
+			},
+			err: migrate.ErrMissingMigration,
+			status: func(statuses []migrate.Status) bool {
+				return statuses[1].Missing && statuses[1].Version == 2 && !statuses[2].Applied
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := open(t)
+			fsys := files("a", "b")
+
+			if _, err := newMigrator(t, db, fsys).Up(t.Context()); err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+
+			changed := maps.Clone(fsys)
+			for name, file := range changed {
+				copied := *file
+				changed[name] = &copied
+			}
+
+			tt.change(changed)
+			migrator := newMigrator(t, db, changed)
+
+			if _, err := migrator.Up(t.Context()); !errors.Is(err, tt.err) {
+				t.Fatalf("Up returned %v, want %v", err, tt.err)
+			}
+
+			if _, err := migrator.Down(t.Context()); !errors.Is(err, tt.err) {
+				t.Fatalf("Down returned %v, want %v", err, tt.err)
+			}
+
+			if _, err := migrator.Version(t.Context()); !errors.Is(err, tt.err) {
+				t.Fatalf("Version returned %v, want %v", err, tt.err)
+			}
+
+			statuses, err := migrator.Status(t.Context())
+			if err != nil || !tt.status(statuses) {
+				t.Fatalf("Status returned %+v, %v", statuses, err)
+			}
+		})
+	}
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	db := open(t)
+
+	fsys := files("a", "b")
+	fsys["0002_create_b.up.sql"].Data = []byte("CREATE TABLE b (id INTEGER PRIMARY KEY); INSERT INTO missing VALUES (1);")
+
+	ran, err := newMigrator(t, db, fsys).Up(t.Context())
+	if err == nil {
+		t.Fatal("Up succeeded, want the error of migration 2")
+	}
+
+	if !slices.Equal(versions(ran), []int{1}) || !slices.Equal(tables(t, db), []string{"a"}) {
+		t.Fatalf("Up ran %v and left tables %v, want migration 1 applied and migration 2 rolled back", versions(ran), tables(t, db))
+	}
+}
+
+func TestMigratorRejectsInvalidAppliedAt(t *testing.T) {
+	db := open(t)
+	migrator := newMigrator(t, db, files("a"))
+
+	if _, err := migrator.Up(t.Context()); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	if _, err := db.Exec("UPDATE schema_migrations SET applied_at = 'yesterday'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Status(t.Context()); err == nil {
+		t.Fatal("Status succeeded with an invalid applied_at")
+	}
+}
+
+func TestMigratorLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "students.db")
+	fsys := files("a", "b", "c")
+
+	var wg sync.WaitGroup
+
+	ran := make([][]migrate.Migration, 4)
+	errs := make([]error, len(ran))
+
+	for i := range ran {
+		db, err := sql.Open("sqlite3", path+"?_busy_timeout=10000") // a process of its own
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { db.Close() })
+
+		migrator := newMigrator(t, db, fsys)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ran[i], errs[i] = migrator.Up(t.Context())
+		}()
+	}
+
+	wg.Wait()
+
+	var all []int
+
+	for i := range ran {
+		if errs[i] != nil {
+			t.Fatalf("Up %d: %v", i, errs[i])
+		}
+
+		all = append(all, versions(ran[i])...)
+	}
+
+	slices.Sort(all)
+
+	if !slices.Equal(all, []int{1, 2, 3}) {
+		t.Fatalf("concurrent migrators ran %v, want every migration once", all)
+	}
+}
@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// insertAuditEntry records an audit entry within the transaction of the change it describes.
+// Snapshots of the student are stored as JSONB.
+func insertAuditEntry(ctx context.Context, tx *sql.Tx, entry types.AuditEntry) error {
+	before, err := snapshot(entry.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := snapshot(entry.After)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (student_id, action, actor, request_id, created_at, before_state, after_state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.StudentID, entry.Action, entry.Actor, entry.RequestID, entry.Timestamp, before, after)
+	if err != nil {
+		return fmt.Errorf("audit error: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot encodes a student snapshot of an audit entry, nil is stored as NULL.
+func snapshot(student *types.Student) (any, error) {
+	if student == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(student)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(encoded), nil
+}
+
+// auditFilterConditions builds the conditions and their arguments for the given audit filter.
+func auditFilterConditions(filter types.AuditFilter) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	if filter.StudentID != 0 {
+		conditions = append(conditions, "student_id = ?")
+		args = append(args, filter.StudentID)
+	}
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+
+	if filter.RequestID != "" {
+		conditions = append(conditions, "request_id = ?")
+		args = append(args, filter.RequestID)
+	}
+
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since)
+	}
+
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until)
+	}
+
+	return conditions, args
+}
+
+func (p *Postgres) GetAuditEntries(ctx context.Context, filter types.AuditFilter) ([]types.AuditEntry, int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	conditions, args := auditFilterConditions(filter)
+
+	var total int64
+
+	err := p.DB.QueryRowContext(ctx, rebind("SELECT COUNT(*) FROM audit_log"+where(conditions)), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count error: %w", err)
+	}
+
+	query := `SELECT id, student_id, action, actor, request_id, created_at, before_state, after_state
+		FROM audit_log` + where(conditions) + " ORDER BY id DESC OFFSET ?"
+	args = append(args, filter.Offset)
+
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := p.DB.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("audit query error: %w", err)
+	}
+
+	defer rows.Close()
+
+	entries := []types.AuditEntry{}
+
+	for rows.Next() {
+		var entry types.AuditEntry
+		var before, after []byte
+
+		err := rows.Scan(&entry.ID, &entry.StudentID, &entry.Action, &entry.Actor, &entry.RequestID, &entry.Timestamp, &before, &after)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan error: %w", err)
+		}
+
+		if entry.Before, err = decodeSnapshot(before); err != nil {
+			return nil, 0, err
+		}
+
+		if entry.After, err = decodeSnapshot(after); err != nil {
+			return nil, 0, err
+		}
+
+		entry.Timestamp = entry.Timestamp.UTC()
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// decodeSnapshot decodes a student snapshot stored by insertAuditEntry, NULL decodes to nil.
+func decodeSnapshot(encoded []byte) (*types.Student, error) {
+	if encoded == nil {
+		return nil, nil
+	}
+
+	var student types.Student
+
+	if err := json.Unmarshal(encoded, &student); err != nil {
+		return nil, fmt.Errorf("decode audit snapshot: %w", err)
+	}
+
+	return &student, nil
+}
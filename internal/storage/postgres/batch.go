@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// ApplyBatch runs a batch of writes in one transaction. Without atomic, every operation runs in a savepoint that is
+// rolled back on its own when the operation fails.
+func (p *Postgres) ApplyBatch(ctx context.Context, operations []types.BatchOperation, atomic bool) ([]storage.BatchResult, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	results := make([]storage.BatchResult, len(operations))
+
+	for i, operation := range operations {
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_operation"); err != nil {
+				return nil, fmt.Errorf("savepoint error: %w", err)
+			}
+		}
+
+		student, err := applyOperation(ctx, tx, operation)
+
+		switch {
+		case err != nil && atomic:
+			return storage.AbortBatch(results, i, err), nil // The deferred rollback undoes the operations before this one
+		case err != nil:
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_operation"); err != nil {
+				return nil, fmt.Errorf("savepoint error: %w", err)
+			}
+
+			results[i].Err = err
+		default:
+			results[i] = storage.BatchResult{ID: student.Id, Version: student.Version}
+		}
+
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation"); err != nil {
+				return nil, fmt.Errorf("savepoint error: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// applyOperation runs one operation of a batch within its transaction and returns the student afterwards.
+func applyOperation(ctx context.Context, tx *sql.Tx, operation types.BatchOperation) (*types.Student, error) {
+	student := operation.Student
+
+	switch operation.Op {
+	case types.BatchCreate:
+		created, err := insertStudent(ctx, tx, student.Name, student.Email, student.Age)
+		if err != nil {
+			return nil, err
+		}
+
+		return &created, nil
+	case types.BatchUpdate:
+		return mutateTx(ctx, tx, types.AuditUpdate, student.Id, student.Version, false, updateStudent(student.Name, student.Email, student.Age))
+	case types.BatchDelete:
+		return mutateTx(ctx, tx, types.AuditDelete, student.Id, student.Version, false, deleteStudent)
+	default:
+		return nil, fmt.Errorf("unsupported batch operation %q", operation.Op)
+	}
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// recordVersion closes the current version of a student in its history at now and, unless the student was purged
+// and after is nil, opens the version after the change. It runs within the transaction of the change.
+func recordVersion(ctx context.Context, tx *sql.Tx, id int64, after *types.Student, now time.Time) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE student_history SET valid_to = $1 WHERE student_id = $2 AND valid_to IS NULL", now, id); err != nil {
+		return fmt.Errorf("history error: %w", err)
+	}
+
+	if after == nil {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO student_history (student_id, version, name, email, age, deleted_at, merged_into, valid_from)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		id, after.Version, after.Name, after.Email, after.Age, after.DeletedAt, after.MergedInto, now)
+	if err != nil {
+		return fmt.Errorf("history error: %w", err)
+	}
+
+	return nil
+}
+
+// historySource returns the table expression the student listing reads from, along with its arguments, with ?
+// placeholders like the rest of the listing query. Listings as of a past time read the versions valid at that time,
+// under the same column names as students.
+func historySource(filter types.StudentFilter) (string, []any) {
+	if filter.AsOf.IsZero() {
+		return "students", nil
+	}
+
+	return `(SELECT student_id AS id, name, email, age, version, deleted_at, merged_into FROM student_history
+		WHERE valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)) AS students`, []any{filter.AsOf, filter.AsOf}
+}
+
+func (p *Postgres) GetStudentAsOf(ctx context.Context, id int64, at time.Time) (types.Student, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	student, err := scanVersion(p.DB.QueryRowContext(ctx, `
+		SELECT student_id, name, email, age, version, deleted_at, merged_into FROM student_history
+		WHERE student_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2) AND deleted_at IS NULL`,
+		id, at))
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.Student{}, fmt.Errorf("%w: no student with ID %d at %s", storage.ErrNotFound, id, at.UTC().Format(time.RFC3339))
+	}
+
+	return student, err
+}
+
+func (p *Postgres) GetStudentVersion(ctx context.Context, id int64, version int64) (types.Student, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	student, err := scanVersion(p.DB.QueryRowContext(ctx,
+		"SELECT student_id, name, email, age, version, deleted_at, merged_into FROM student_history WHERE student_id = $1 AND version = $2",
+		id, version))
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.Student{}, fmt.Errorf("%w: no version %d of student with ID %d", storage.ErrNotFound, version, id)
+	}
+
+	return student, err
+}
+
+// scanVersion reads a student version selected from the history. sql.ErrNoRows is returned as is.
+func scanVersion(row *sql.Row) (types.Student, error) {
+	var student types.Student
+	var deletedAt sql.NullTime
+	var mergedInto sql.NullInt64
+
+	err := row.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.Version, &deletedAt, &mergedInto)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return types.Student{}, err
+	case err != nil:
+		return types.Student{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if deletedAt.Valid {
+		student.DeletedAt = &deletedAt.Time
+	}
+
+	if mergedInto.Valid {
+		student.MergedInto = &mergedInto.Int64
+	}
+
+	return student, nil
+}
@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// ClaimIdempotencyKey inserts a record for a request being processed, or takes over the record of the key if it
+// expired. The upsert locks the row of the key, so the record read afterwards is the one that was kept.
+func (p *Postgres) ClaimIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) (types.IdempotencyRecord, bool, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return types.IdempotencyRecord{}, false, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, fingerprint, status, created_at, expires_at) VALUES ($1, $2, 0, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET fingerprint = excluded.fingerprint, status = 0, header = NULL, body = NULL,
+			created_at = excluded.created_at, expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at`,
+		record.Key, record.Fingerprint, record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return types.IdempotencyRecord{}, false, fmt.Errorf("idempotency error: %w", translateError(err))
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return types.IdempotencyRecord{}, false, err
+	}
+
+	if claimed == 0 {
+		stored, err := loadIdempotencyRecord(ctx, tx, record.Key)
+		if err != nil {
+			return types.IdempotencyRecord{}, false, err
+		}
+
+		return stored, false, tx.Commit()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return types.IdempotencyRecord{}, false, err
+	}
+
+	record.Status, record.Header, record.Body = 0, nil, nil
+
+	return record, true, nil
+}
+
+// loadIdempotencyRecord reads the record of a key within a transaction.
+func loadIdempotencyRecord(ctx context.Context, tx *sql.Tx, key string) (types.IdempotencyRecord, error) {
+	var record types.IdempotencyRecord
+	var header []byte
+
+	err := tx.QueryRowContext(ctx, "SELECT key, fingerprint, status, header, body, created_at, expires_at FROM idempotency_keys WHERE key = $1", key).
+		Scan(&record.Key, &record.Fingerprint, &record.Status, &header, &record.Body, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.IdempotencyRecord{}, fmt.Errorf("%w: no idempotency key %q", storage.ErrNotFound, key)
+		}
+
+		return types.IdempotencyRecord{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if header != nil {
+		if err := json.Unmarshal(header, &record.Header); err != nil {
+			return types.IdempotencyRecord{}, fmt.Errorf("idempotency error: invalid header of key %q: %w", key, err)
+		}
+	}
+
+	return record, nil
+}
+
+// CompleteIdempotencyKey stores the response of a request; the headers are stored as JSONB.
+func (p *Postgres) CompleteIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.DB.ExecContext(ctx, "UPDATE idempotency_keys SET status = $1, header = $2, body = $3, expires_at = $4 WHERE key = $5 AND fingerprint = $6 AND status = 0",
+		record.Status, string(header), record.Body, record.ExpiresAt, record.Key, record.Fingerprint)
+	if err != nil {
+		return fmt.Errorf("idempotency error: %w", err)
+	}
+
+	completed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if completed == 0 {
+		return fmt.Errorf("%w: idempotency key %q is not claimed by a request being processed", storage.ErrNotFound, record.Key)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := p.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status = 0", key); err != nil {
+		return fmt.Errorf("idempotency error: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	result, err := p.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("idempotency error: %w", err)
+	}
+
+	return result.RowsAffected()
+}
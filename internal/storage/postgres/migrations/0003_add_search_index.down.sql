@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_students_search;
+ALTER TABLE students DROP COLUMN IF EXISTS search;
@@ -0,0 +1,6 @@
+-- Emails are split on punctuation so that "gmail" matches "ansh@gmail.com", the default parser keeps them whole.
+ALTER TABLE students ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+	to_tsvector('simple', name || ' ' || regexp_replace(email, '[^[:alnum:]]+', ' ', 'g'))
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_students_search ON students USING GIN (search);
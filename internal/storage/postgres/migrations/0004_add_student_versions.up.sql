@@ -0,0 +1,2 @@
+-- Every write bumps the version, which the API exposes as the ETag of a student for optimistic concurrency.
+ALTER TABLE students ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Deleted students stay in the table with deleted_at set until they are restored or purged from the trash.
+ALTER TABLE students ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_students_deleted_at ON students (deleted_at);
@@ -0,0 +1,25 @@
+-- Every change to a student is recorded here in the transaction that makes it. Entries are never changed or removed,
+-- not even when the student is purged, so there is no foreign key to students.
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	student_id BIGINT NOT NULL,
+	action TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	before_state JSONB,
+	after_state JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_student_id ON audit_log (student_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit log entries are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+CREATE TRIGGER audit_log_immutable BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
@@ -0,0 +1,20 @@
+-- Every version of a student, valid from valid_from up to but excluding valid_to. The current version has no valid_to.
+-- Rows are written in the transaction that changes the student and are kept when the student is purged.
+CREATE TABLE IF NOT EXISTS student_history (
+	student_id BIGINT NOT NULL,
+	version BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	age BIGINT NOT NULL,
+	deleted_at TIMESTAMPTZ,
+	valid_from TIMESTAMPTZ NOT NULL,
+	valid_to TIMESTAMPTZ,
+	PRIMARY KEY (student_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_student_history_valid_from ON student_history (valid_from, valid_to);
+
+-- Existing students start their history now, their earlier versions were never recorded.
+INSERT INTO student_history (student_id, version, name, email, age, deleted_at, valid_from)
+SELECT id, version, name, email, age, deleted_at, now() FROM students
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_students_email_key;
+ALTER TABLE student_history DROP COLUMN IF EXISTS merged_into;
+ALTER TABLE students DROP COLUMN IF EXISTS merged_into;
+ALTER TABLE students DROP COLUMN IF EXISTS email_key;
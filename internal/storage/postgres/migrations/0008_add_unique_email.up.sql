@@ -0,0 +1,16 @@
+-- email_key holds the lowercased email of live students and is unique, so that no two live students share an email.
+-- It is NULL in the trash, and for students that shared their email with a live student of a lower ID when the rule
+-- was introduced: those duplicates are kept until they are merged, see GET /api/students/duplicates.
+ALTER TABLE students ADD COLUMN IF NOT EXISTS email_key TEXT;
+
+UPDATE students SET email_key = lower(trim(email))
+WHERE deleted_at IS NULL AND NOT EXISTS (
+	SELECT 1 FROM students AS other
+	WHERE other.deleted_at IS NULL AND lower(trim(other.email)) = lower(trim(students.email)) AND other.id < students.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_students_email_key ON students (email_key);
+
+-- merged_into is set on students merged into another one, which moves them to the trash.
+ALTER TABLE students ADD COLUMN IF NOT EXISTS merged_into BIGINT;
+ALTER TABLE student_history ADD COLUMN IF NOT EXISTS merged_into BIGINT;
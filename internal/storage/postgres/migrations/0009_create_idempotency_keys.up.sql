@@ -0,0 +1,14 @@
+-- The outcome of requests made with an Idempotency-Key header, replayed to their retries until expires_at.
+-- status is 0 while the request that claimed the key is being processed; header holds the replayed response
+-- headers as JSON.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	header JSONB,
+	body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,2 @@
+-- Roles granted to the client of each API key, as a JSON array. Existing keys have none until they are granted some.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS roles JSONB NOT NULL DEFAULT '[]';
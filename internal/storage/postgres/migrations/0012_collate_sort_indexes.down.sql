@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_students_name_id;
+DROP INDEX IF EXISTS idx_students_email_id;
+CREATE INDEX IF NOT EXISTS idx_students_name_id ON students (name, id);
+CREATE INDEX IF NOT EXISTS idx_students_email_id ON students (email, id);
@@ -0,0 +1,6 @@
+-- Names and emails are sorted bytewise, with the "C" collation, so that every backend orders them alike.
+-- Their sort indexes are rebuilt with it, the indexes of the default collation cannot serve that order.
+DROP INDEX IF EXISTS idx_students_name_id;
+DROP INDEX IF EXISTS idx_students_email_id;
+CREATE INDEX IF NOT EXISTS idx_students_name_id ON students (name COLLATE "C", id);
+CREATE INDEX IF NOT EXISTS idx_students_email_id ON students (email COLLATE "C", id);
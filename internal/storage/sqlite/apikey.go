@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// apiKeyColumns lists the columns scanned by scanAPIKey, in order.
+const apiKeyColumns = "id, name, prefix, hash, roles, created_at, revoked_at"
+
+// scanAPIKey reads an API key from a row selected with apiKeyColumns.
+func scanAPIKey(row interface{ Scan(...any) error }) (types.APIKey, error) {
+	var key types.APIKey
+	var roles []byte
+	var revokedAt sql.NullTime
+
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &roles, &key.CreatedAt, &revokedAt); err != nil {
+		return types.APIKey{}, err
+	}
+
+	if err := json.Unmarshal(roles, &key.Roles); err != nil {
+		return types.APIKey{}, fmt.Errorf("invalid roles of API key %q: %w", key.Name, err)
+	}
+
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
+
+func (s *Sqlite) CreateAPIKey(ctx context.Context, key types.APIKey) (types.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	roles, err := encodeRoles(key.Roles)
+	if err != nil {
+		return types.APIKey{}, err
+	}
+
+	result, err := s.DB.ExecContext(ctx, "INSERT INTO api_keys (name, prefix, hash, roles, created_at) VALUES (?, ?, ?, ?, ?)",
+		key.Name, key.Prefix, key.Hash, roles, key.CreatedAt.UTC())
+	if err != nil {
+		return types.APIKey{}, fmt.Errorf("api key error: %w", translateError(err)) // A name in use violates the UNIQUE constraint
+	}
+
+	key.ID, err = result.LastInsertId()
+	if err != nil {
+		return types.APIKey{}, err
+	}
+
+	key.RevokedAt = nil
+
+	return key, nil
+}
+
+func (s *Sqlite) GetAPIKeyByHash(ctx context.Context, hash string) (types.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	key, err := scanAPIKey(s.DB.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE hash = ?", hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.APIKey{}, fmt.Errorf("%w: no such API key", storage.ErrNotFound)
+		}
+
+		return types.APIKey{}, fmt.Errorf("query error: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *Sqlite) GetAPIKeys(ctx context.Context) ([]types.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	defer rows.Close()
+
+	keys := []types.APIKey{}
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *Sqlite) RevokeAPIKey(ctx context.Context, name string, at time.Time) error {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, "UPDATE api_keys SET revoked_at = ? WHERE name = ? AND revoked_at IS NULL", at.UTC(), name)
+	if err != nil {
+		return fmt.Errorf("api key error: %w", err)
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if revoked == 0 {
+		return fmt.Errorf("%w: no API key named %q that is not revoked", storage.ErrNotFound, name)
+	}
+
+	return nil
+}
+
+func (s *Sqlite) SetAPIKeyRoles(ctx context.Context, name string, roles []string) error {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	encoded, err := encodeRoles(roles)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.DB.ExecContext(ctx, "UPDATE api_keys SET roles = ? WHERE name = ?", encoded, name)
+	if err != nil {
+		return fmt.Errorf("api key error: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return fmt.Errorf("%w: no API key named %q", storage.ErrNotFound, name)
+	}
+
+	return nil
+}
+
+// encodeRoles encodes the roles of an API key as a JSON array, nil as an empty one.
+func encodeRoles(roles []string) (string, error) {
+	if roles == nil {
+		roles = []string{}
+	}
+
+	encoded, err := json.Marshal(roles)
+
+	return string(encoded), err
+}
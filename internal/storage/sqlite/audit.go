@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// insertAuditEntry records an audit entry within the transaction of the change it describes.
+// Snapshots of the student are stored as JSON.
+func insertAuditEntry(ctx context.Context, tx *sql.Tx, entry types.AuditEntry) error {
+	before, err := snapshot(entry.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := snapshot(entry.After)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (student_id, action, actor, request_id, created_at, before_state, after_state)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.StudentID, entry.Action, entry.Actor, entry.RequestID, entry.Timestamp, before, after)
+	if err != nil {
+		return fmt.Errorf("audit error: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot encodes a student snapshot of an audit entry, nil is stored as NULL.
+func snapshot(student *types.Student) (any, error) {
+	if student == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(student)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(encoded), nil
+}
+
+// auditFilterClause builds the WHERE clause and its arguments for the given audit filter.
+func auditFilterClause(filter types.AuditFilter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if filter.StudentID != 0 {
+		conditions = append(conditions, "student_id = ?")
+		args = append(args, filter.StudentID)
+	}
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+
+	if filter.RequestID != "" {
+		conditions = append(conditions, "request_id = ?")
+		args = append(args, filter.RequestID)
+	}
+
+	// Timestamps are stored in UTC with the driver's fixed format, so they compare as strings
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (s *Sqlite) GetAuditEntries(ctx context.Context, filter types.AuditFilter) ([]types.AuditEntry, int64, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	where, args := auditFilterClause(filter)
+
+	var total int64
+
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count error: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // A negative LIMIT means no limit in SQLite
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, student_id, action, actor, request_id, created_at, before_state, after_state
+		FROM audit_log`+where+" ORDER BY id DESC LIMIT ? OFFSET ?", append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("audit query error: %w", err)
+	}
+
+	defer rows.Close() // Ensure the rows are closed after use
+
+	entries := []types.AuditEntry{}
+
+	for rows.Next() {
+		var entry types.AuditEntry
+		var before, after sql.NullString
+
+		err := rows.Scan(&entry.ID, &entry.StudentID, &entry.Action, &entry.Actor, &entry.RequestID, &entry.Timestamp, &before, &after)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan error: %w", err)
+		}
+
+		if entry.Before, err = decodeSnapshot(before); err != nil {
+			return nil, 0, err
+		}
+
+		if entry.After, err = decodeSnapshot(after); err != nil {
+			return nil, 0, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// decodeSnapshot decodes a student snapshot stored by insertAuditEntry.
+func decodeSnapshot(encoded sql.NullString) (*types.Student, error) {
+	if !encoded.Valid {
+		return nil, nil
+	}
+
+	var student types.Student
+
+	if err := json.Unmarshal([]byte(encoded.String), &student); err != nil {
+		return nil, fmt.Errorf("decode audit snapshot: %w", err)
+	}
+
+	return &student, nil
+}
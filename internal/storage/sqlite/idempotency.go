@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// ClaimIdempotencyKey inserts a record for a request being processed, or takes over the record of the key if it
+// expired, in one transaction with the read of the record that is kept.
+func (s *Sqlite) ClaimIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) (types.IdempotencyRecord, bool, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the queries by the configured query timeout
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil) // Starts with BEGIN IMMEDIATE, see Open, so the record cannot change between the claim and the read
+	if err != nil {
+		return types.IdempotencyRecord{}, false, err
+	}
+
+	defer tx.Rollback() // Roll back unless the transaction was committed
+
+	// Timestamps are stored in UTC with the driver's fixed format, so they compare as strings
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, fingerprint, status, created_at, expires_at) VALUES (?, ?, 0, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET fingerprint = excluded.fingerprint, status = 0, header = NULL, body = NULL,
+			created_at = excluded.created_at, expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at`,
+		record.Key, record.Fingerprint, record.CreatedAt.UTC(), record.ExpiresAt.UTC())
+	if err != nil {
+		return types.IdempotencyRecord{}, false, fmt.Errorf("idempotency error: %w", translateError(err))
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return types.IdempotencyRecord{}, false, err
+	}
+
+	if claimed == 0 {
+		stored, err := loadIdempotencyRecord(ctx, tx, record.Key)
+		if err != nil {
+			return types.IdempotencyRecord{}, false, err
+		}
+
+		return stored, false, tx.Commit()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return types.IdempotencyRecord{}, false, err
+	}
+
+	record.Status, record.Header, record.Body = 0, nil, nil
+
+	return record, true, nil
+}
+
+// loadIdempotencyRecord reads the record of a key within a transaction.
+func loadIdempotencyRecord(ctx context.Context, tx *sql.Tx, key string) (types.IdempotencyRecord, error) {
+	var record types.IdempotencyRecord
+	var header sql.NullString
+
+	err := tx.QueryRowContext(ctx, "SELECT key, fingerprint, status, header, body, created_at, expires_at FROM idempotency_keys WHERE key = ?", key).
+		Scan(&record.Key, &record.Fingerprint, &record.Status, &header, &record.Body, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return types.IdempotencyRecord{}, fmt.Errorf("%w: no idempotency key %q", storage.ErrNotFound, key)
+		}
+
+		return types.IdempotencyRecord{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if header.Valid {
+		if err := json.Unmarshal([]byte(header.String), &record.Header); err != nil {
+			return types.IdempotencyRecord{}, fmt.Errorf("idempotency error: invalid header of key %q: %w", key, err)
+		}
+	}
+
+	return record, nil
+}
+
+// CompleteIdempotencyKey stores the response of a request; the headers are stored as JSON.
+func (s *Sqlite) CompleteIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) error {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.DB.ExecContext(ctx, "UPDATE idempotency_keys SET status = ?, header = ?, body = ?, expires_at = ? WHERE key = ? AND fingerprint = ? AND status = 0",
+		record.Status, string(header), record.Body, record.ExpiresAt.UTC(), record.Key, record.Fingerprint)
+	if err != nil {
+		return fmt.Errorf("idempotency error: %w", err)
+	}
+
+	completed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if completed == 0 {
+		return fmt.Errorf("%w: idempotency key %q is not claimed by a request being processed", storage.ErrNotFound, record.Key)
+	}
+
+	return nil
+}
+
+func (s *Sqlite) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	if _, err := s.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = ? AND status = 0", key); err != nil {
+		return fmt.Errorf("idempotency error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Sqlite) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("idempotency error: %w", err)
+	}
+
+	return result.RowsAffected()
+}
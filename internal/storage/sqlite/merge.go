@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// MergeStudents folds a student into another one in one transaction: the source is moved to the trash with
+// merged_into set, and both students get an audit entry for the merge.
+func (s *Sqlite) MergeStudents(ctx context.Context, sourceID int64, targetID int64, sourceVersion int64, targetVersion int64) (types.Student, error) {
+	if sourceID == targetID {
+		return types.Student{}, fmt.Errorf("%w: cannot merge student %d into itself", storage.ErrConflict, sourceID)
+	}
+
+	ctx, cancel := s.withTimeout(ctx) // Bound the queries by the configured query timeout
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil) // Starts with BEGIN IMMEDIATE, see Open, so neither student can change during the merge
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	defer tx.Rollback() // Roll back unless the transaction was committed
+
+	target, err := loadStudent(ctx, tx, targetID, false)
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	if targetVersion != 0 && target.Version != targetVersion {
+		return types.Student{}, fmt.Errorf("%w: student %d is at version %d, not %d", storage.ErrVersionMismatch, targetID, target.Version, targetVersion)
+	}
+
+	source, err := mutateTx(ctx, tx, types.AuditMerge, sourceID, sourceVersion, false, func(ctx context.Context, tx *sql.Tx, before types.Student, now time.Time) (*types.Student, error) {
+		_, err := tx.ExecContext(ctx, "UPDATE students SET deleted_at = ?, merged_into = ?, email_key = NULL, version = version + 1 WHERE id = ?", now, targetID, before.Id)
+		if err != nil {
+			return nil, fmt.Errorf("merge error: %w", translateError(err))
+		}
+
+		after := before
+		after.DeletedAt = &now
+		after.MergedInto = &targetID
+		after.Version++
+
+		return &after, nil
+	})
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	// A target that shared its email with the source before emails had to be unique takes the email over
+	emailKey := storage.EmailKey(target.Email)
+
+	_, err = tx.ExecContext(ctx, "UPDATE students SET email_key = ? WHERE id = ? AND email_key IS NULL AND NOT EXISTS (SELECT 1 FROM students WHERE email_key = ?)",
+		emailKey, targetID, emailKey)
+	if err != nil {
+		return types.Student{}, fmt.Errorf("merge error: %w", translateError(err))
+	}
+
+	// The target itself is unchanged, its entry records what was merged into it at the time of the merge
+	if err := insertAuditEntry(ctx, tx, storage.NewAuditEntry(ctx, types.AuditMerge, targetID, &target, &target, *source.DeletedAt)); err != nil {
+		return types.Student{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return types.Student{}, err
+	}
+
+	return target, nil
+}
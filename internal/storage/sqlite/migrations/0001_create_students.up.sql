@@ -0,0 +1,7 @@
+-- IF NOT EXISTS lets databases created before migrations existed adopt this one as-is.
+CREATE TABLE IF NOT EXISTS students (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	age INTEGER NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_students_name_id;
+DROP INDEX IF EXISTS idx_students_email_id;
+DROP INDEX IF EXISTS idx_students_age_id;
@@ -0,0 +1,4 @@
+-- Index every sortable column together with the ID so that keyset pagination does not scan the table.
+CREATE INDEX IF NOT EXISTS idx_students_name_id ON students (name, id);
+CREATE INDEX IF NOT EXISTS idx_students_email_id ON students (email, id);
+CREATE INDEX IF NOT EXISTS idx_students_age_id ON students (age, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_students_deleted_at;
+DELETE FROM students WHERE deleted_at IS NOT NULL;
+ALTER TABLE students DROP COLUMN deleted_at;
@@ -0,0 +1,23 @@
+-- Every change to a student is recorded here in the transaction that makes it. Entries are never changed or removed,
+-- not even when the student is purged, so there is no foreign key to students.
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	student_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	before_state TEXT,
+	after_state TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_student_id ON audit_log (student_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log BEGIN
+	SELECT RAISE(ABORT, 'audit log entries are immutable');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log BEGIN
+	SELECT RAISE(ABORT, 'audit log entries are immutable');
+END;
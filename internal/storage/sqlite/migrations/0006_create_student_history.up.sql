@@ -0,0 +1,20 @@
+-- Every version of a student, valid from valid_from up to but excluding valid_to. The current version has no valid_to.
+-- Rows are written in the transaction that changes the student and are kept when the student is purged.
+CREATE TABLE IF NOT EXISTS student_history (
+	student_id INTEGER NOT NULL,
+	version INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL,
+	age INTEGER NOT NULL,
+	deleted_at TIMESTAMP,
+	valid_from TIMESTAMP NOT NULL,
+	valid_to TIMESTAMP,
+	PRIMARY KEY (student_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_student_history_valid_from ON student_history (valid_from, valid_to);
+
+-- Existing students start their history now, their earlier versions were never recorded.
+-- The timestamp uses the format the driver writes time.Time values in so that they compare correctly as text.
+INSERT INTO student_history (student_id, version, name, email, age, deleted_at, valid_from)
+SELECT id, version, name, email, age, deleted_at, strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') FROM students;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_students_email_key;
+ALTER TABLE student_history DROP COLUMN merged_into;
+ALTER TABLE students DROP COLUMN merged_into;
+ALTER TABLE students DROP COLUMN email_key;
@@ -0,0 +1,9 @@
+-- Static API keys of the clients allowed to use the API. Only the SHA-256 of each key is stored.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	prefix TEXT NOT NULL,
+	hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP
+);
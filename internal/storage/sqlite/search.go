@@ -0,0 +1,221 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/textsearch"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// The full-text index is an external content FTS5 table over the students table, kept in sync by triggers.
+// FTS5 is only compiled into go-sqlite3 with the sqlite_fts5 build tag (go build -tags sqlite_fts5 ./...);
+// without it, search falls back to LIKE queries.
+const createSearchQuery = `
+CREATE VIRTUAL TABLE IF NOT EXISTS students_fts USING fts5(
+	name,
+	email,
+	content = 'students',
+	content_rowid = 'id',
+	tokenize = 'unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS students_fts_ai AFTER INSERT ON students BEGIN
+	INSERT INTO students_fts (rowid, name, email) VALUES (new.id, new.name, new.email);
+END;
+
+CREATE TRIGGER IF NOT EXISTS students_fts_ad AFTER DELETE ON students BEGIN
+	INSERT INTO students_fts (students_fts, rowid, name, email) VALUES ('delete', old.id, old.name, old.email);
+END;
+
+CREATE TRIGGER IF NOT EXISTS students_fts_au AFTER UPDATE ON students BEGIN
+	INSERT INTO students_fts (students_fts, rowid, name, email) VALUES ('delete', old.id, old.name, old.email);
+	INSERT INTO students_fts (rowid, name, email) VALUES (new.id, new.name, new.email);
+END;`
+
+// dropSearchTriggersQuery removes the triggers when FTS5 is not available, otherwise every write to students would fail.
+const dropSearchTriggersQuery = `
+DROP TRIGGER IF EXISTS students_fts_ai;
+DROP TRIGGER IF EXISTS students_fts_ad;
+DROP TRIGGER IF EXISTS students_fts_au;`
+
+// setupSearch creates the full-text index and its triggers and reports whether FTS5 is available.
+// The index is rebuilt from the students table whenever the triggers had to be (re)created, since it may have missed writes.
+func setupSearch(db *sql.DB) (bool, error) {
+	var available bool
+
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available); err != nil {
+		return false, err
+	}
+
+	if !available {
+		slog.Warn("SQLite was built without FTS5, student search falls back to LIKE queries; build with -tags sqlite_fts5 to enable it")
+
+		if _, err := db.Exec(dropSearchTriggersQuery); err != nil {
+			return false, fmt.Errorf("drop search triggers: %w", err)
+		}
+
+		return false, nil
+	}
+
+	var triggers int
+
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'students_fts_%'").Scan(&triggers)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(createSearchQuery); err != nil {
+		return false, fmt.Errorf("create search index: %w", err)
+	}
+
+	if triggers < 3 {
+		if _, err := db.Exec("INSERT INTO students_fts (students_fts) VALUES ('rebuild')"); err != nil {
+			return false, fmt.Errorf("rebuild search index: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (s *Sqlite) SearchStudents(ctx context.Context, query string, limit int, withEmails bool) ([]types.StudentSearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []types.StudentSearchResult{}, nil
+	}
+
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	if !s.fts {
+		return s.searchLike(ctx, terms, limit, withEmails)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT s.id, s.name, s.email, s.age, s.version, -bm25(students_fts),
+			highlight(students_fts, 0, char(2), char(3)),
+			highlight(students_fts, 1, char(2), char(3))
+		FROM students_fts
+		JOIN students s ON s.id = students_fts.rowid
+		WHERE students_fts MATCH ? AND s.deleted_at IS NULL
+		ORDER BY bm25(students_fts), s.id
+		LIMIT ?`, matchQuery(terms, withEmails), limit)
+	if err != nil {
+		return nil, fmt.Errorf("search error: %w", err)
+	}
+
+	defer rows.Close() // Ensure the rows are closed after use
+
+	results := []types.StudentSearchResult{}
+
+	for rows.Next() {
+		var result types.StudentSearchResult
+
+		err := rows.Scan(&result.Student.Id, &result.Student.Name, &result.Student.Email, &result.Student.Age, &result.Student.Version,
+			&result.Score, &result.Highlights.Name, &result.Highlights.Email)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+
+		// the matches are delimited by sentinels, so that the tags are only added once the text is escaped
+		result.Highlights.Name = textsearch.Highlighted(result.Highlights.Name)
+		result.Highlights.Email = textsearch.Highlighted(result.Highlights.Email)
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return results, nil
+}
+
+// matchQuery turns search terms into an FTS5 query matching every term as a prefix, so "ans gma" finds
+// "Ansh <ansh@gmail.com>". Terms are quoted so that FTS5 operators typed by users are matched literally.
+// Without withEmails, the query is limited to the name column.
+func matchQuery(terms []string, withEmails bool) string {
+	quoted := make([]string, len(terms))
+
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+
+	if !withEmails {
+		return "name : (" + strings.Join(quoted, " ") + ")"
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// likeCandidates is how many rows, per result asked for, the LIKE search reads and scores.
+const likeCandidates = 4
+
+// searchLike is the search used when FTS5 is not available: every term has to appear in the name, or with
+// withEmails the email, and matches are scored and highlighted by textsearch. Only the likeCandidates*limit
+// matches with the most terms starting a word of the name or the email are read, so that a search never scores
+// every student.
+func (s *Sqlite) searchLike(ctx context.Context, terms []string, limit int, withEmails bool) ([]types.StudentSearchResult, error) {
+	conditions := make([]string, len(terms))
+	scores := make([]string, 0, 2*len(terms))
+	args := make([]any, 0, 4*len(terms)+1)
+	scoreArgs := make([]any, 0, 2*len(terms))
+
+	for i, term := range terms {
+		// a term starts a word of the name when it follows a space, and a part of the email when it follows @
+		scores = append(scores, `((' ' || name) LIKE ? ESCAPE '\')`)
+		scoreArgs = append(scoreArgs, likeWordPattern(" ", term))
+
+		if !withEmails {
+			conditions[i] = `name LIKE ? ESCAPE '\'`
+			args = append(args, likePattern(term))
+
+			continue
+		}
+
+		conditions[i] = `(name LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\')`
+		args = append(args, likePattern(term), likePattern(term))
+
+		scores = append(scores, `(('@' || email) LIKE ? ESCAPE '\')`)
+		scoreArgs = append(scoreArgs, likeWordPattern("@", term))
+	}
+
+	args = append(append(args, scoreArgs...), likeCandidates*limit)
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, name, email, age, version FROM students WHERE deleted_at IS NULL AND "+strings.Join(conditions, " AND ")+
+		" ORDER BY "+strings.Join(scores, " + ")+" DESC, id LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("search error: %w", err)
+	}
+
+	defer rows.Close() // Ensure the rows are closed after use
+
+	matcher := textsearch.NewMatcher(terms)
+	results := []types.StudentSearchResult{}
+
+	for rows.Next() {
+		var student types.Student
+
+		err := rows.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.Version)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+
+		results = append(results, matcher.Result(student, withEmails))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	textsearch.Sort(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
@@ -0,0 +1,75 @@
+//go:build sqlite_fts5
+
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/sqlite"
+)
+
+// TestSearchFTS5 covers the ranking and highlights of the FTS5 index, which only exists with the sqlite_fts5
+// build tag: go test -tags sqlite_fts5 ./internal/storage/sqlite/
+func TestSearchFTS5(t *testing.T) {
+	s, err := sqlite.New(&config.Config{
+		StoragePath: filepath.Join(t.TempDir(), "students.db"),
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("sqlite.New: %v", err)
+	}
+
+	defer s.Close()
+
+	var tables int
+	if err := s.DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'students_fts'").Scan(&tables); err != nil || tables != 1 {
+		t.Fatalf("the students_fts index is missing (%v), search would fall back to LIKE queries", err)
+	}
+
+	for _, student := range []struct{ name, email string }{
+		{"Ann Lee", "lee@example.com"},
+		{"Ann Kim", "ann@example.com"},
+		{"Bob <b>Annex</b>", "bob@example.com"},
+		{"Joanna", "joanna@example.com"},
+	} {
+		if _, err := s.CreateStudent(t.Context(), student.name, student.email, 20); err != nil {
+			t.Fatalf("CreateStudent: %v", err)
+		}
+	}
+
+	results, err := s.SearchStudents(t.Context(), "ann", 10, true)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	// bm25 ranks the match on the name and the email first, terms match prefixes of tokens only
+	if len(results) != 3 || results[0].Student.Id != 2 {
+		t.Fatalf("search for ann returned %+v, want students 2, 1 and 3 with student 2 first", results)
+	}
+
+	for i, result := range results {
+		if result.Score <= 0 || (i > 0 && result.Score > results[i-1].Score) {
+			t.Fatalf("search for ann returned scores out of order: %+v", results)
+		}
+	}
+
+	if got := results[0].Highlights; got.Name != "<mark>Ann</mark> Kim" || got.Email != "<mark>ann</mark>@example.com" {
+		t.Fatalf("student 2 is highlighted as %+v", got)
+	}
+
+	if got := results[2].Highlights.Name; got != "Bob &lt;b&gt;<mark>Annex</mark>&lt;/b&gt;" {
+		t.Fatalf("student 3 is highlighted as %q, want the match marked and the rest escaped", got)
+	}
+
+	// without emails, the query is limited to the name column
+	results, err = s.SearchStudents(t.Context(), "lee", 10, false)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Student.Id != 1 || results[0].Highlights.Email != "lee@example.com" {
+		t.Fatalf("search of names for lee returned %+v, want student 1 without an email highlight", results)
+	}
+}
@@ -1,73 +1,210 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/migrate"
 	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
 
-	_ "github.com/mattn/go-sqlite3" // Import the SQLite driver
-	// use of _ means we are importing the package solely for its side effects (registering the driver) - i.e., indirect usess
+	"github.com/mattn/go-sqlite3" // Import the SQLite driver, which registers itself with database/sql and provides its error types
 )
 
 type Sqlite struct {
 	DB *sql.DB
+
+	queryTimeout  time.Duration // upper bound for every query, see withTimeout
+	exportTimeout time.Duration // upper bound for streaming students, see StreamStudents
+
+	fts bool // whether the students_fts full-text index is available, see search.go
 }
 
+// migrationFiles holds the numbered schema migrations of the SQLite storage, see Migrator.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// New opens the SQLite database configured in cfg, brings its schema up to date when cfg.AutoMigrate is set
+// and prepares the full-text search index. Without auto migration, New fails if there are pending migrations.
 func New(cfg *config.Config) (*Sqlite, error) {
-	db, err := sql.Open("sqlite3", cfg.StoragePath)
+	s, err := Open(cfg.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	s.queryTimeout = cfg.QueryTimeout
+	s.exportTimeout = cfg.ExportTimeout
+
+	migrator, err := s.Migrator()
 	if err != nil {
 		return nil, err
 	}
 
-	// Create the students table if it doesn't exist
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS students (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		email TEXT NOT NULL,
-		age INTEGER NOT NULL
-	);`
+	ctx := context.Background()
 
-	if _, err := db.Exec(createTableQuery); err != nil {
+	if cfg.AutoMigrate {
+		if _, err := migrator.Up(ctx); err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+	} else {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+
+		if len(pending) > 0 {
+			return nil, fmt.Errorf("database schema is out of date, %d migrations are pending: run the migrate up command or enable auto_migrate", len(pending))
+		}
+	}
+
+	s.fts, err = setupSearch(s.DB) // Create the full-text index over students when the driver supports it
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Open opens the SQLite database at path without touching its schema.
+func Open(path string) (*Sqlite, error) {
+	// Transactions start with BEGIN IMMEDIATE so that a write transaction holds the write lock from its first read:
+	// the checks it makes cannot be invalidated by another writer, and it never fails upgrading a read lock.
+	dsn := path + "?_txlock=immediate"
+	if strings.Contains(path, "?") {
+		dsn = path + "&_txlock=immediate"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
 		return nil, err
 	}
 
 	return &Sqlite{
 		DB: db,
 	}, nil
+}
+
+// withTimeout bounds a query by the configured query timeout, on top of any deadline ctx already has.
+func (s *Sqlite) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
 
+	return context.WithTimeout(ctx, s.queryTimeout)
 }
 
-func (s *Sqlite) CreateStudent(name string, email string, age int) (int64, error) {
+// withExportTimeout bounds a stream of students by the configured export timeout, on top of any deadline ctx already
+// has. Streams outlive the query timeout, as they last as long as their reader takes to consume the rows.
+func (s *Sqlite) withExportTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.exportTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
 
-	// Prepare the SQL statement to insert a new student
-	stmt, err := s.DB.Prepare("INSERT INTO students (name, email, age) VALUES (?, ?, ?)") // ? are placeholders for the values to be inserted
+	return context.WithTimeout(ctx, s.exportTimeout)
+}
 
+// Migrator returns the migrator managing the schema of the database.
+func (s *Sqlite) Migrator() (*migrate.Migrator, error) {
+	migrations, err := fs.Sub(migrationFiles, "migrations")
 	if err != nil {
-		return 0, err // Return an error if the statement preparation fails
+		return nil, err
 	}
-	defer stmt.Close() // Ensure the statement is closed after use
 
-	// Execute the statement with the provided values
-	result, err := stmt.Exec(name, email, age)
+	return migrate.New(s.DB, migrate.SQLite, migrations)
+}
+
+func (s *Sqlite) CreateStudent(ctx context.Context, name string, email string, age int) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil) // The student and its audit entry are written in one transaction
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback() // Roll back unless the transaction was committed
+
+	created, err := insertStudent(ctx, tx, name, email, age)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Return the last inserted ID and no error
+	return created.Id, nil
+}
+
+// insertStudent inserts a new student within a transaction, along with its audit entry and first version.
+func insertStudent(ctx context.Context, tx *sql.Tx, name string, email string, age int) (types.Student, error) {
+	emailKey, err := claimEmail(ctx, tx, 0, email) // Fail with the ID of the student already using the email, if any
 	if err != nil {
-		return 0, err // Return an error if the execution fails
+		return types.Student{}, err
+	}
+
+	// Execute the statement to insert a new student with the provided values
+	result, err := tx.ExecContext(ctx, "INSERT INTO students (name, email, email_key, age) VALUES (?, ?, ?, ?)", name, email, emailKey, age) // ? are placeholders for the values to be inserted
+	if err != nil {
+		return types.Student{}, translateError(err) // Return an error if the execution fails
 	}
 
 	// Get the last inserted ID
 	lastId, err := result.LastInsertId()
 	if err != nil {
-		return 0, err // Return an error if retrieving the last inserted ID fails
+		return types.Student{}, err // Return an error if retrieving the last inserted ID fails
 	}
 
-	// Return the last inserted ID and no error
-	return lastId, nil
+	created := types.Student{Id: lastId, Name: name, Email: email, Age: age, Version: 1}
+	now := time.Now().UTC()
+
+	if err := insertAuditEntry(ctx, tx, storage.NewAuditEntry(ctx, types.AuditCreate, lastId, nil, &created, now)); err != nil {
+		return types.Student{}, err
+	}
+
+	if err := recordVersion(ctx, tx, lastId, &created, now); err != nil {
+		return types.Student{}, err
+	}
+
+	return created, nil
+}
+
+// claimEmail checks within a transaction that no live student other than the one with the given ID uses an email,
+// and returns the key to store in email_key for it. The unique index on email_key enforces the same rule, but
+// checking first tells which student the email belongs to. The transaction holds the write lock, see Open, so the
+// check cannot be invalidated before the write.
+func claimEmail(ctx context.Context, tx *sql.Tx, id int64, email string) (string, error) {
+	key := storage.EmailKey(email)
+
+	var owner int64
+
+	err := tx.QueryRowContext(ctx, "SELECT id FROM students WHERE email_key = ? AND id != ?", key, id).Scan(&owner)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return key, nil // Nobody else uses the email
+	case err != nil:
+		return "", fmt.Errorf("query error: %w", err)
+	}
+
+	return "", &storage.DuplicateEmailError{Email: email, ID: owner}
 }
 
-func (s *Sqlite) GetStudentByID(id int64) (types.Student, error) {
-	stmt, err := s.DB.Prepare("SELECT id, name, email, age FROM students WHERE id = ? LIMIT 1") // Prepare the SQL statement to select a student by ID
+func (s *Sqlite) GetStudentByID(ctx context.Context, id int64) (types.Student, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
+
+	stmt, err := s.DB.PrepareContext(ctx, "SELECT id, name, email, age, version FROM students WHERE id = ? AND deleted_at IS NULL LIMIT 1") // Prepare the SQL statement to select a live student by ID
 	if err != nil {
 		return types.Student{}, err // Return an empty Student struct and an error if preparation fails
 	}
@@ -76,11 +213,11 @@ func (s *Sqlite) GetStudentByID(id int64) (types.Student, error) {
 
 	var student types.Student // Create a Student struct to hold the retrieved data
 
-	err = stmt.QueryRow(id).Scan(&student.Id, &student.Name, &student.Email, &student.Age) // Execute the query and scan the result into the Student struct
+	err = stmt.QueryRowContext(ctx, id).Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.Version) // Execute the query and scan the result into the Student struct
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return types.Student{}, fmt.Errorf("student with ID %d not found", id) // Return an empty Student struct and a not found error if no rows are returned
+			return types.Student{}, fmt.Errorf("%w: no student with ID %d", storage.ErrNotFound, id) // Return an empty Student struct and a not found error if no rows are returned
 		}
 
 		return types.Student{}, fmt.Errorf("query error: %w", err) // Return an empty Student struct and an error if the query fails
@@ -90,55 +227,326 @@ func (s *Sqlite) GetStudentByID(id int64) (types.Student, error) {
 	return student, nil
 }
 
-func (s *Sqlite) GetStudents() ([]types.Student, error) {
+// sortColumns maps the sortable fields of a student to their columns in the students table.
+// Only columns listed here are ever interpolated into an ORDER BY clause.
+var sortColumns = map[string]string{
+	types.SortByID:    "id",
+	types.SortByName:  "name",
+	types.SortByEmail: "email",
+	types.SortByAge:   "age",
+}
+
+// filterClause builds the WHERE clause and its arguments for the given student filter.
+func filterClause(filter types.StudentFilter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if filter.Deleted {
+		conditions = append(conditions, "deleted_at IS NOT NULL") // List the trash
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL") // Soft-deleted students are hidden from listings
+	}
+
+	if filter.Name != "" {
+		conditions = append(conditions, `name LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(filter.Name))
+	}
+
+	if filter.Email != "" {
+		conditions = append(conditions, `email LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(filter.Email))
+	}
+
+	if filter.MinAge != nil {
+		conditions = append(conditions, "age >= ?")
+		args = append(args, *filter.MinAge)
+	}
+
+	if filter.MaxAge != nil {
+		conditions = append(conditions, "age <= ?")
+		args = append(args, *filter.MaxAge)
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// likePattern turns a search term into a LIKE pattern matching it anywhere, escaping LIKE wildcards in the term.
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+
+	return "%" + escaped + "%"
+}
+
+// likeWordPattern returns a LIKE pattern, to be used with ESCAPE '\', matching term right after sep.
+func likeWordPattern(sep string, term string) string {
+	return "%" + sep + strings.TrimPrefix(likePattern(term), "%")
+}
+
+// sortColumn returns the column to sort by for the given student filter.
+func sortColumn(filter types.StudentFilter) (string, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = types.SortByID
+	}
+
+	column, ok := sortColumns[sort]
+	if !ok {
+		return "", fmt.Errorf("unsupported sort field %q", sort)
+	}
+
+	return column, nil
+}
+
+// descending reports whether rows must be read in descending order. Walking backwards from a cursor
+// reads the rows in the opposite of the requested order, they are put back in order once scanned.
+func descending(filter types.StudentFilter) bool {
+	if filter.Cursor != nil && filter.Cursor.Backward {
+		return !filter.Desc
+	}
+
+	return filter.Desc
+}
+
+// orderClause builds the ORDER BY clause for the given sort column, using the ID as a tie-breaker so that pages are stable.
+func orderClause(column string, desc bool) string {
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	if column == "id" {
+		return fmt.Sprintf(" ORDER BY id %s", direction)
+	}
+
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+}
+
+// keysetCondition builds the condition selecting the rows that come after the cursor in the order the rows are read.
+func keysetCondition(column string, desc bool, cursor *types.StudentCursor) (string, []any) {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	if column == "id" {
+		return "id " + op + " ?", []any{cursor.ID}
+	}
+
+	return fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, op, column, op), []any{cursor.Value, cursor.Value, cursor.ID}
+}
+
+func (s *Sqlite) GetStudents(ctx context.Context, filter types.StudentFilter) ([]types.Student, int64, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the query by the configured query timeout
+	defer cancel()
 
-	// Prepare the SQL statement to select all students
-	stmt, err := s.DB.Prepare("SELECT id, name, email, age FROM students")
+	source, args := historySource(filter) // The students table, or its history when listing as of a past time
+	where, filterArgs := filterClause(filter)
+	args = append(args, filterArgs...)
 
+	column, err := sortColumn(filter)
 	if err != nil {
-		return nil, err // Return nil and an error if the statement preparation fails
+		return nil, 0, err
 	}
 
-	defer stmt.Close() // Ensure the statement is closed after use
+	var total int64 // Count every matching student so that callers can render pagers
 
-	rows, err := stmt.Query() // Execute the query to get all students
+	err = s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+source+where, args...).Scan(&total)
 	if err != nil {
-		return nil, err // Return nil and an error if the query execution fails
+		return nil, 0, fmt.Errorf("count error: %w", err)
+	}
+
+	desc := descending(filter)
+	offset := filter.Offset
+
+	if filter.Cursor != nil {
+		condition, keysetArgs := keysetCondition(column, desc, filter.Cursor)
+
+		where += " AND " + condition
+
+		args = append(args, keysetArgs...)
+		offset = 0 // The cursor already marks where the page starts
+	}
+
+	query := "SELECT id, name, email, age, version, deleted_at, merged_into FROM " + source + where + orderClause(column, desc) + " LIMIT ? OFFSET ?"
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // A negative LIMIT means no limit in SQLite
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, append(args, limit, offset)...) // Execute the query to get one page of students
+	if err != nil {
+		return nil, 0, err // Return nil and an error if the query execution fails
 	}
 
 	defer rows.Close() // Ensure the rows are closed after use
 
-	var students []types.Student // Create a slice to hold the retrieved students
+	students := []types.Student{} // Create a slice to hold the retrieved students
 
 	for rows.Next() { // Iterate over the rows returned by the query
-		var student types.Student // Create a Student struct to hold the data for each row
-
-		err := rows.Scan(&student.Id, &student.Name, &student.Email, &student.Age) // Scan the row data into the Student struct
+		student, err := scanStudent(rows) // Scan the row data into a Student struct
 		if err != nil {
-			return nil, fmt.Errorf("scan error: %w", err) // Return nil and an error if scanning fails
+			return nil, 0, err // Return nil and an error if scanning fails
 		}
 
 		students = append(students, student) // Append the Student struct to the slice of students
 	}
 
-	return students, nil // Return the slice of students and no error
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	if desc != filter.Desc {
+		slices.Reverse(students) // Rows read backwards from a cursor are put back in the requested order
+	}
+
+	return students, total, nil // Return the page of students, the total count and no error
 }
 
-func (s *Sqlite) UpdateStudent(id int64, name string, email string, age int) error {
-	stmt, err := s.DB.Prepare("UPDATE students SET name = ?, email = ?, age = ? WHERE id = ?") // Prepare the SQL statement to update a student
+// StreamStudents calls fn with every student matching the filter, in the order of the filter, as the rows are read.
+func (s *Sqlite) StreamStudents(ctx context.Context, filter types.StudentFilter, fn func(types.Student) error) error {
+	ctx, cancel := s.withExportTimeout(ctx) // Bound the stream by the configured export timeout, a slow reader would keep the database locked otherwise
+	defer cancel()
+
+	source, args := historySource(filter) // The students table, or its history when listing as of a past time
+	where, filterArgs := filterClause(filter)
+	args = append(args, filterArgs...)
+
+	column, err := sortColumn(filter)
 	if err != nil {
-		return err // Return an error if the statement preparation fails
+		return err
 	}
 
-	defer stmt.Close() // Ensure the statement is closed after use
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, name, email, age, version, deleted_at, merged_into FROM "+source+where+orderClause(column, filter.Desc), args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close() // Ensure the rows are closed after use, also when fn stops the stream
+
+	for rows.Next() {
+		student, err := scanStudent(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(student); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	return nil
+}
+
+// scanStudent reads a student selected as id, name, email, age, version, deleted_at, merged_into.
+func scanStudent(rows *sql.Rows) (types.Student, error) {
+	var student types.Student
+	var deletedAt sql.NullTime
+	var mergedInto sql.NullInt64
+
+	if err := rows.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.Version, &deletedAt, &mergedInto); err != nil {
+		return types.Student{}, fmt.Errorf("scan error: %w", err)
+	}
+
+	if deletedAt.Valid {
+		student.DeletedAt = &deletedAt.Time // Only students in the trash have a deletion time
+	}
+
+	if mergedInto.Valid {
+		student.MergedInto = &mergedInto.Int64 // Only students merged into another one have a merge target
+	}
+
+	return student, nil
+}
+
+func (s *Sqlite) UpdateStudent(ctx context.Context, id int64, name string, email string, age int, version int64) error {
+	_, err := s.mutate(ctx, types.AuditUpdate, id, version, false, updateStudent(name, email, age))
+
+	return err
+}
+
+// updateStudent returns the write of a full update of a student, for mutate.
+func updateStudent(name string, email string, age int) writeFunc {
+	return func(ctx context.Context, tx *sql.Tx, before types.Student, now time.Time) (*types.Student, error) {
+		emailKey, err := claimEmail(ctx, tx, before.Id, email)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE students SET name = ?, email = ?, email_key = ?, age = ?, version = version + 1 WHERE id = ?", name, email, emailKey, age, before.Id) // Execute the statement with the provided values
+		if err != nil {
+			return nil, fmt.Errorf("update error: %w", translateError(err)) // Return an error if the execution fails
+		}
+
+		return &types.Student{Id: before.Id, Name: name, Email: email, Age: age, Version: before.Version + 1}, nil
+	}
+}
+
+func (s *Sqlite) PatchStudent(ctx context.Context, id int64, patch types.StudentPatch, version int64) (types.Student, error) {
+	if patch.IsEmpty() {
+		student, err := s.GetStudentByID(ctx, id) // Nothing to write, return the student as it is
+		if err == nil && version != 0 && student.Version != version {
+			return types.Student{}, fmt.Errorf("%w: student %d is at version %d, not %d", storage.ErrVersionMismatch, id, student.Version, version)
+		}
+
+		return student, err
+	}
+
+	student, err := s.mutate(ctx, types.AuditUpdate, id, version, false, func(ctx context.Context, tx *sql.Tx, before types.Student, now time.Time) (*types.Student, error) {
+		assignments, args := patchAssignments(patch) // Only the columns set in the patch are written
+
+		if patch.Email != nil {
+			emailKey, err := claimEmail(ctx, tx, id, *patch.Email)
+			if err != nil {
+				return nil, err
+			}
+
+			assignments = append(assignments, "email_key = ?")
+			args = append(args, emailKey)
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE students SET "+strings.Join(assignments, ", ")+", version = version + 1 WHERE id = ?", append(args, id)...)
+		if err != nil {
+			return nil, fmt.Errorf("patch error: %w", translateError(err))
+		}
 
-	// Execute the statement with the provided values
-	_, err = stmt.Exec(name, email, age, id)
+		after := patch.Apply(before)
+		after.Version++
+
+		return &after, nil
+	})
 	if err != nil {
-		return fmt.Errorf("update error: %w", err) // Return an error if the execution fails
+		return types.Student{}, err
 	}
 
-	return nil // Return no error if the update is successful
+	return *student, nil
+}
+
+// patchAssignments builds the SET assignments and their arguments for the fields set in a patch.
+func patchAssignments(patch types.StudentPatch) ([]string, []any) {
+	var assignments []string
+	var args []any
+
+	if patch.Name != nil {
+		assignments = append(assignments, "name = ?")
+		args = append(args, *patch.Name)
+	}
+
+	if patch.Email != nil {
+		assignments = append(assignments, "email = ?")
+		args = append(args, *patch.Email)
+	}
+
+	if patch.Age != nil {
+		assignments = append(assignments, "age = ?")
+		args = append(args, *patch.Age)
+	}
+
+	return assignments, args
 }
 
 func (s *Sqlite) Close() error {
@@ -148,20 +556,163 @@ func (s *Sqlite) Close() error {
 	return nil // Return nil if the database connection is already nil
 }
 
-// DeleteStudent deletes a student by ID from the storage.
-func (s *Sqlite) DeleteStudent(id int64) error {
-	stmt, err := s.DB.Prepare("DELETE FROM students WHERE id = ?") // Prepare the SQL statement to delete a student by ID
+// DeleteStudent soft-deletes a student by ID, moving it to the trash.
+func (s *Sqlite) DeleteStudent(ctx context.Context, id int64, version int64) error {
+	_, err := s.mutate(ctx, types.AuditDelete, id, version, false, deleteStudent)
+
+	return err
+}
+
+// deleteStudent is the write of a soft delete, for mutate.
+func deleteStudent(ctx context.Context, tx *sql.Tx, before types.Student, now time.Time) (*types.Student, error) {
+	_, err := tx.ExecContext(ctx, "UPDATE students SET deleted_at = ?, email_key = NULL, version = version + 1 WHERE id = ?", now, before.Id) // Students in the trash give up their email
 	if err != nil {
-		return err // Return an error if the statement preparation fails
+		return nil, fmt.Errorf("delete error: %w", translateError(err)) // Return an error if the execution fails
 	}
 
-	defer stmt.Close() // Ensure the statement is closed after use
+	after := before
+	after.DeletedAt = &now
+	after.Version++
+
+	return &after, nil
+}
+
+// RestoreStudent moves a soft-deleted student out of the trash and returns it.
+func (s *Sqlite) RestoreStudent(ctx context.Context, id int64, version int64) (types.Student, error) {
+	student, err := s.mutate(ctx, types.AuditRestore, id, version, true, func(ctx context.Context, tx *sql.Tx, before types.Student, now time.Time) (*types.Student, error) {
+		emailKey, err := claimEmail(ctx, tx, id, before.Email) // The email may have been taken while the student was in the trash
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE students SET deleted_at = NULL, merged_into = NULL, email_key = ?, version = version + 1 WHERE id = ?", emailKey, id)
+		if err != nil {
+			return nil, fmt.Errorf("restore error: %w", translateError(err))
+		}
+
+		after := before
+		after.DeletedAt = nil
+		after.MergedInto = nil // Restoring a merged student undoes the merge
+		after.Version++
+
+		return &after, nil
+	})
+	if err != nil {
+		return types.Student{}, err
+	}
+
+	return *student, nil
+}
+
+// PurgeStudent permanently deletes a soft-deleted student. Its audit entries are kept.
+func (s *Sqlite) PurgeStudent(ctx context.Context, id int64, version int64) error {
+	_, err := s.mutate(ctx, types.AuditPurge, id, version, true, func(ctx context.Context, tx *sql.Tx, before types.Student, now time.Time) (*types.Student, error) {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM students WHERE id = ?", id); err != nil {
+			return nil, fmt.Errorf("purge error: %w", translateError(err))
+		}
+
+		return nil, nil // There is no student after a purge
+	})
+
+	return err
+}
+
+// writeFunc makes a change to a student within the transaction of mutate and returns the student as it is
+// afterwards, nil if it is gone.
+type writeFunc func(ctx context.Context, tx *sql.Tx, before types.Student, now time.Time) (*types.Student, error)
+
+// mutate runs a write to the student with the given ID in a transaction that also records it in the audit log.
+// See mutateTx.
+func (s *Sqlite) mutate(ctx context.Context, action string, id int64, version int64, deleted bool, write writeFunc) (*types.Student, error) {
+	ctx, cancel := s.withTimeout(ctx) // Bound the queries by the configured query timeout
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil) // Starts with BEGIN IMMEDIATE, see Open, so nobody can change the student between the check and the write
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback() // Roll back unless the transaction was committed
+
+	after, err := mutateTx(ctx, tx, action, id, version, deleted, write)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// mutateTx runs a write to the student with the given ID within a transaction and records it in the audit log and
+// the history of the student. It loads the live (or, with deleted set, soft-deleted) student and checks that it is
+// at the expected version unless that is 0, then write makes the change.
+func mutateTx(ctx context.Context, tx *sql.Tx, action string, id int64, version int64, deleted bool, write writeFunc) (*types.Student, error) {
+	before, err := loadStudent(ctx, tx, id, deleted)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && before.Version != version {
+		return nil, fmt.Errorf("%w: student %d is at version %d, not %d", storage.ErrVersionMismatch, id, before.Version, version)
+	}
+
+	now := time.Now().UTC()
 
-	// Execute the statement with the provided ID
-	_, err = stmt.Exec(id)
+	after, err := write(ctx, tx, before, now)
 	if err != nil {
-		return fmt.Errorf("delete error: %w", err) // Return an error if the execution fails
+		return nil, err
+	}
+
+	if err := insertAuditEntry(ctx, tx, storage.NewAuditEntry(ctx, action, id, &before, after, now)); err != nil {
+		return nil, err
+	}
+
+	if err := recordVersion(ctx, tx, id, after, now); err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// loadStudent reads the live (or, with deleted set, soft-deleted) student with the given ID within a transaction.
+func loadStudent(ctx context.Context, tx *sql.Tx, id int64, deleted bool) (types.Student, error) {
+	var student types.Student
+	var deletedAt sql.NullTime
+	var mergedInto sql.NullInt64
+
+	err := tx.QueryRowContext(ctx, "SELECT id, name, email, age, version, deleted_at, merged_into FROM students WHERE id = ? AND (deleted_at IS NOT NULL) = ?", id, deleted).
+		Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.Version, &deletedAt, &mergedInto)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows) && deleted:
+		return types.Student{}, fmt.Errorf("%w: no deleted student with ID %d", storage.ErrNotFound, id)
+	case errors.Is(err, sql.ErrNoRows):
+		return types.Student{}, fmt.Errorf("%w: no student with ID %d", storage.ErrNotFound, id)
+	case err != nil:
+		return types.Student{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if deletedAt.Valid {
+		student.DeletedAt = &deletedAt.Time
+	}
+
+	if mergedInto.Valid {
+		student.MergedInto = &mergedInto.Int64
+	}
+
+	return student, nil
+}
+
+// translateError maps SQLite constraint violations to storage.ErrConflict and returns other errors unchanged.
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		return fmt.Errorf("%w: %s", storage.ErrConflict, sqliteErr.Error())
 	}
 
-	return nil // Return no error if the deletion is successful
+	return err
 }
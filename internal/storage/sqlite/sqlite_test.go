@@ -0,0 +1,155 @@
+package sqlite_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/sqlite"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage/storagetest"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := sqlite.New(&config.Config{
+			StoragePath: filepath.Join(t.TempDir(), "students.db"),
+			AutoMigrate: true,
+		})
+		if err != nil {
+			t.Fatalf("sqlite.New: %v", err)
+		}
+
+		t.Cleanup(func() { s.Close() })
+
+		return s
+	})
+}
+
+func TestAuditLogIsImmutable(t *testing.T) {
+	s, err := sqlite.New(&config.Config{
+		StoragePath: filepath.Join(t.TempDir(), "students.db"),
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("sqlite.New: %v", err)
+	}
+
+	defer s.Close()
+
+	if _, err := s.CreateStudent(t.Context(), "Ansh", "ansh@example.com", 21); err != nil {
+		t.Fatalf("CreateStudent: %v", err)
+	}
+
+	if _, err := s.DB.Exec("UPDATE audit_log SET actor = 'mallory'"); err == nil {
+		t.Error("updating the audit log succeeded, want an error")
+	}
+
+	if _, err := s.DB.Exec("DELETE FROM audit_log"); err == nil {
+		t.Error("deleting from the audit log succeeded, want an error")
+	}
+}
+
+func TestUniqueEmailMigrationKeepsDuplicates(t *testing.T) {
+	s, err := sqlite.Open(filepath.Join(t.TempDir(), "students.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	defer s.Close()
+
+	migrator, err := s.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator: %v", err)
+	}
+
+	if _, err := migrator.To(t.Context(), 6); err != nil {
+		t.Fatalf("migrating to version 6: %v", err)
+	}
+
+	// A roster with duplicate emails, as stored before they were rejected
+	_, err = s.DB.Exec(`INSERT INTO students (name, email, age) VALUES
+		('Ansh', 'ansh@example.com', 21), ('Ansh Singh', 'Ansh@Example.com', 21), ('Other', 'other@example.com', 30)`)
+	if err != nil {
+		t.Fatalf("seeding students: %v", err)
+	}
+
+	if _, err := migrator.Up(t.Context()); err != nil {
+		t.Fatalf("Up with duplicate emails: %v", err)
+	}
+
+	// The duplicate stays until it is merged, new students cannot join it
+	_, err = s.CreateStudent(t.Context(), "Third", "ANSH@example.com", 20)
+
+	var duplicate *storage.DuplicateEmailError
+	if !errors.As(err, &duplicate) || duplicate.ID != 1 {
+		t.Fatalf("CreateStudent with a duplicated email returned %v, want a duplicate of student 1", err)
+	}
+
+	if _, err := s.MergeStudents(t.Context(), 1, 2, 0, 0); err != nil {
+		t.Fatalf("MergeStudents: %v", err)
+	}
+
+	// The target took the email over from the student merged into it
+	err = s.UpdateStudent(t.Context(), 3, "Other", "ansh@example.com", 30, 0)
+	if !errors.As(err, &duplicate) || duplicate.ID != 2 {
+		t.Fatalf("UpdateStudent to the merged email returned %v, want a duplicate of student 2", err)
+	}
+}
+
+func TestStreamStudentsTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		exportTimeout time.Duration
+		wantErr       bool
+	}{
+		{"outlives the query timeout", 0, false},
+		{"bounded by the export timeout", 30 * time.Millisecond, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := sqlite.New(&config.Config{
+				StoragePath:   filepath.Join(t.TempDir(), "students.db"),
+				AutoMigrate:   true,
+				QueryTimeout:  time.Millisecond,
+				ExportTimeout: tt.exportTimeout,
+			})
+			if err != nil {
+				t.Fatalf("sqlite.New: %v", err)
+			}
+
+			defer s.Close()
+
+			for _, name := range []string{"Ann", "Bob", "Carol", "Dan", "Eve"} {
+				if _, err := s.DB.Exec("INSERT INTO students (name, email, age) VALUES (?, ?, 20)", name, name+"@example.com"); err != nil {
+					t.Fatalf("seeding students: %v", err)
+				}
+			}
+
+			var streamed int
+
+			err = s.StreamStudents(t.Context(), types.StudentFilter{}, func(types.Student) error {
+				time.Sleep(20 * time.Millisecond) // a slow reader, such as a client downloading an export
+				streamed++
+
+				return nil
+			})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("StreamStudents streamed %d students past the export timeout", streamed)
+				}
+
+				return
+			}
+
+			if err != nil || streamed != 5 {
+				t.Fatalf("StreamStudents streamed %d students, %v, want all 5", streamed, err)
+			}
+		})
+	}
+}
@@ -1,20 +1,181 @@
 package storage
 
-import "github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// Errors returned by Storage implementations. Implementations wrap them with details,
+// so callers should compare with errors.Is.
+var (
+	// ErrNotFound is returned when the requested student does not exist.
+	ErrNotFound = errors.New("student not found")
+
+	// ErrConflict is returned when a change would violate a constraint of the storage, e.g. a duplicate key.
+	ErrConflict = errors.New("student conflict")
+
+	// ErrVersionMismatch is returned when a write expected the student at another version than the stored one,
+	// i.e. somebody else changed the student since the caller read it.
+	ErrVersionMismatch = errors.New("student version mismatch")
+
+	// ErrBatchAborted is the result of the operations of an atomic batch that were rolled back, or never run,
+	// because another operation of the batch failed.
+	ErrBatchAborted = errors.New("batch aborted")
+)
+
+// DuplicateEmailError is the ErrConflict of a write that would give a student the email of another live student.
+// Emails are compared case-insensitively, see EmailKey.
+type DuplicateEmailError struct {
+	Email string
+	ID    int64 // ID of the live student already using the email
+}
+
+func (e *DuplicateEmailError) Error() string {
+	return fmt.Sprintf("%v: email %s is already used by student %d", ErrConflict, e.Email, e.ID)
+}
+
+// Is makes errors.Is(err, ErrConflict) hold for duplicate emails.
+func (e *DuplicateEmailError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// EmailKey returns the form of an email that live students must not share: two emails with the same key are the
+// same address to the storage.
+func EmailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// BatchResult is the outcome of one operation of a batch write.
+type BatchResult struct {
+	ID      int64 // ID of the created, updated or deleted student, 0 if the operation failed
+	Version int64 // version of the student after the operation
+	Err     error // nil if the operation was applied
+}
+
+// AbortBatch fills in the results of an atomic batch whose operation at index failed with err: every other
+// operation fails with ErrBatchAborted.
+func AbortBatch(results []BatchResult, index int, err error) []BatchResult {
+	for i := range results {
+		results[i] = BatchResult{Err: ErrBatchAborted}
+	}
+
+	results[index].Err = err
+
+	return results
+}
+
+// Storage is implemented by every storage backend. Every method takes the context of the request it serves,
+// so a disconnected client or a server shutdown cancels the queries it started.
+//
+// Deleting a student only moves it to the trash: soft-deleted students are left out by every method unless it
+// asks for them, until they are restored or purged.
+//
+// Every write is recorded in the audit log, in the same transaction, under the AuditInfo of its context.
+// It also closes the current version of the student in its history and opens the next one, so that past states
+// can be read back with GetStudentAsOf, GetStudentVersion and StudentFilter.AsOf.
+//
+// No two live students share an email, compared by EmailKey: writes that would break that fail with a
+// *DuplicateEmailError. Students stored before the rule was enforced may still share one until they are merged.
+//
+// Every write bumps the version of a student. Writes take the version the caller expects the student to be at
+// and fail with ErrVersionMismatch if it is at another one; a version of 0 skips the check.
 type Storage interface {
 	// CreateStudent creates a new student in the storage.
-	CreateStudent(name string, email string, age int) (int64, error)
+	CreateStudent(ctx context.Context, name string, email string, age int) (int64, error)
 
 	// GetStudentByID retrieves a student by ID from the storage.
-	GetStudentByID(id int64) (types.Student, error)
+	GetStudentByID(ctx context.Context, id int64) (types.Student, error)
 
-	// GetStudents retrieves all students from the storage.
-	GetStudents() ([]types.Student, error)
+	// GetStudentAsOf retrieves a student as it was at the given time. It returns ErrNotFound if the student did
+	// not exist yet, or was in the trash, at that time.
+	GetStudentAsOf(ctx context.Context, id int64, at time.Time) (types.Student, error)
+
+	// GetStudentVersion retrieves the given version of a student from its history, including versions in which
+	// the student was in the trash and versions of purged students.
+	GetStudentVersion(ctx context.Context, id int64, version int64) (types.Student, error)
+
+	// GetStudents retrieves one page of students matching the filter, along with the total number of matching students.
+	// It lists soft-deleted students instead of live ones when filter.Deleted is set,
+	// and the students as they were at filter.AsOf when that is set.
+	GetStudents(ctx context.Context, filter types.StudentFilter) ([]types.Student, int64, error)
+
+	// StreamStudents calls fn with every student matching the filter, in the order of the filter, reading them from
+	// the storage as it goes instead of loading them all first. The pagination fields of the filter are ignored.
+	// It stops at the first error returned by fn and returns it.
+	StreamStudents(ctx context.Context, filter types.StudentFilter, fn func(types.Student) error) error
+
+	// SearchStudents runs a full-text search over the names of students, and their emails when withEmails is set, and
+	// returns at most limit matches, best first.
+	SearchStudents(ctx context.Context, query string, limit int, withEmails bool) ([]types.StudentSearchResult, error)
 
 	// UpdateStudent updates an existing student in the storage.
-	UpdateStudent(id int64, name string, email string, age int) error
+	UpdateStudent(ctx context.Context, id int64, name string, email string, age int, version int64) error
+
+	// PatchStudent updates only the fields set in the patch and returns the resulting student.
+	// An empty patch changes nothing and returns the student as it is.
+	PatchStudent(ctx context.Context, id int64, patch types.StudentPatch, version int64) (types.Student, error)
+
+	// DeleteStudent soft-deletes a student by ID, moving it to the trash.
+	DeleteStudent(ctx context.Context, id int64, version int64) error
+
+	// RestoreStudent moves a soft-deleted student out of the trash and returns it.
+	RestoreStudent(ctx context.Context, id int64, version int64) (types.Student, error)
+
+	// PurgeStudent permanently deletes a soft-deleted student. Live students have to be deleted first.
+	PurgeStudent(ctx context.Context, id int64, version int64) error
+
+	// MergeStudents folds the live student sourceID into the live student targetID and returns the target. The source
+	// is moved to the trash with MergedInto set to the target, keeping its history; the target is left as it is.
+	// Both versions are checked like the version of any other write.
+	MergeStudents(ctx context.Context, sourceID int64, targetID int64, sourceVersion int64, targetVersion int64) (types.Student, error)
+
+	// ApplyBatch runs a batch of creates, updates and deletes in one transaction and returns the result of each
+	// operation, in order. With atomic set, the first failing operation rolls back the whole batch; otherwise every
+	// failing operation is rolled back on its own and the others are committed. The error is only set when the batch
+	// as a whole could not be run.
+	ApplyBatch(ctx context.Context, operations []types.BatchOperation, atomic bool) ([]BatchResult, error)
+
+	// ClaimIdempotencyKey stores a record for a request that is being processed, with a Status of 0, unless an
+	// unexpired record with the same key exists. It returns the stored record and whether it is the one given, i.e.
+	// whether the caller claimed the key. Expired records are replaced.
+	ClaimIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) (types.IdempotencyRecord, bool, error)
+
+	// CompleteIdempotencyKey stores the response of the request that claimed a key, along with the new expiry of the
+	// record. It returns ErrNotFound if the key is not claimed by a request being processed.
+	CompleteIdempotencyKey(ctx context.Context, record types.IdempotencyRecord) error
+
+	// ReleaseIdempotencyKey deletes the claim on a key by a request being processed, so that the request can be
+	// retried. Completed records are left as they are.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+
+	// DeleteExpiredIdempotencyKeys deletes the records that expired at or before the given time and returns how many
+	// there were.
+	DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+
+	// CreateAPIKey stores a new API key and returns it with its ID. A name already used by another key, revoked or
+	// not, returns ErrConflict.
+	CreateAPIKey(ctx context.Context, key types.APIKey) (types.APIKey, error)
+
+	// GetAPIKeyByHash retrieves the API key with the given hash, including revoked keys, or ErrNotFound.
+	GetAPIKeyByHash(ctx context.Context, hash string) (types.APIKey, error)
+
+	// GetAPIKeys retrieves every API key, including revoked keys, oldest first.
+	GetAPIKeys(ctx context.Context) ([]types.APIKey, error)
+
+	// RevokeAPIKey revokes the API key with the given name as of the given time. It returns ErrNotFound if there is
+	// no such key that is not revoked yet.
+	RevokeAPIKey(ctx context.Context, name string, at time.Time) error
+
+	// SetAPIKeyRoles replaces the roles of the API key with the given name, revoked or not. It returns ErrNotFound
+	// if there is no such key.
+	SetAPIKeyRoles(ctx context.Context, name string, roles []string) error
 
-	// DeleteStudent deletes a student by ID from the storage.
-	DeleteStudent(id int64) error
+	// GetAuditEntries retrieves one page of the audit log entries matching the filter, newest first,
+	// along with the total number of matching entries.
+	GetAuditEntries(ctx context.Context, filter types.AuditFilter) ([]types.AuditEntry, int64, error)
 }
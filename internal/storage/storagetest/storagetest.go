@@ -0,0 +1,1546 @@
+// Package storagetest is a conformance test suite for storage.Storage implementations.
+// Every backend runs it from its own tests so that they all behave alike:
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return newEmptyBackend(t)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/storage"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// Factory returns a new, empty storage for a single test. It should register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs the whole suite against the storage returned by factory. Every subtest gets its own storage.
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.Storage)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"GetNotFound", testGetNotFound},
+		{"IDsAreNotReused", testIDsAreNotReused},
+		{"Update", testUpdate},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"Patch", testPatch},
+		{"PatchNotFound", testPatchNotFound},
+		{"Delete", testDelete},
+		{"DeleteNotFound", testDeleteNotFound},
+		{"Versions", testVersions},
+		{"Trash", testTrash},
+		{"RestoreAndPurgeNotFound", testRestoreAndPurgeNotFound},
+		{"UniqueEmail", testUniqueEmail},
+		{"Merge", testMerge},
+		{"Idempotency", testIdempotency},
+		{"APIKeys", testAPIKeys},
+		{"AuditLog", testAuditLog},
+		{"AuditFilters", testAuditFilters},
+		{"AsOf", testAsOf},
+		{"ListAsOf", testListAsOf},
+		{"StudentVersions", testStudentVersions},
+		{"Batch", testBatch},
+		{"BatchAtomic", testBatchAtomic},
+		{"ListEmpty", testListEmpty},
+		{"ListOrder", testListOrder},
+		{"ListFilters", testListFilters},
+		{"ListOffset", testListOffset},
+		{"ListCursor", testListCursor},
+		{"Stream", testStream},
+		{"Search", testSearch},
+		{"Unicode", testUnicode},
+		{"AgeLimits", testAgeLimits},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"CanceledContext", testCanceledContext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, factory(t))
+		})
+	}
+}
+
+// create creates a student and fails the test if that is not possible.
+func create(t *testing.T, s storage.Storage, name, email string, age int) types.Student {
+	t.Helper()
+
+	id, err := s.CreateStudent(t.Context(), name, email, age)
+	if err != nil {
+		t.Fatalf("CreateStudent(%q, %q, %d): %v", name, email, age, err)
+	}
+
+	return types.Student{Id: id, Name: name, Email: email, Age: age, Version: 1}
+}
+
+// list lists students and fails the test if that is not possible.
+func list(t *testing.T, s storage.Storage, filter types.StudentFilter) ([]types.Student, int64) {
+	t.Helper()
+
+	students, total, err := s.GetStudents(t.Context(), filter)
+	if err != nil {
+		t.Fatalf("GetStudents(%+v): %v", filter, err)
+	}
+
+	return students, total
+}
+
+// ids returns the IDs of students, in order.
+func ids(students []types.Student) []int64 {
+	result := make([]int64, len(students))
+
+	for i, student := range students {
+		result[i] = student.Id
+	}
+
+	return result
+}
+
+// expectIDs fails the test when students do not have exactly the wanted IDs in the wanted order.
+func expectIDs(t *testing.T, students []types.Student, want ...int64) {
+	t.Helper()
+
+	if got := ids(students); !slices.Equal(got, want) {
+		t.Fatalf("got students %v, want %v", got, want)
+	}
+}
+
+func testCreateAndGet(t *testing.T, s storage.Storage) {
+	want := create(t, s, "Ansh", "ansh@example.com", 21)
+
+	if want.Id <= 0 {
+		t.Fatalf("CreateStudent returned ID %d, want a positive ID", want.Id)
+	}
+
+	got, err := s.GetStudentByID(t.Context(), want.Id)
+	if err != nil {
+		t.Fatalf("GetStudentByID(%d): %v", want.Id, err)
+	}
+
+	if got != want {
+		t.Fatalf("GetStudentByID(%d) = %+v, want %+v", want.Id, got, want)
+	}
+}
+
+func testGetNotFound(t *testing.T, s storage.Storage) {
+	if _, err := s.GetStudentByID(t.Context(), 42); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetStudentByID on an empty storage returned %v, want ErrNotFound", err)
+	}
+}
+
+func testIDsAreNotReused(t *testing.T, s storage.Storage) {
+	first := create(t, s, "First", "first@example.com", 20)
+	second := create(t, s, "Second", "second@example.com", 20)
+
+	if second.Id <= first.Id {
+		t.Fatalf("IDs are not increasing: %d then %d", first.Id, second.Id)
+	}
+
+	if err := s.DeleteStudent(t.Context(), second.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", second.Id, err)
+	}
+
+	third := create(t, s, "Third", "third@example.com", 20)
+
+	if third.Id <= second.Id {
+		t.Fatalf("ID %d of a deleted student was reused", third.Id)
+	}
+}
+
+func testUpdate(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Ansh", "ansh@example.com", 21)
+	other := create(t, s, "Other", "other@example.com", 30)
+
+	if err := s.UpdateStudent(t.Context(), student.Id, "Ansh Singh", "ansh.singh@example.com", 22, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d): %v", student.Id, err)
+	}
+
+	// Updating with unchanged values is still a successful update
+	if err := s.UpdateStudent(t.Context(), student.Id, "Ansh Singh", "ansh.singh@example.com", 22, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d) with unchanged values: %v", student.Id, err)
+	}
+
+	got, err := s.GetStudentByID(t.Context(), student.Id)
+	if err != nil {
+		t.Fatalf("GetStudentByID(%d): %v", student.Id, err)
+	}
+
+	want := types.Student{Id: student.Id, Name: "Ansh Singh", Email: "ansh.singh@example.com", Age: 22, Version: 3}
+	if got != want {
+		t.Fatalf("after update got %+v, want %+v", got, want)
+	}
+
+	if got, _ := s.GetStudentByID(t.Context(), other.Id); got != other {
+		t.Fatalf("updating student %d changed student %d to %+v", student.Id, other.Id, got)
+	}
+}
+
+func testUpdateNotFound(t *testing.T, s storage.Storage) {
+	if err := s.UpdateStudent(t.Context(), 42, "Nobody", "nobody@example.com", 20, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("UpdateStudent of a missing student returned %v, want ErrNotFound", err)
+	}
+}
+
+func testPatch(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Ansh", "ansh@example.com", 21)
+
+	age := 0
+
+	got, err := s.PatchStudent(t.Context(), student.Id, types.StudentPatch{Age: &age}, 0)
+	if err != nil {
+		t.Fatalf("PatchStudent(%d): %v", student.Id, err)
+	}
+
+	want := student
+	want.Age = 0
+	want.Version = 2
+
+	if got != want {
+		t.Fatalf("PatchStudent returned %+v, want %+v", got, want)
+	}
+
+	email := "ansh.singh@example.com"
+
+	if _, err := s.PatchStudent(t.Context(), student.Id, types.StudentPatch{Email: &email}, 0); err != nil {
+		t.Fatalf("PatchStudent(%d): %v", student.Id, err)
+	}
+
+	want.Email = email
+	want.Version = 3
+
+	if got, _ := s.GetStudentByID(t.Context(), student.Id); got != want {
+		t.Fatalf("after patches got %+v, want %+v", got, want)
+	}
+
+	got, err = s.PatchStudent(t.Context(), student.Id, types.StudentPatch{}, 0)
+	if err != nil || got != want {
+		t.Fatalf("empty patch returned %+v, %v, want the unchanged student", got, err)
+	}
+}
+
+func testPatchNotFound(t *testing.T, s storage.Storage) {
+	name := "Nobody"
+
+	if _, err := s.PatchStudent(t.Context(), 42, types.StudentPatch{Name: &name}, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("PatchStudent of a missing student returned %v, want ErrNotFound", err)
+	}
+
+	if _, err := s.PatchStudent(t.Context(), 42, types.StudentPatch{}, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("empty PatchStudent of a missing student returned %v, want ErrNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Ansh", "ansh@example.com", 21)
+	other := create(t, s, "Other", "other@example.com", 30)
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", student.Id, err)
+	}
+
+	if _, err := s.GetStudentByID(t.Context(), student.Id); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetStudentByID of a deleted student returned %v, want ErrNotFound", err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("deleting a student twice returned %v, want ErrNotFound", err)
+	}
+
+	students, total := list(t, s, types.StudentFilter{})
+	expectIDs(t, students, other.Id)
+
+	if total != 1 {
+		t.Fatalf("total is %d after deleting one of two students, want 1", total)
+	}
+}
+
+func testDeleteNotFound(t *testing.T, s storage.Storage) {
+	if err := s.DeleteStudent(t.Context(), 42, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("DeleteStudent of a missing student returned %v, want ErrNotFound", err)
+	}
+}
+
+func testVersions(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Ansh", "ansh@example.com", 21)
+
+	if err := s.UpdateStudent(t.Context(), student.Id, "Ansh Singh", "ansh@example.com", 21, 2); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("UpdateStudent at a future version returned %v, want ErrVersionMismatch", err)
+	}
+
+	if err := s.UpdateStudent(t.Context(), student.Id, "Ansh Singh", "ansh@example.com", 21, 1); err != nil {
+		t.Fatalf("UpdateStudent at the current version: %v", err)
+	}
+
+	// The first writer won, a second one that read version 1 has to fail
+	if err := s.UpdateStudent(t.Context(), student.Id, "Someone Else", "ansh@example.com", 21, 1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("UpdateStudent at a stale version returned %v, want ErrVersionMismatch", err)
+	}
+
+	name := "Ansh S."
+
+	if _, err := s.PatchStudent(t.Context(), student.Id, types.StudentPatch{Name: &name}, 1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("PatchStudent at a stale version returned %v, want ErrVersionMismatch", err)
+	}
+
+	if _, err := s.PatchStudent(t.Context(), student.Id, types.StudentPatch{}, 1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("empty PatchStudent at a stale version returned %v, want ErrVersionMismatch", err)
+	}
+
+	got, err := s.PatchStudent(t.Context(), student.Id, types.StudentPatch{Name: &name}, 2)
+	if err != nil {
+		t.Fatalf("PatchStudent at the current version: %v", err)
+	}
+
+	if got.Name != name || got.Version != 3 {
+		t.Fatalf("PatchStudent returned %+v, want name %q at version 3", got, name)
+	}
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 2); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("DeleteStudent at a stale version returned %v, want ErrVersionMismatch", err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 3); err != nil {
+		t.Fatalf("DeleteStudent at the current version: %v", err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 3); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("DeleteStudent of a deleted student returned %v, want ErrNotFound", err)
+	}
+}
+
+func testTrash(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Ansh", "ansh@example.com", 21)
+	other := create(t, s, "Other", "other@example.com", 30)
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", student.Id, err)
+	}
+
+	trash, total := list(t, s, types.StudentFilter{Deleted: true})
+	expectIDs(t, trash, student.Id)
+
+	if total != 1 || trash[0].DeletedAt == nil || trash[0].Version != 2 {
+		t.Fatalf("trash is %+v with total %d, want the deleted student at version 2 with a deletion time", trash, total)
+	}
+
+	if results, err := s.SearchStudents(t.Context(), "ansh", 10, true); err != nil || len(results) != 0 {
+		t.Fatalf("search found %+v, %v, want no deleted students", results, err)
+	}
+
+	if err := s.UpdateStudent(t.Context(), student.Id, "Ansh", "ansh@example.com", 22, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("UpdateStudent of a deleted student returned %v, want ErrNotFound", err)
+	}
+
+	if err := s.PurgeStudent(t.Context(), other.Id, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("PurgeStudent of a live student returned %v, want ErrNotFound", err)
+	}
+
+	if _, err := s.RestoreStudent(t.Context(), student.Id, 1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("RestoreStudent at a stale version returned %v, want ErrVersionMismatch", err)
+	}
+
+	restored, err := s.RestoreStudent(t.Context(), student.Id, 2)
+	if err != nil {
+		t.Fatalf("RestoreStudent(%d): %v", student.Id, err)
+	}
+
+	want := student
+	want.Version = 3
+
+	if restored != want {
+		t.Fatalf("RestoreStudent returned %+v, want %+v", restored, want)
+	}
+
+	if got, err := s.GetStudentByID(t.Context(), student.Id); err != nil || got != want {
+		t.Fatalf("GetStudentByID of a restored student returned %+v, %v, want %+v", got, err, want)
+	}
+
+	if trash, _ := list(t, s, types.StudentFilter{Deleted: true}); len(trash) != 0 {
+		t.Fatalf("trash is %+v after restoring, want it empty", trash)
+	}
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 3); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", student.Id, err)
+	}
+
+	if err := s.PurgeStudent(t.Context(), student.Id, 4); err != nil {
+		t.Fatalf("PurgeStudent(%d): %v", student.Id, err)
+	}
+
+	if _, err := s.RestoreStudent(t.Context(), student.Id, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("RestoreStudent of a purged student returned %v, want ErrNotFound", err)
+	}
+
+	students, _ := list(t, s, types.StudentFilter{})
+	expectIDs(t, students, other.Id)
+}
+
+func testRestoreAndPurgeNotFound(t *testing.T, s storage.Storage) {
+	if _, err := s.RestoreStudent(t.Context(), 42, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("RestoreStudent of a missing student returned %v, want ErrNotFound", err)
+	}
+
+	if err := s.PurgeStudent(t.Context(), 42, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("PurgeStudent of a missing student returned %v, want ErrNotFound", err)
+	}
+}
+
+// expectDuplicateEmail fails the test unless err is a *storage.DuplicateEmailError naming the student with the given ID.
+func expectDuplicateEmail(t *testing.T, err error, id int64) {
+	t.Helper()
+
+	var duplicate *storage.DuplicateEmailError
+
+	if !errors.As(err, &duplicate) || !errors.Is(err, storage.ErrConflict) || duplicate.ID != id {
+		t.Fatalf("got error %v, want a duplicate email error naming student %d", err, id)
+	}
+}
+
+func testUniqueEmail(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Ansh", "ansh@example.com", 21)
+	other := create(t, s, "Other", "other@example.com", 30)
+
+	_, err := s.CreateStudent(t.Context(), "Ansh Again", " ANSH@Example.com", 22)
+	expectDuplicateEmail(t, err, student.Id)
+
+	err = s.UpdateStudent(t.Context(), other.Id, "Other", "Ansh@example.com", 30, 0)
+	expectDuplicateEmail(t, err, student.Id)
+
+	email := "ANSH@EXAMPLE.COM"
+
+	_, err = s.PatchStudent(t.Context(), other.Id, types.StudentPatch{Email: &email}, 0)
+	expectDuplicateEmail(t, err, student.Id)
+
+	_, err = s.ApplyBatch(t.Context(), []types.BatchOperation{{Op: types.BatchCreate, Student: types.Student{Name: "Batch", Email: "other@EXAMPLE.com"}}}, true)
+	if err != nil {
+		t.Fatalf("ApplyBatch: %v", err)
+	}
+
+	// A student may change the case of its own email
+	if _, err := s.PatchStudent(t.Context(), student.Id, types.StudentPatch{Email: &email}, 0); err != nil {
+		t.Fatalf("PatchStudent of the case of an email: %v", err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", student.Id, err)
+	}
+
+	// Students in the trash give up their email, until they are restored
+	replacement := create(t, s, "Replacement", "ansh@example.com", 21)
+
+	_, err = s.RestoreStudent(t.Context(), student.Id, 0)
+	expectDuplicateEmail(t, err, replacement.Id)
+
+	if err := s.UpdateStudent(t.Context(), replacement.Id, "Replacement", "replacement@example.com", 21, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d): %v", replacement.Id, err)
+	}
+
+	if _, err := s.RestoreStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("RestoreStudent after the email was freed: %v", err)
+	}
+
+	students, _ := list(t, s, types.StudentFilter{})
+	expectIDs(t, students, student.Id, other.Id, replacement.Id)
+}
+
+func testMerge(t *testing.T, s storage.Storage) {
+	target := create(t, s, "Ansh Singh", "ansh@example.com", 21)
+	source := create(t, s, "Ansh", "ansh.singh@example.com", 21)
+
+	if _, err := s.MergeStudents(t.Context(), source.Id, source.Id, 0, 0); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("merging a student into itself returned %v, want ErrConflict", err)
+	}
+
+	if _, err := s.MergeStudents(t.Context(), source.Id, 42, 0, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("merging into a missing student returned %v, want ErrNotFound", err)
+	}
+
+	if _, err := s.MergeStudents(t.Context(), source.Id, target.Id, 1, 2); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("merging into a stale target version returned %v, want ErrVersionMismatch", err)
+	}
+
+	if _, err := s.MergeStudents(t.Context(), source.Id, target.Id, 2, 1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("merging a stale source version returned %v, want ErrVersionMismatch", err)
+	}
+
+	merged, err := s.MergeStudents(t.Context(), source.Id, target.Id, 1, 1)
+	if err != nil {
+		t.Fatalf("MergeStudents(%d, %d): %v", source.Id, target.Id, err)
+	}
+
+	if merged != target {
+		t.Fatalf("MergeStudents returned %+v, want the target unchanged: %+v", merged, target)
+	}
+
+	if _, err := s.GetStudentByID(t.Context(), source.Id); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetStudentByID of a merged student returned %v, want ErrNotFound", err)
+	}
+
+	trash, _ := list(t, s, types.StudentFilter{Deleted: true})
+	expectIDs(t, trash, source.Id)
+
+	if trash[0].MergedInto == nil || *trash[0].MergedInto != target.Id || trash[0].DeletedAt == nil || trash[0].Version != 2 {
+		t.Fatalf("merged student is %+v, want it in the trash at version 2, merged into %d", trash[0], target.Id)
+	}
+
+	// The history of the source is kept, including the merge
+	version, err := s.GetStudentVersion(t.Context(), source.Id, 2)
+	if err != nil || version.MergedInto == nil || *version.MergedInto != target.Id {
+		t.Fatalf("GetStudentVersion(%d, 2) returned %+v, %v, want the merged version", source.Id, version, err)
+	}
+
+	if first, err := s.GetStudentVersion(t.Context(), source.Id, 1); err != nil || first.Name != source.Name || first.MergedInto != nil {
+		t.Fatalf("GetStudentVersion(%d, 1) returned %+v, %v, want the version before the merge", source.Id, first, err)
+	}
+
+	for _, id := range []int64{source.Id, target.Id} {
+		entries, _ := auditEntries(t, s, types.AuditFilter{StudentID: id, Action: types.AuditMerge})
+
+		if len(entries) != 1 {
+			t.Fatalf("student %d has merge entries %+v, want one", id, entries)
+		}
+	}
+
+	// The merged student gave up its email, and restoring it undoes the merge
+	newcomer := create(t, s, "Newcomer", "ansh.singh@example.com", 19)
+
+	if _, err := s.RestoreStudent(t.Context(), source.Id, 0); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("restoring a merged student whose email was taken returned %v, want ErrConflict", err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), newcomer.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", newcomer.Id, err)
+	}
+
+	restored, err := s.RestoreStudent(t.Context(), source.Id, 0)
+	if err != nil || restored.MergedInto != nil || restored.DeletedAt != nil {
+		t.Fatalf("RestoreStudent of a merged student returned %+v, %v, want it live and no longer merged", restored, err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), source.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", source.Id, err)
+	}
+
+	if _, err := s.MergeStudents(t.Context(), source.Id, target.Id, 0, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("merging a student in the trash returned %v, want ErrNotFound", err)
+	}
+}
+
+func testIdempotency(t *testing.T, s storage.Storage) {
+	now := time.Now().UTC().Truncate(time.Second)
+	claim := types.IdempotencyRecord{Key: "key-1", Fingerprint: "abc", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+
+	if _, claimed, err := s.ClaimIdempotencyKey(t.Context(), claim); err != nil || !claimed {
+		t.Fatalf("ClaimIdempotencyKey of a new key returned %t, %v, want it claimed", claimed, err)
+	}
+
+	// A retry while the request is being processed finds the claim
+	stored, claimed, err := s.ClaimIdempotencyKey(t.Context(), claim)
+	if err != nil || claimed || stored.Status != 0 || stored.Fingerprint != "abc" {
+		t.Fatalf("ClaimIdempotencyKey of a claimed key returned %+v, %t, %v, want the claim", stored, claimed, err)
+	}
+
+	completed := claim
+	completed.Status = 201
+	completed.Header = map[string][]string{"Content-Type": {"application/json"}}
+	completed.Body = []byte(`{"id":1}`)
+	completed.ExpiresAt = now.Add(time.Hour)
+
+	if err := s.CompleteIdempotencyKey(t.Context(), completed); err != nil {
+		t.Fatalf("CompleteIdempotencyKey: %v", err)
+	}
+
+	if err := s.CompleteIdempotencyKey(t.Context(), completed); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("completing a key twice returned %v, want ErrNotFound", err)
+	}
+
+	// Completed records are kept when released, and replayed to retries until they expire
+	if err := s.ReleaseIdempotencyKey(t.Context(), claim.Key); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey: %v", err)
+	}
+
+	retry := claim
+	retry.CreatedAt = now.Add(30 * time.Minute)
+	retry.ExpiresAt = retry.CreatedAt.Add(time.Minute)
+
+	stored, claimed, err = s.ClaimIdempotencyKey(t.Context(), retry)
+	if err != nil || claimed {
+		t.Fatalf("ClaimIdempotencyKey of a completed key returned %t, %v, want the stored record", claimed, err)
+	}
+
+	if stored.Status != 201 || string(stored.Body) != `{"id":1}` || !slices.Equal(stored.Header["Content-Type"], []string{"application/json"}) || !stored.ExpiresAt.Equal(completed.ExpiresAt) {
+		t.Fatalf("stored record is %+v, want the completed response", stored)
+	}
+
+	// Expired records are taken over by a new claim
+	retry.CreatedAt = now.Add(2 * time.Hour)
+	retry.ExpiresAt = retry.CreatedAt.Add(time.Minute)
+	retry.Fingerprint = "def"
+
+	stored, claimed, err = s.ClaimIdempotencyKey(t.Context(), retry)
+	if err != nil || !claimed || stored.Status != 0 || stored.Body != nil {
+		t.Fatalf("ClaimIdempotencyKey of an expired key returned %+v, %t, %v, want a new claim", stored, claimed, err)
+	}
+
+	// A released claim can be claimed again right away
+	if err := s.ReleaseIdempotencyKey(t.Context(), claim.Key); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey: %v", err)
+	}
+
+	if _, claimed, err := s.ClaimIdempotencyKey(t.Context(), retry); err != nil || !claimed {
+		t.Fatalf("ClaimIdempotencyKey of a released key returned %t, %v, want it claimed", claimed, err)
+	}
+
+	other := types.IdempotencyRecord{Key: "key-2", Fingerprint: "abc", CreatedAt: now, ExpiresAt: now.Add(5 * time.Hour)}
+
+	if _, _, err := s.ClaimIdempotencyKey(t.Context(), other); err != nil {
+		t.Fatalf("ClaimIdempotencyKey: %v", err)
+	}
+
+	deleted, err := s.DeleteExpiredIdempotencyKeys(t.Context(), now.Add(3*time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredIdempotencyKeys returned %d, %v, want 1 deleted", deleted, err)
+	}
+
+	if _, claimed, err := s.ClaimIdempotencyKey(t.Context(), other); err != nil || claimed {
+		t.Fatalf("ClaimIdempotencyKey of an unexpired key returned %t, %v, want it kept", claimed, err)
+	}
+}
+
+func testAPIKeys(t *testing.T, s storage.Storage) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	created, err := s.CreateAPIKey(t.Context(), types.APIKey{Name: "ci", Prefix: "sk_abc", Hash: "hash-1", Roles: []string{"registrar"}, CreatedAt: now})
+	if err != nil || created.ID == 0 {
+		t.Fatalf("CreateAPIKey returned %+v, %v", created, err)
+	}
+
+	if _, err := s.CreateAPIKey(t.Context(), types.APIKey{Name: "ci", Prefix: "sk_def", Hash: "hash-2", CreatedAt: now}); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("creating a key with a name in use returned %v, want ErrConflict", err)
+	}
+
+	if _, err := s.CreateAPIKey(t.Context(), types.APIKey{Name: "mobile", Prefix: "sk_ghi", Hash: "hash-3", CreatedAt: now}); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	got, err := s.GetAPIKeyByHash(t.Context(), "hash-1")
+	if err != nil || got.ID != created.ID || got.Name != "ci" || got.Prefix != "sk_abc" || !slices.Equal(got.Roles, []string{"registrar"}) || !got.CreatedAt.Equal(now) || got.RevokedAt != nil {
+		t.Fatalf("GetAPIKeyByHash returned %+v, %v, want %+v", got, err, created)
+	}
+
+	if _, err := s.GetAPIKeyByHash(t.Context(), "hash-2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetAPIKeyByHash of a missing hash returned %v, want ErrNotFound", err)
+	}
+
+	if err := s.SetAPIKeyRoles(t.Context(), "ci", []string{"teacher", "admin"}); err != nil {
+		t.Fatalf("SetAPIKeyRoles: %v", err)
+	}
+
+	if err := s.SetAPIKeyRoles(t.Context(), "missing", []string{"admin"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("SetAPIKeyRoles of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	if got, _ := s.GetAPIKeyByHash(t.Context(), "hash-1"); !slices.Equal(got.Roles, []string{"teacher", "admin"}) {
+		t.Fatalf("roles of the key are %v after SetAPIKeyRoles, want [teacher admin]", got.Roles)
+	}
+
+	if err := s.RevokeAPIKey(t.Context(), "ci", now.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+
+	if err := s.RevokeAPIKey(t.Context(), "ci", now.Add(time.Hour)); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("revoking a key twice returned %v, want ErrNotFound", err)
+	}
+
+	// Revoked keys are still found, so that they can be told apart from unknown ones
+	got, err = s.GetAPIKeyByHash(t.Context(), "hash-1")
+	if err != nil || got.RevokedAt == nil || !got.RevokedAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("GetAPIKeyByHash of a revoked key returned %+v, %v, want it revoked", got, err)
+	}
+
+	keys, err := s.GetAPIKeys(t.Context())
+	if err != nil || len(keys) != 2 || keys[0].Name != "ci" || keys[1].Name != "mobile" || keys[1].RevokedAt != nil || len(keys[1].Roles) != 0 {
+		t.Fatalf("GetAPIKeys returned %+v, %v, want ci revoked and mobile", keys, err)
+	}
+}
+
+// auditEntries lists audit entries and fails the test if that is not possible.
+func auditEntries(t *testing.T, s storage.Storage, filter types.AuditFilter) ([]types.AuditEntry, int64) {
+	t.Helper()
+
+	entries, total, err := s.GetAuditEntries(t.Context(), filter)
+	if err != nil {
+		t.Fatalf("GetAuditEntries(%+v): %v", filter, err)
+	}
+
+	return entries, total
+}
+
+func testAuditLog(t *testing.T, s storage.Storage) {
+	ctx := storage.WithAuditInfo(t.Context(), storage.AuditInfo{Actor: "alice", RequestID: "req-1"})
+	start := time.Now().Add(-time.Second)
+
+	id, err := s.CreateStudent(ctx, "Ansh", "ansh@example.com", 21)
+	if err != nil {
+		t.Fatalf("CreateStudent: %v", err)
+	}
+
+	if err := s.UpdateStudent(ctx, id, "Ansh Singh", "ansh@example.com", 22, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d): %v", id, err)
+	}
+
+	// Failed writes leave no trace
+	if err := s.UpdateStudent(ctx, id, "Stale", "ansh@example.com", 22, 1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("UpdateStudent at a stale version returned %v, want ErrVersionMismatch", err)
+	}
+
+	if err := s.DeleteStudent(ctx, id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", id, err)
+	}
+
+	if _, err := s.RestoreStudent(ctx, id, 0); err != nil {
+		t.Fatalf("RestoreStudent(%d): %v", id, err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), id, 0); err != nil { // without audit information, the change is made by the system
+		t.Fatalf("DeleteStudent(%d): %v", id, err)
+	}
+
+	if err := s.PurgeStudent(ctx, id, 0); err != nil {
+		t.Fatalf("PurgeStudent(%d): %v", id, err)
+	}
+
+	end := time.Now().Add(time.Second)
+
+	entries, total := auditEntries(t, s, types.AuditFilter{StudentID: id})
+
+	actions := make([]string, len(entries))
+	for i, entry := range entries {
+		actions[i] = entry.Action
+	}
+
+	want := []string{types.AuditPurge, types.AuditDelete, types.AuditRestore, types.AuditDelete, types.AuditUpdate, types.AuditCreate}
+	if !slices.Equal(actions, want) || total != int64(len(want)) {
+		t.Fatalf("history has actions %v (total %d), want %v newest first", actions, total, want)
+	}
+
+	for i, entry := range entries {
+		wantActor := "alice"
+		if i == 1 {
+			wantActor = storage.SystemActor
+		}
+
+		if entry.StudentID != id || entry.Actor != wantActor || (wantActor == "alice") != (entry.RequestID == "req-1") {
+			t.Errorf("entry %d is %+v, want student %d changed by %s", i, entry, id, wantActor)
+		}
+
+		if entry.Timestamp.Before(start) || entry.Timestamp.After(end) {
+			t.Errorf("entry %d has timestamp %v, want it between %v and %v", i, entry.Timestamp, start, end)
+		}
+	}
+
+	create, update, purge := entries[5], entries[4], entries[0]
+
+	if create.Before != nil || create.After == nil || *create.After != (types.Student{Id: id, Name: "Ansh", Email: "ansh@example.com", Age: 21, Version: 1}) {
+		t.Errorf("create entry has snapshots %+v -> %+v", create.Before, create.After)
+	}
+
+	if update.Before == nil || *update.Before != *create.After || update.After == nil || update.After.Name != "Ansh Singh" || update.After.Version != 2 {
+		t.Errorf("update entry has snapshots %+v -> %+v", update.Before, update.After)
+	}
+
+	if deletion := entries[3]; deletion.After == nil || deletion.After.DeletedAt == nil {
+		t.Errorf("delete entry has no deletion time after the change: %+v", deletion.After)
+	}
+
+	if purge.Before == nil || purge.Before.DeletedAt == nil || purge.After != nil {
+		t.Errorf("purge entry has snapshots %+v -> %+v, want a deleted student and nothing", purge.Before, purge.After)
+	}
+}
+
+func testAuditFilters(t *testing.T, s storage.Storage) {
+	alice := storage.WithAuditInfo(t.Context(), storage.AuditInfo{Actor: "alice", RequestID: "req-1"})
+	bob := storage.WithAuditInfo(t.Context(), storage.AuditInfo{Actor: "bob", RequestID: "req-2"})
+
+	first, err := s.CreateStudent(alice, "First", "first@example.com", 20)
+	if err != nil {
+		t.Fatalf("CreateStudent: %v", err)
+	}
+
+	second, err := s.CreateStudent(bob, "Second", "second@example.com", 20)
+	if err != nil {
+		t.Fatalf("CreateStudent: %v", err)
+	}
+
+	if err := s.UpdateStudent(bob, first, "First", "first@example.com", 21, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d): %v", first, err)
+	}
+
+	between := time.Now()
+
+	if err := s.DeleteStudent(alice, second, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", second, err)
+	}
+
+	tests := []struct {
+		name   string
+		filter types.AuditFilter
+		want   []string // "<actor> <action> <student>" of the matching entries, newest first
+	}{
+		{"all", types.AuditFilter{}, []string{"alice delete 2", "bob update 1", "bob create 2", "alice create 1"}},
+		{"actor", types.AuditFilter{Actor: "bob"}, []string{"bob update 1", "bob create 2"}},
+		{"action", types.AuditFilter{Action: types.AuditCreate}, []string{"bob create 2", "alice create 1"}},
+		{"request", types.AuditFilter{RequestID: "req-1"}, []string{"alice delete 2", "alice create 1"}},
+		{"student", types.AuditFilter{StudentID: second}, []string{"alice delete 2", "bob create 2"}},
+		{"since", types.AuditFilter{Since: between}, []string{"alice delete 2"}},
+		{"until", types.AuditFilter{Until: between}, []string{"bob update 1", "bob create 2", "alice create 1"}},
+		{"page", types.AuditFilter{Limit: 2, Offset: 1}, []string{"bob update 1", "bob create 2"}},
+	}
+
+	for _, tt := range tests {
+		entries, total := auditEntries(t, s, tt.filter)
+
+		got := make([]string, len(entries))
+		for i, entry := range entries {
+			student := "1"
+			if entry.StudentID == second {
+				student = "2"
+			}
+
+			got[i] = entry.Actor + " " + entry.Action + " " + student
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got entries %v, want %v", tt.name, got, tt.want)
+		}
+
+		if tt.filter.Limit == 0 && total != int64(len(tt.want)) {
+			t.Errorf("%s: total is %d, want %d", tt.name, total, len(tt.want))
+		}
+	}
+}
+
+func testAsOf(t *testing.T, s storage.Storage) {
+	beforeCreate := time.Now()
+
+	student := create(t, s, "Alice", "alice@example.com", 20)
+	created := time.Now()
+
+	if err := s.UpdateStudent(t.Context(), student.Id, "Alice Smith", "alice@example.com", 21, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d): %v", student.Id, err)
+	}
+
+	updated := time.Now()
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", student.Id, err)
+	}
+
+	deleted := time.Now()
+
+	if _, err := s.RestoreStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("RestoreStudent(%d): %v", student.Id, err)
+	}
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		want    types.Student
+		missing bool
+	}{
+		{"before create", beforeCreate, types.Student{}, true},
+		{"created", created, student, false},
+		{"updated", updated, types.Student{Id: student.Id, Name: "Alice Smith", Email: "alice@example.com", Age: 21, Version: 2}, false},
+		{"deleted", deleted, types.Student{}, true},
+		{"restored", time.Now(), types.Student{Id: student.Id, Name: "Alice Smith", Email: "alice@example.com", Age: 21, Version: 4}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := s.GetStudentAsOf(t.Context(), student.Id, tt.at)
+
+		if tt.missing {
+			if !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("%s: GetStudentAsOf returned %+v, %v, want ErrNotFound", tt.name, got, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: GetStudentAsOf: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := s.GetStudentAsOf(t.Context(), 999, time.Now()); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetStudentAsOf(999): got %v, want ErrNotFound", err)
+	}
+}
+
+func testListAsOf(t *testing.T, s storage.Storage) {
+	alice := create(t, s, "Alice", "alice@example.com", 20)
+	bob := create(t, s, "Bob", "bob@example.com", 30)
+
+	before := time.Now()
+
+	if err := s.UpdateStudent(t.Context(), alice.Id, "Alice", "alice@example.com", 40, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d): %v", alice.Id, err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), bob.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", bob.Id, err)
+	}
+
+	carol := create(t, s, "Carol", "carol@example.com", 25)
+
+	students, total := list(t, s, types.StudentFilter{AsOf: before})
+	expectIDs(t, students, alice.Id, bob.Id)
+
+	if total != 2 {
+		t.Errorf("total as of before the changes is %d, want 2", total)
+	}
+
+	if len(students) > 0 && students[0].Age != 20 {
+		t.Errorf("age of %d as of before the update is %d, want 20", alice.Id, students[0].Age)
+	}
+
+	minAge := 30
+
+	students, _ = list(t, s, types.StudentFilter{AsOf: before, MinAge: &minAge, Sort: types.SortByAge, Desc: true})
+	expectIDs(t, students, bob.Id)
+
+	students, _ = list(t, s, types.StudentFilter{AsOf: time.Now()})
+	expectIDs(t, students, alice.Id, carol.Id)
+
+	students, _ = list(t, s, types.StudentFilter{AsOf: time.Now(), Deleted: true})
+	expectIDs(t, students, bob.Id)
+}
+
+func testStudentVersions(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Alice", "alice@example.com", 20)
+
+	if err := s.UpdateStudent(t.Context(), student.Id, "Alice", "alice@example.com", 21, 0); err != nil {
+		t.Fatalf("UpdateStudent(%d): %v", student.Id, err)
+	}
+
+	if err := s.DeleteStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", student.Id, err)
+	}
+
+	if err := s.PurgeStudent(t.Context(), student.Id, 0); err != nil {
+		t.Fatalf("PurgeStudent(%d): %v", student.Id, err)
+	}
+
+	// The history outlives the student
+	first, err := s.GetStudentVersion(t.Context(), student.Id, 1)
+	if err != nil {
+		t.Fatalf("GetStudentVersion(%d, 1): %v", student.Id, err)
+	}
+
+	if first != student {
+		t.Errorf("version 1 is %+v, want %+v", first, student)
+	}
+
+	second, err := s.GetStudentVersion(t.Context(), student.Id, 2)
+	if err != nil {
+		t.Fatalf("GetStudentVersion(%d, 2): %v", student.Id, err)
+	}
+
+	if second.Age != 21 || second.DeletedAt != nil {
+		t.Errorf("version 2 is %+v, want age 21 and not deleted", second)
+	}
+
+	third, err := s.GetStudentVersion(t.Context(), student.Id, 3)
+	if err != nil {
+		t.Fatalf("GetStudentVersion(%d, 3): %v", student.Id, err)
+	}
+
+	if third.DeletedAt == nil {
+		t.Errorf("version 3 is %+v, want it in the trash", third)
+	}
+
+	if _, err := s.GetStudentVersion(t.Context(), student.Id, 4); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetStudentVersion(%d, 4): got %v, want ErrNotFound", student.Id, err)
+	}
+}
+
+func testBatch(t *testing.T, s storage.Storage) {
+	alice := create(t, s, "Alice", "alice@example.com", 20)
+	bob := create(t, s, "Bob", "bob@example.com", 30)
+
+	results, err := s.ApplyBatch(t.Context(), []types.BatchOperation{
+		{Op: types.BatchCreate, Student: types.Student{Name: "Carol", Email: "carol@example.com", Age: 25}},
+		{Op: types.BatchUpdate, Student: types.Student{Id: alice.Id, Name: "Alice Smith", Email: "alice@example.com", Age: 21, Version: 1}},
+		{Op: types.BatchUpdate, Student: types.Student{Id: bob.Id, Name: "Bob", Email: "bob@example.com", Age: 31, Version: 7}},
+		{Op: types.BatchDelete, Student: types.Student{Id: bob.Id}},
+		{Op: types.BatchDelete, Student: types.Student{Id: 999}},
+	}, false)
+	if err != nil {
+		t.Fatalf("ApplyBatch: %v", err)
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5", len(results))
+	}
+
+	for i, want := range []error{nil, nil, storage.ErrVersionMismatch, nil, storage.ErrNotFound} {
+		if want == nil && results[i].Err != nil || want != nil && !errors.Is(results[i].Err, want) {
+			t.Errorf("result %d has error %v, want %v", i, results[i].Err, want)
+		}
+	}
+
+	if results[1].ID != alice.Id || results[1].Version != 2 {
+		t.Errorf("update result is %+v, want ID %d at version 2", results[1], alice.Id)
+	}
+
+	if results[3].ID != bob.Id || results[3].Version != 2 {
+		t.Errorf("delete result is %+v, want ID %d at version 2", results[3], bob.Id)
+	}
+
+	carol, err := s.GetStudentByID(t.Context(), results[0].ID)
+	if err != nil {
+		t.Fatalf("GetStudentByID(%d): %v", results[0].ID, err)
+	}
+
+	if carol.Name != "Carol" || carol.Version != 1 {
+		t.Errorf("created student is %+v", carol)
+	}
+
+	if updated, _ := s.GetStudentByID(t.Context(), alice.Id); updated.Name != "Alice Smith" {
+		t.Errorf("updated student is %+v, want the name Alice Smith", updated)
+	}
+
+	if _, err := s.GetStudentByID(t.Context(), bob.Id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetStudentByID(%d) after the batch delete: got %v, want ErrNotFound", bob.Id, err)
+	}
+
+	// Every applied operation is audited
+	if _, total := auditEntries(t, s, types.AuditFilter{}); total != 5 {
+		t.Errorf("audit log has %d entries, want 5", total)
+	}
+}
+
+func testBatchAtomic(t *testing.T, s storage.Storage) {
+	alice := create(t, s, "Alice", "alice@example.com", 20)
+
+	results, err := s.ApplyBatch(t.Context(), []types.BatchOperation{
+		{Op: types.BatchCreate, Student: types.Student{Name: "Carol", Email: "carol@example.com", Age: 25}},
+		{Op: types.BatchUpdate, Student: types.Student{Id: alice.Id, Name: "Alice Smith", Email: "alice@example.com", Age: 21}},
+		{Op: types.BatchDelete, Student: types.Student{Id: 999}},
+		{Op: types.BatchCreate, Student: types.Student{Name: "Dave", Email: "dave@example.com", Age: 40}},
+	}, true)
+	if err != nil {
+		t.Fatalf("ApplyBatch: %v", err)
+	}
+
+	for i, want := range []error{storage.ErrBatchAborted, storage.ErrBatchAborted, storage.ErrNotFound, storage.ErrBatchAborted} {
+		if !errors.Is(results[i].Err, want) {
+			t.Errorf("result %d has error %v, want %v", i, results[i].Err, want)
+		}
+	}
+
+	students, total := list(t, s, types.StudentFilter{})
+	expectIDs(t, students, alice.Id)
+
+	if total != 1 || students[0] != alice {
+		t.Errorf("students after a rolled back batch are %+v, want only %+v", students, alice)
+	}
+
+	if _, total := auditEntries(t, s, types.AuditFilter{}); total != 1 {
+		t.Errorf("audit log has %d entries after a rolled back batch, want 1", total)
+	}
+
+	results, err = s.ApplyBatch(t.Context(), []types.BatchOperation{
+		{Op: types.BatchCreate, Student: types.Student{Name: "Carol", Email: "carol@example.com", Age: 25}},
+		{Op: types.BatchDelete, Student: types.Student{Id: alice.Id, Version: 1}},
+	}, true)
+	if err != nil {
+		t.Fatalf("ApplyBatch: %v", err)
+	}
+
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("result %d has error %v", i, result.Err)
+		}
+	}
+
+	students, _ = list(t, s, types.StudentFilter{})
+	expectIDs(t, students, results[0].ID)
+}
+
+func testListEmpty(t *testing.T, s storage.Storage) {
+	students, total := list(t, s, types.StudentFilter{Limit: 10})
+
+	if students == nil || len(students) != 0 || total != 0 {
+		t.Fatalf("listing an empty storage returned %v (nil: %t) and total %d, want an empty non-nil slice", students, students == nil, total)
+	}
+}
+
+// seed creates a small roster with duplicate names and ages to exercise tie-breaking.
+func seed(t *testing.T, s storage.Storage) []types.Student {
+	return []types.Student{
+		create(t, s, "Charlie", "charlie@school.org", 19),
+		create(t, s, "Alice", "alice@example.com", 21),
+		create(t, s, "Bob", "bob@school.org", 19),
+		create(t, s, "Alice", "alice.b@example.com", 25),
+		create(t, s, "Dave", "dave@example.com", 21),
+		// a lowercase name and an uppercase email, which a case-insensitive collation would sort elsewhere
+		create(t, s, "bob", "Bobby@school.org", 23),
+	}
+}
+
+func testListOrder(t *testing.T, s storage.Storage) {
+	st := seed(t, s)
+
+	tests := []struct {
+		sort string
+		desc bool
+		want []int64
+	}{
+		{"", false, []int64{st[0].Id, st[1].Id, st[2].Id, st[3].Id, st[4].Id, st[5].Id}},
+		{types.SortByID, true, []int64{st[5].Id, st[4].Id, st[3].Id, st[2].Id, st[1].Id, st[0].Id}},
+		// text is sorted bytewise by every backend, uppercase before lowercase
+		{types.SortByName, false, []int64{st[1].Id, st[3].Id, st[2].Id, st[0].Id, st[4].Id, st[5].Id}},
+		{types.SortByName, true, []int64{st[5].Id, st[4].Id, st[0].Id, st[2].Id, st[3].Id, st[1].Id}},
+		{types.SortByEmail, false, []int64{st[5].Id, st[3].Id, st[1].Id, st[2].Id, st[0].Id, st[4].Id}},
+		{types.SortByAge, false, []int64{st[0].Id, st[2].Id, st[1].Id, st[4].Id, st[5].Id, st[3].Id}},
+		{types.SortByAge, true, []int64{st[3].Id, st[5].Id, st[4].Id, st[1].Id, st[2].Id, st[0].Id}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s desc=%t", tt.sort, tt.desc), func(t *testing.T) {
+			students, _ := list(t, s, types.StudentFilter{Sort: tt.sort, Desc: tt.desc})
+			expectIDs(t, students, tt.want...)
+		})
+	}
+
+	if _, _, err := s.GetStudents(t.Context(), types.StudentFilter{Sort: "password"}); err == nil {
+		t.Fatal("sorting by an unknown field succeeded, want an error")
+	}
+}
+
+func testListFilters(t *testing.T, s storage.Storage) {
+	st := seed(t, s)
+
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name   string
+		filter types.StudentFilter
+		want   []int64
+	}{
+		{"name substring", types.StudentFilter{Name: "li"}, []int64{st[0].Id, st[1].Id, st[3].Id}},
+		{"name ignores case", types.StudentFilter{Name: "ALICE"}, []int64{st[1].Id, st[3].Id}},
+		{"email substring", types.StudentFilter{Email: "school"}, []int64{st[0].Id, st[2].Id, st[5].Id}},
+		{"wildcards are literal", types.StudentFilter{Email: "%"}, nil},
+		{"underscore is literal", types.StudentFilter{Name: "_"}, nil},
+		{"min age", types.StudentFilter{MinAge: intPtr(21)}, []int64{st[1].Id, st[3].Id, st[4].Id, st[5].Id}},
+		{"max age", types.StudentFilter{MaxAge: intPtr(19)}, []int64{st[0].Id, st[2].Id}},
+		{"age range", types.StudentFilter{MinAge: intPtr(20), MaxAge: intPtr(21)}, []int64{st[1].Id, st[4].Id}},
+		{"zero age bound", types.StudentFilter{MaxAge: intPtr(0)}, nil},
+		{"combined", types.StudentFilter{Name: "alice", MinAge: intPtr(22)}, []int64{st[3].Id}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			students, total := list(t, s, tt.filter)
+			expectIDs(t, students, tt.want...)
+
+			if total != int64(len(tt.want)) {
+				t.Fatalf("total is %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
+
+func testListOffset(t *testing.T, s storage.Storage) {
+	st := seed(t, s)
+
+	students, total := list(t, s, types.StudentFilter{Limit: 2, Offset: 2})
+	expectIDs(t, students, st[2].Id, st[3].Id)
+
+	if total != int64(len(st)) {
+		t.Fatalf("total is %d, want %d: it must count every matching student, not just the page", total, len(st))
+	}
+
+	students, _ = list(t, s, types.StudentFilter{Limit: 2, Offset: 4})
+	expectIDs(t, students, st[4].Id, st[5].Id)
+
+	students, _ = list(t, s, types.StudentFilter{Limit: 2, Offset: 10})
+	expectIDs(t, students)
+}
+
+func testListCursor(t *testing.T, s storage.Storage) {
+	st := seed(t, s)
+
+	// Sorted by age: Charlie(19) Bob(19) Alice(21) Dave(21) Alice(25)
+	byAge := types.StudentFilter{Sort: types.SortByAge, Limit: 2}
+
+	after := byAge
+	after.Cursor = &types.StudentCursor{ID: st[2].Id, Value: st[2].Age}
+
+	students, _ := list(t, s, after)
+	expectIDs(t, students, st[1].Id, st[4].Id)
+
+	before := byAge
+	before.Cursor = &types.StudentCursor{ID: st[4].Id, Value: st[4].Age, Backward: true}
+
+	students, _ = list(t, s, before)
+	expectIDs(t, students, st[2].Id, st[1].Id)
+
+	// A cursor keeps its place when the student it points at is deleted
+	if err := s.DeleteStudent(t.Context(), st[2].Id, 0); err != nil {
+		t.Fatalf("DeleteStudent(%d): %v", st[2].Id, err)
+	}
+
+	students, _ = list(t, s, after)
+	expectIDs(t, students, st[1].Id, st[4].Id)
+
+	// Students created before the cursor do not shift the next page
+	eve := create(t, s, "Eve", "eve@example.com", 18)
+
+	students, _ = list(t, s, after)
+	expectIDs(t, students, st[1].Id, st[4].Id)
+
+	byNameDesc := types.StudentFilter{Sort: types.SortByName, Desc: true, Cursor: &types.StudentCursor{ID: st[2].Id, Value: st[2].Name}}
+
+	students, _ = list(t, s, byNameDesc)
+	expectIDs(t, students, st[3].Id, st[1].Id)
+
+	// Cursors compare text bytewise like the sort order, so the lowercase name comes after every capitalized one
+	byName := types.StudentFilter{Sort: types.SortByName, Cursor: &types.StudentCursor{ID: st[4].Id, Value: st[4].Name}}
+
+	students, _ = list(t, s, byName)
+	expectIDs(t, students, eve.Id, st[5].Id)
+
+	byEmail := types.StudentFilter{Sort: types.SortByEmail, Limit: 2, Cursor: &types.StudentCursor{ID: st[5].Id, Value: st[5].Email}}
+
+	students, _ = list(t, s, byEmail)
+	expectIDs(t, students, st[3].Id, st[1].Id)
+
+	byID := types.StudentFilter{Limit: 10, Cursor: &types.StudentCursor{ID: st[3].Id}}
+
+	students, _ = list(t, s, byID)
+
+	if got := ids(students); len(got) != 3 || got[0] != st[4].Id || got[1] != st[5].Id {
+		t.Fatalf("listing after ID %d returned %v, want %d, %d and the student created last", st[3].Id, got, st[4].Id, st[5].Id)
+	}
+}
+
+func testStream(t *testing.T, s storage.Storage) {
+	students := seed(t, s)
+
+	stream := func(filter types.StudentFilter) []types.Student {
+		t.Helper()
+
+		var streamed []types.Student
+
+		err := s.StreamStudents(t.Context(), filter, func(student types.Student) error {
+			streamed = append(streamed, student)
+
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamStudents(%+v): %v", filter, err)
+		}
+
+		return streamed
+	}
+
+	// Pagination is ignored, everything matching the filter is streamed in the order of the listing
+	for _, filter := range []types.StudentFilter{
+		{},
+		{Sort: types.SortByAge, Desc: true},
+		{Name: "a", Limit: 1, Offset: 1},
+	} {
+		paged := filter
+		paged.Limit, paged.Offset = 0, 0
+
+		listed, _ := list(t, s, paged)
+
+		if got := stream(filter); !slices.Equal(ids(got), ids(listed)) {
+			t.Errorf("StreamStudents(%+v) streamed %v, want %v", filter, ids(got), ids(listed))
+		}
+	}
+
+	if len(stream(types.StudentFilter{})) != len(students) {
+		t.Errorf("StreamStudents did not stream every student")
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+
+	err := s.StreamStudents(t.Context(), types.StudentFilter{}, func(types.Student) error {
+		calls++
+
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("StreamStudents returned %v after %d calls, want the error of fn after 1 call", err, calls)
+	}
+}
+
+func testSearch(t *testing.T, s storage.Storage) {
+	st := seed(t, s)
+
+	results, err := s.SearchStudents(t.Context(), "alice", 10, true)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	var got []int64
+
+	for _, result := range results {
+		got = append(got, result.Student.Id)
+
+		if result.Highlights.Name != "<mark>Alice</mark>" {
+			t.Errorf("name of student %d is highlighted as %q", result.Student.Id, result.Highlights.Name)
+		}
+	}
+
+	slices.Sort(got)
+
+	if want := []int64{st[1].Id, st[3].Id}; !slices.Equal(got, want) {
+		t.Fatalf("search for alice found %v, want %v", got, want)
+	}
+
+	results, err = s.SearchStudents(t.Context(), "bob school", 10, true)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if got := searchIDs(results); !slices.Equal(got, []int64{st[2].Id, st[5].Id}) {
+		t.Fatalf("search for bob school found %v, want students %d and %d", got, st[2].Id, st[5].Id)
+	}
+
+	results, err = s.SearchStudents(t.Context(), "alice", 1, true)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("search with limit 1 returned %d results", len(results))
+	}
+
+	results, err = s.SearchStudents(t.Context(), "nobody", 10, true)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if results == nil || len(results) != 0 {
+		t.Fatalf("search without matches returned %v, want an empty non-nil slice", results)
+	}
+
+	// highlights are rendered as HTML, so the text around the matches is escaped
+	create(t, s, "<script>Zed</script> & Co", "zed@example.com", 30)
+
+	results, err = s.SearchStudents(t.Context(), "zed", 10, true)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Highlights.Name != "&lt;script&gt;<mark>Zed</mark>&lt;/script&gt; &amp; Co" || results[0].Highlights.Email != "<mark>zed</mark>@example.com" {
+		t.Fatalf("search for zed returned %+v, want escaped highlights", results)
+	}
+
+	// the best match is found among many weaker ones, with a higher ID than all of them
+	for i := range 30 {
+		create(t, s, "Azora", fmt.Sprintf("azora%d@example.com", i), 20)
+	}
+
+	best := create(t, s, "Zora Best", "zora@example.com", 20)
+
+	results, err = s.SearchStudents(t.Context(), "zora", 1, true)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Student.Id != best.Id {
+		t.Fatalf("search for zora with limit 1 returned %+v, want student %d", results, best.Id)
+	}
+
+	// without emails, only names are searched and emails are left unhighlighted
+	results, err = s.SearchStudents(t.Context(), "alice example", 10, false)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if len(results) != 0 {
+		t.Fatalf("search of names for alice example returned %+v, want no matches on emails", results)
+	}
+
+	results, err = s.SearchStudents(t.Context(), "charlie", 10, false)
+	if err != nil {
+		t.Fatalf("SearchStudents: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Student.Id != st[0].Id || results[0].Highlights.Name != "<mark>Charlie</mark>" || results[0].Highlights.Email != "charlie@school.org" {
+		t.Fatalf("search of names for charlie returned %+v, want student %d with only the name highlighted", results, st[0].Id)
+	}
+}
+
+// searchIDs returns the IDs of search results in ascending order.
+func searchIDs(results []types.StudentSearchResult) []int64 {
+	var got []int64
+
+	for _, result := range results {
+		got = append(got, result.Student.Id)
+	}
+
+	slices.Sort(got)
+
+	return got
+}
+
+func testUnicode(t *testing.T, s storage.Storage) {
+	names := []string{"Zoë Ørsted", "李小龍", "Ахмед", "👩‍🎓 Student", "O'Brien \"Quote\" Λ"}
+
+	for i, name := range names {
+		student := create(t, s, name, fmt.Sprintf("unicode%d@example.com", i), 20)
+
+		got, err := s.GetStudentByID(t.Context(), student.Id)
+		if err != nil {
+			t.Fatalf("GetStudentByID(%d): %v", student.Id, err)
+		}
+
+		if got.Name != name {
+			t.Fatalf("stored name %q came back as %q", name, got.Name)
+		}
+	}
+
+	students, _ := list(t, s, types.StudentFilter{Name: "李"})
+
+	if len(students) != 1 || students[0].Name != "李小龍" {
+		t.Fatalf("filtering by a unicode name returned %+v", students)
+	}
+}
+
+func testAgeLimits(t *testing.T, s storage.Storage) {
+	for i, age := range []int{0, math.MaxInt64, math.MinInt64} {
+		student := create(t, s, "Limit", fmt.Sprintf("limit%d@example.com", i), age)
+
+		got, err := s.GetStudentByID(t.Context(), student.Id)
+		if err != nil {
+			t.Fatalf("GetStudentByID(%d): %v", student.Id, err)
+		}
+
+		if got.Age != age {
+			t.Fatalf("stored age %d came back as %d", age, got.Age)
+		}
+	}
+
+	students, _ := list(t, s, types.StudentFilter{Sort: types.SortByAge, Desc: true, Limit: 1})
+
+	if len(students) != 1 || students[0].Age != math.MaxInt64 {
+		t.Fatalf("sorting by age descending returned %+v first, want the max int age", students)
+	}
+}
+
+func testConcurrentWriters(t *testing.T, s storage.Storage) {
+	const writers, perWriter = 8, 10
+
+	var wg sync.WaitGroup
+
+	created := make(chan int64, writers*perWriter)
+	errs := make(chan error, writers*perWriter)
+
+	for w := range writers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range perWriter {
+				email := fmt.Sprintf("writer%d-%d@example.com", w, i)
+
+				id, err := s.CreateStudent(t.Context(), fmt.Sprintf("Writer %d-%d", w, i), email, 20)
+				if err != nil {
+					errs <- err
+
+					continue
+				}
+
+				created <- id
+
+				if err := s.UpdateStudent(t.Context(), id, fmt.Sprintf("Writer %d-%d updated", w, i), email, 21, 0); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(created)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent write failed: %v", err)
+	}
+
+	seen := map[int64]bool{}
+
+	for id := range created {
+		if seen[id] {
+			t.Fatalf("ID %d was handed out twice", id)
+		}
+
+		seen[id] = true
+	}
+
+	students, total := list(t, s, types.StudentFilter{})
+
+	if total != writers*perWriter || len(students) != writers*perWriter {
+		t.Fatalf("got %d students (total %d) after concurrent writes, want %d", len(students), total, writers*perWriter)
+	}
+
+	for _, student := range students {
+		if student.Age != 21 {
+			t.Fatalf("student %d missed its update: %+v", student.Id, student)
+		}
+	}
+}
+
+func testCanceledContext(t *testing.T, s storage.Storage) {
+	student := create(t, s, "Ansh", "ansh@example.com", 21)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if _, err := s.CreateStudent(ctx, "Late", "late@example.com", 20); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateStudent with a canceled context returned %v, want context.Canceled", err)
+	}
+
+	if _, err := s.GetStudentByID(ctx, student.Id); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetStudentByID with a canceled context returned %v, want context.Canceled", err)
+	}
+
+	if _, _, err := s.GetStudents(ctx, types.StudentFilter{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetStudents with a canceled context returned %v, want context.Canceled", err)
+	}
+
+	if err := s.UpdateStudent(ctx, student.Id, "Changed", "changed@example.com", 30, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("UpdateStudent with a canceled context returned %v, want context.Canceled", err)
+	}
+
+	if err := s.DeleteStudent(ctx, student.Id, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteStudent with a canceled context returned %v, want context.Canceled", err)
+	}
+
+	if got, err := s.GetStudentByID(t.Context(), student.Id); err != nil || got != student {
+		t.Fatalf("student changed through canceled calls: %+v, %v", got, err)
+	}
+}
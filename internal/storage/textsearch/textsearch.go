@@ -0,0 +1,110 @@
+package textsearch
+
+import (
+	"cmp"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+)
+
+// Matcher finds the terms of a search query in student fields, case-insensitively. Storage backends without
+// a native highlighter or ranking function use it to build their search results.
+type Matcher struct {
+	terms   []string
+	pattern *regexp.Regexp
+}
+
+// NewMatcher creates a Matcher for the given search terms, which must not be empty.
+func NewMatcher(terms []string) *Matcher {
+	quoted := make([]string, len(terms))
+
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+
+	return &Matcher{terms: terms, pattern: regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))}
+}
+
+// Sentinels delimiting the matches in the highlights of native highlighters, like the highlight function of FTS5.
+// Highlighted turns them into <mark> tags once the text around them is escaped.
+const (
+	MatchStart = "\x02"
+	MatchEnd   = "\x03"
+)
+
+// Highlight HTML-escapes text and wraps every occurrence of a term in <mark> tags, so that highlights can be
+// rendered as HTML whatever the text holds.
+func (m *Matcher) Highlight(text string) string {
+	var b strings.Builder
+
+	last := 0
+
+	for _, match := range m.pattern.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:match[0]]))
+		b.WriteString("<mark>" + html.EscapeString(text[match[0]:match[1]]) + "</mark>")
+		last = match[1]
+	}
+
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
+
+// Highlighted HTML-escapes a highlight whose matches are delimited by MatchStart and MatchEnd, and turns the
+// sentinels into <mark> tags.
+func Highlighted(text string) string {
+	return highlightTags.Replace(html.EscapeString(text))
+}
+
+var highlightTags = strings.NewReplacer(MatchStart, "<mark>", MatchEnd, "</mark>")
+
+// Result builds the search result for a matching student, with its highlights and score.
+// Every occurrence of a term counts towards the score, and counts double when it starts a word. Without withEmails,
+// only the name is scored and highlighted.
+func (m *Matcher) Result(student types.Student, withEmails bool) types.StudentSearchResult {
+	fields := []string{student.Name}
+	if withEmails {
+		fields = append(fields, student.Email)
+	}
+
+	score := 0.0
+
+	for _, field := range fields {
+		for _, match := range m.pattern.FindAllStringIndex(field, -1) {
+			score++
+
+			if match[0] == 0 || strings.ContainsAny(field[match[0]-1:match[0]], " .@_-+") {
+				score++
+			}
+		}
+	}
+
+	result := types.StudentSearchResult{
+		Student: student,
+		Score:   score / float64(len(m.terms)),
+		Highlights: types.StudentHighlights{
+			Name:  m.Highlight(student.Name),
+			Email: html.EscapeString(student.Email),
+		},
+	}
+
+	if withEmails {
+		result.Highlights.Email = m.Highlight(student.Email)
+	}
+
+	return result
+}
+
+// Sort orders search results by descending score and then by ID.
+func Sort(results []types.StudentSearchResult) {
+	slices.SortStableFunc(results, func(a, b types.StudentSearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Student.Id, b.Student.Id)
+	})
+}
@@ -1,8 +1,262 @@
 package types
 
+import "time"
+
 type Student struct {
-	Id    int64  `json:"id"`
-	Name  string `json:"name" validate:"required"`
-	Email string `json:"email" validate:"required"`
-	Age   int    `json:"age" validate:"required"`
+	Id    int64  `json:"id" xml:"id"`
+	Name  string `json:"name" xml:"name" validate:"required,student_name"`
+	Email string `json:"email" xml:"email" validate:"required,student_email"`
+	Age   int    `json:"age" xml:"age" validate:"student_age"` // 0 is a valid age
+
+	// The student_* rules are the configurable ones of the validation package, which validators must come from.
+
+	// Version is bumped by every write to the student. It is read-only: clients send it back in If-Match headers.
+	Version int64 `json:"version" xml:"version"`
+
+	// DeletedAt is set while the student is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+
+	// MergedInto is the ID of the student this one was merged into, which also moved it to the trash.
+	MergedInto *int64 `json:"merged_into,omitempty" xml:"merged_into,omitempty"`
+}
+
+// StudentPatch holds the fields of a partial student update. Nil fields are left unchanged.
+type StudentPatch struct {
+	Name  *string
+	Email *string
+	Age   *int
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p StudentPatch) IsEmpty() bool {
+	return p.Name == nil && p.Email == nil && p.Age == nil
+}
+
+// Apply returns a copy of student with the fields of the patch applied.
+func (p StudentPatch) Apply(student Student) Student {
+	if p.Name != nil {
+		student.Name = *p.Name
+	}
+
+	if p.Email != nil {
+		student.Email = *p.Email
+	}
+
+	if p.Age != nil {
+		student.Age = *p.Age
+	}
+
+	return student
+}
+
+// Sortable student fields, used as values of StudentFilter.Sort.
+const (
+	SortByID    = "id"
+	SortByName  = "name"
+	SortByEmail = "email"
+	SortByAge   = "age"
+)
+
+// StudentFilter describes which students to list, in what order and which page of them.
+// Zero values mean "no filter"; MinAge and MaxAge are pointers so that 0 can be used as a bound.
+type StudentFilter struct {
+	Name   string // case-insensitive substring match on the name
+	Email  string // case-insensitive substring match on the email
+	MinAge *int   // inclusive lower bound on the age
+	MaxAge *int   // inclusive upper bound on the age
+
+	Deleted bool      // list soft-deleted students, the trash, instead of live ones
+	AsOf    time.Time // list the students as they were at this time instead of now, unless zero
+
+	Sort string // one of the SortBy* constants, defaults to SortByID
+	Desc bool   // sort in descending order
+
+	Limit  int // maximum number of students to return
+	Offset int // number of students to skip, ignored when Cursor is set
+
+	Cursor *StudentCursor // position to continue a keyset paginated listing from
+}
+
+// StudentCursor marks a position in a sorted student listing for keyset pagination.
+// Listing from a cursor returns the students strictly after it (or strictly before it when Backward is set),
+// which stays correct while students are created or deleted between pages.
+type StudentCursor struct {
+	ID       int64 // ID of the student at the cursor position
+	Value    any   // value of the sort column of that student, a string for name and email, an int for age, unused for id
+	Backward bool  // list the students before the cursor instead of after it
+}
+
+// StudentPage is one page of a filtered student listing along with the metadata needed to render pagers.
+type StudentPage struct {
+	Students   []Student `json:"students"`
+	Total      int64     `json:"total"`          // number of students matching the filter across all pages
+	Limit      int       `json:"limit"`          // page size used for this page
+	Offset     int       `json:"offset"`         // offset of the first student on this page
+	Page       int       `json:"page,omitempty"` // 1-based page number, omitted for cursor based pages
+	TotalPages int       `json:"total_pages"`    // number of pages available with this page size
+
+	NextCursor string `json:"next_cursor,omitempty"` // opaque token for the page after this one, empty on the last page
+	PrevCursor string `json:"prev_cursor,omitempty"` // opaque token for the page before this one, empty on the first page
+}
+
+// TableRows makes tabular responses, like CSV, list the students of the page.
+func (p StudentPage) TableRows() any {
+	return p.Students
+}
+
+// StudentSearchResult is a single match of a full-text student search.
+type StudentSearchResult struct {
+	Student    Student           `json:"student"`
+	Score      float64           `json:"score"`      // relevance of the match, higher is better
+	Highlights StudentHighlights `json:"highlights"` // matched fields with the matching fragments wrapped in <mark> tags
+}
+
+// StudentHighlights holds the searchable fields of a student with the matching fragments highlighted.
+type StudentHighlights struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// StudentDiff lists the fields that changed between two versions of a student.
+type StudentDiff struct {
+	ID      int64                  `json:"id"`
+	From    int64                  `json:"from"`    // older version
+	To      int64                  `json:"to"`      // newer version
+	Changes map[string]FieldChange `json:"changes"` // changed fields by JSON name, empty when nothing changed
+}
+
+// FieldChange holds the values of a field in the two versions compared by a StudentDiff.
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// DuplicateReport lists the groups of live students that were likely entered more than once.
+type DuplicateReport struct {
+	Groups    []DuplicateGroup `json:"groups"`
+	Threshold float64          `json:"threshold"` // lowest score of the reported matches
+	Scanned   int              `json:"scanned"`   // number of live students compared
+}
+
+// TableRows makes tabular responses, like CSV, list the students of the groups, one row each, along with the number
+// of their group counting from 1.
+func (r DuplicateReport) TableRows() any {
+	type row struct {
+		Group int `json:"group"`
+		Student
+	}
+
+	rows := []row{}
+
+	for i, group := range r.Groups {
+		for _, student := range group.Students {
+			rows = append(rows, row{Group: i + 1, Student: student})
+		}
+	}
+
+	return rows
+}
+
+// DuplicateGroup is a set of students linked to each other by matches, directly or through other students.
+type DuplicateGroup struct {
+	Students []Student        `json:"students"` // by ID
+	Matches  []DuplicateMatch `json:"matches"`  // best first
+}
+
+// DuplicateMatch is a pair of students that are likely the same person.
+type DuplicateMatch struct {
+	IDs     [2]int64 `json:"ids"`     // lower ID first
+	Score   float64  `json:"score"`   // from 0 to 1, higher is more likely
+	Reasons []string `json:"reasons"` // what matched, see the Reason* constants of the dedupe package
+}
+
+// Operations of a batch write, used as values of BatchOperation.Op.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete" // moves the student to the trash
+)
+
+// BatchOperation is one write of a batch. Student holds the student to create, or the ID, new fields and expected
+// version of the student to update; deletes only use its ID and version. A version of 0 skips the version check.
+type BatchOperation struct {
+	Op      string // one of the Batch* constants
+	Student Student
+}
+
+// Actions recorded in the audit log, used as values of AuditEntry.Action.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update" // full updates and patches
+	AuditDelete  = "delete" // moving a student to the trash
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditMerge   = "merge" // recorded for both students of a merge
+)
+
+// AuditEntry records one change to a student: who made it, when, in which request, and the student before and after.
+// Before is nil for creations and After is nil for purges.
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	StudentID int64     `json:"student_id"`
+	Action    string    `json:"action"` // one of the Audit* constants
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Before    *Student  `json:"before,omitempty"`
+	After     *Student  `json:"after,omitempty"`
+}
+
+// AuditFilter describes which audit entries to list and which page of them. Zero values mean "no filter".
+// Entries are listed newest first.
+type AuditFilter struct {
+	StudentID int64
+	Actor     string
+	Action    string
+	RequestID string
+	Since     time.Time // inclusive lower bound on the timestamp
+	Until     time.Time // exclusive upper bound on the timestamp
+
+	Limit  int // maximum number of entries to return
+	Offset int // number of entries to skip
+}
+
+// AuditPage is one page of a filtered audit log listing.
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int64        `json:"total"`  // number of entries matching the filter across all pages
+	Limit   int          `json:"limit"`  // page size used for this page
+	Offset  int          `json:"offset"` // offset of the first entry on this page
+}
+
+// TableRows makes tabular responses, like CSV, list the entries of the page.
+func (p AuditPage) TableRows() any {
+	return p.Entries
+}
+
+// IdempotencyRecord is the stored outcome of a request made with an Idempotency-Key header, which is replayed to
+// retries of the request until the record expires.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string // hash of the request, retries must have the same one
+
+	// Status is the status code of the response, 0 while the request that claimed the key is being processed.
+	Status int
+	Header map[string][]string // headers of the response that are replayed
+	Body   []byte
+
+	CreatedAt time.Time
+	ExpiresAt time.Time // the key can be claimed again from then on
+}
+
+// APIKey is a static key for authenticating API clients. Only a hash of the key is stored; the key itself is shown
+// once, when it is created.
+type APIKey struct {
+	ID        int64
+	Name      string   // unique, names the client in the audit log
+	Prefix    string   // first characters of the key, to tell keys apart
+	Hash      string   // hex SHA-256 of the key
+	Roles     []string // roles granted to the client, see auth.Policy
+	CreatedAt time.Time
+	RevokedAt *time.Time // set once the key no longer authenticates
 }
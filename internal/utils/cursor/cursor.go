@@ -0,0 +1,78 @@
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned when a cursor is malformed or its signature does not match.
+var ErrInvalid = errors.New("invalid cursor")
+
+// Codec turns pagination state into opaque, signed cursor tokens and back.
+// A token is the base64url encoded JSON payload followed by a dot and its base64url encoded HMAC-SHA256 signature,
+// so clients can pass it around but cannot forge or tamper with it.
+type Codec struct {
+	secret []byte
+}
+
+// New creates a Codec that signs cursors with the given secret.
+// When the secret is empty a random one is generated, which means cursors stop being valid when the process restarts.
+func New(secret []byte) (*Codec, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Codec{secret: secret}, nil
+}
+
+// Encode marshals v to JSON and returns it as a signed cursor token.
+func (c *Codec) Encode(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(encoded)), nil
+}
+
+// Decode verifies the signature of a cursor token and unmarshals its payload into v.
+func (c *Codec) Decode(token string, v any) error {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.sign(encoded)) {
+		return ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalid
+	}
+
+	return nil
+}
+
+func (c *Codec) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(encoded))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,72 @@
+package cursor_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/cursor"
+)
+
+type position struct {
+	ID    int64  `json:"id"`
+	Value string `json:"v"`
+}
+
+func TestCodec(t *testing.T) {
+	codec, err := cursor.New([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := codec.Encode(position{ID: 42, Value: "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded position
+	if err := codec.Decode(token, &decoded); err != nil || decoded != (position{ID: 42, Value: "Ann"}) {
+		t.Fatalf("Decode returned %+v, %v, want the encoded position", decoded, err)
+	}
+
+	other, err := cursor.New([]byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	random, err := cursor.New(nil) // a random secret of its own
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	forged, _ := other.Encode(position{ID: 1})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name  string
+		codec *cursor.Codec
+		token string
+	}{
+		{"foreign secret", other, token},
+		{"random secret", random, token},
+		{"tampered payload", codec, forgedPayload + "." + signature},
+		{"tampered signature", codec, payload + "." + strings.Repeat("A", len(signature))},
+		{"no signature", codec, payload},
+		{"invalid base64", codec, "!!!." + signature},
+		{"empty", codec, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.codec.Decode(tt.token, &decoded); !errors.Is(err, cursor.ErrInvalid) {
+				t.Fatalf("Decode returned %v, want ErrInvalid", err)
+			}
+		})
+	}
+
+	signed, _ := codec.Encode("not an object")
+	if err := codec.Decode(signed, &decoded); !errors.Is(err, cursor.ErrInvalid) {
+		t.Fatalf("Decode of a payload of another type returned %v, want ErrInvalid", err)
+	}
+}
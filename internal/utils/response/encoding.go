@@ -0,0 +1,179 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedMediaType is returned by Decode for request bodies in a format that can only be used for responses.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Encoder writes responses, and possibly reads request bodies, in one format.
+type Encoder struct {
+	MediaType   string   // media type matched against Accept and Content-Type headers
+	Aliases     []string // other media types the format goes by
+	ContentType string   // Content-Type header of the responses
+	// ProblemContentType is the Content-Type header of problem details, ContentType when empty.
+	ProblemContentType string
+	Encode             func(w io.Writer, data any) error
+	Decode             func(r io.Reader, v any) error // nil for formats that cannot be used for request bodies
+}
+
+// encoders is the registry of formats, in order of preference. The first one is the default.
+var encoders = []Encoder{
+	{MediaType: "application/json", Aliases: []string{"application/problem+json"}, ContentType: "application/json", ProblemContentType: "application/problem+json", Encode: encodeJSON, Decode: decodeJSON},
+	{MediaType: "application/xml", Aliases: []string{"text/xml", "application/problem+xml"}, ContentType: "application/xml; charset=utf-8", ProblemContentType: "application/problem+xml; charset=utf-8", Encode: encodeXML, Decode: decodeXML},
+	{MediaType: "text/csv", ContentType: "text/csv; charset=utf-8", Encode: encodeCSV},
+	{MediaType: "application/msgpack", Aliases: []string{"application/x-msgpack", "application/vnd.msgpack"}, ContentType: "application/msgpack", Encode: encodeMsgpack, Decode: decodeMsgpack},
+}
+
+// Register adds a format to the registry, or replaces the one with the same media type.
+// It is meant to be called during initialization, before any request is served.
+func Register(encoder Encoder) {
+	for i := range encoders {
+		if encoders[i].MediaType == encoder.MediaType {
+			encoders[i] = encoder
+
+			return
+		}
+	}
+
+	encoders = append(encoders, encoder)
+}
+
+// MediaTypes lists the media types of the registered formats, in order of preference.
+func MediaTypes() []string {
+	mediaTypes := make([]string, len(encoders))
+	for i, encoder := range encoders {
+		mediaTypes[i] = encoder.MediaType
+	}
+
+	return mediaTypes
+}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept returns the media ranges of an Accept header, skipping malformed ones.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	return ranges
+}
+
+// quality returns the weight the media ranges give to a format: that of the most specific range matching one of
+// its media types, 0 when none does.
+func quality(ranges []mediaRange, encoder Encoder) float64 {
+	q, specificity := 0.0, 0
+
+	for _, mediaType := range append([]string{encoder.MediaType}, encoder.Aliases...) {
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+
+		for _, r := range ranges {
+			s := 0
+
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 3
+			case r.typ == typ && r.subtype == "*":
+				s = 2
+			case r.typ == "*" && r.subtype == "*":
+				s = 1
+			}
+
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+	}
+
+	return q
+}
+
+// Negotiate picks the format to respond in from an Accept header: the one the client weighs highest, preferring
+// formats registered first on ties. A missing or unreadable header accepts the default format. It reports false
+// when the header rules out every format.
+func Negotiate(accept string) (Encoder, bool) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return encoders[0], true
+	}
+
+	best, bestQ := Encoder{}, 0.0
+
+	for _, encoder := range encoders {
+		if q := quality(ranges, encoder); q > bestQ {
+			best, bestQ = encoder, q
+		}
+	}
+
+	return best, bestQ > 0
+}
+
+// Write writes data with the given status code in the format negotiated from the Accept header of the request.
+// Requests that accept none of the formats get the default one; the Negotiate middleware turns them away before
+// their handler runs.
+func Write(w http.ResponseWriter, r *http.Request, status int, data interface{}) error {
+	encoder, ok := Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		encoder = encoders[0]
+	}
+
+	w.Header().Add("Vary", "Accept") // caches must not serve a response in one format to clients asking for another
+	w.Header().Set("Content-Type", encoder.ContentType)
+	w.WriteHeader(status)
+
+	return encoder.Encode(w, data)
+}
+
+// Decode reads the body of a request into v in the format of its Content-Type. Bodies without a registered
+// content type are read as JSON, as they always have been; formats that cannot be decoded return an error
+// wrapping ErrUnsupportedMediaType.
+func Decode(r *http.Request, v any) error {
+	encoder := encoders[0]
+
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+		for _, e := range encoders {
+			if e.MediaType == mediaType || slices.Contains(e.Aliases, mediaType) {
+				encoder = e
+			}
+		}
+	}
+
+	if encoder.Decode == nil {
+		return fmt.Errorf("%w: %s request bodies are not supported", ErrUnsupportedMediaType, encoder.MediaType)
+	}
+
+	return encoder.Decode(r.Body, v)
+}
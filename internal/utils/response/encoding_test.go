@@ -0,0 +1,138 @@
+package response_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string // media type picked, empty when nothing is acceptable
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "application/xml"},
+		{"text/xml", "application/xml"},
+		{"application/json;q=0.5, text/csv", "text/csv"},
+		{"application/x-msgpack", "application/msgpack"},
+		{"text/*", "application/xml"}, // text/xml comes first
+		{"application/json;q=0, */*", "application/xml"},
+		{"image/png", ""},
+		{"application/json;q=0", ""},
+	}
+
+	for _, test := range tests {
+		encoder, ok := response.Negotiate(test.accept)
+
+		got := ""
+		if ok {
+			got = encoder.MediaType
+		}
+
+		if got != test.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", test.accept, got, test.want)
+		}
+	}
+}
+
+// write writes data as the response to a request with the given Accept header.
+func write(t *testing.T, accept string, data any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", accept)
+
+	w := httptest.NewRecorder()
+
+	if err := response.Write(w, r, http.StatusOK, data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	return w
+}
+
+func TestWriteXML(t *testing.T) {
+	w := write(t, "application/xml", map[string]any{"id": 3, "tags": []string{"a"}, "Full Name": nil})
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<response><entry key="Full Name"></entry><id>3</id><tags><item>a</item></tags></response>`
+
+	if got := w.Body.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != "application/xml; charset=utf-8" {
+		t.Errorf("Content-Type is %q", got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	page := types.StudentPage{
+		Students: []types.Student{{Id: 1, Name: "Ann, Lee", Email: "ann@example.com", Age: 20, Version: 1}},
+		Total:    1,
+	}
+
+	want := "id,name,email,age,version\n1,\"Ann, Lee\",ann@example.com,20,1\n"
+
+	if got := write(t, "text/csv", page).Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteMsgpack(t *testing.T) {
+	w := write(t, "application/msgpack", types.Student{Id: 1, Name: "Ann", Email: "ann@example.com", Age: 20})
+
+	var got map[string]any
+	if err := msgpack.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body is not MessagePack: %v", err)
+	}
+
+	if got["name"] != "Ann" || got["age"] != int8(20) {
+		t.Errorf("decoded %v", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	packed, _ := msgpack.Marshal(map[string]any{"name": "Ann", "email": "ann@example.com", "age": 20})
+
+	bodies := map[string][]byte{
+		"":                                  []byte(`{"name":"Ann","email":"ann@example.com","age":20}`),
+		"application/json":                  []byte(`{"name":"Ann","email":"ann@example.com","age":20}`),
+		"text/xml; charset=utf-8":           []byte(`<student><name>Ann</name><email>ann@example.com</email><age>20</age></student>`),
+		"application/vnd.msgpack":           packed,
+		"application/x-www-form-urlencoded": []byte(`{"name":"Ann","email":"ann@example.com","age":20}`), // read as JSON, like curl -d sends it
+	}
+
+	for contentType, body := range bodies {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+
+		var student types.Student
+
+		if err := response.Decode(r, &student); err != nil {
+			t.Errorf("%q: %v", contentType, err)
+
+			continue
+		}
+
+		if student.Name != "Ann" || student.Email != "ann@example.com" || student.Age != 20 {
+			t.Errorf("%q decodes to %+v", contentType, student)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name\nAnn\n"))
+	r.Header.Set("Content-Type", "text/csv")
+
+	if err := response.Decode(r, &types.Student{}); !errors.Is(err, response.ErrUnsupportedMediaType) {
+		t.Errorf("CSV body decodes with error %v, want ErrUnsupportedMediaType", err)
+	}
+}
@@ -0,0 +1,377 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The XML, CSV and MessagePack formats are all written from the JSON form of the data, so that they carry the
+// same fields, under the same names, as the JSON responses.
+
+// Table is implemented by responses that wrap a list, like a page of students, to have the tabular formats write
+// the rows of the list rather than a single row for the whole response.
+type Table interface {
+	TableRows() any
+}
+
+// object is a JSON object with its members in their original order.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(m.key)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// toTree returns the JSON form of data as objects, []any, json.Number, string, bool and nil values.
+func toTree(data any) (any, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	return readTree(decoder)
+}
+
+func readTree(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		obj := object{}
+
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := readTree(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, member{key: key.(string), value: value})
+		}
+
+		_, err = decoder.Token() // the closing brace
+
+		return obj, err
+	case json.Delim('['):
+		array := []any{}
+
+		for decoder.More() {
+			value, err := readTree(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		_, err = decoder.Token() // the closing bracket
+
+		return array, err
+	default:
+		return token, nil
+	}
+}
+
+func encodeJSON(w io.Writer, data any) error {
+	return json.NewEncoder(w).Encode(data)
+}
+
+func decodeJSON(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// encodeXML writes data as a <response> document, or under the root element named by its xmlRoot method.
+// Objects become elements named after their keys (or <entry key="..."> elements for keys that are not valid XML
+// names) and array values become <item> elements.
+func encodeXML(w io.Writer, data any) error {
+	root := xml.StartElement{Name: xml.Name{Local: "response"}}
+	if rooted, ok := data.(interface{ xmlRoot() xml.Name }); ok {
+		root = xml.StartElement{Name: rooted.xmlRoot()}
+	}
+
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+
+	if err := writeXML(encoder, root, tree); err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+// writeXMLKey writes a value as the element named after an object key.
+func writeXMLKey(encoder *xml.Encoder, key string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: key}}
+	if !validXMLName(key) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}}}
+	}
+
+	return writeXML(encoder, start, value)
+}
+
+// writeXML writes a value as the element opened by start.
+func writeXML(encoder *xml.Encoder, start xml.StartElement, value any) error {
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case object:
+		for _, m := range value {
+			if err := writeXMLKey(encoder, m.key, m.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			if err := writeXMLKey(encoder, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+		// null is an empty element
+	default:
+		if err := encoder.EncodeToken(xml.CharData(scalar(value))); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
+
+// validXMLName reports whether a key can be used as an element name as is.
+func validXMLName(name string) bool {
+	if name == "" || len(name) >= 3 && (name[0]|0x20) == 'x' && (name[1]|0x20) == 'm' && (name[2]|0x20) == 'l' {
+		return false // names starting with xml are reserved
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c == '-' || c == '.' || c >= '0' && c <= '9'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func decodeXML(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// encodeCSV writes data as a table with a header row: one row per value of an array (or of the rows of a Table)
+// and a single row for anything else. Object keys are the columns; nested objects and arrays are written as JSON.
+func encodeCSV(w io.Writer, data any) error {
+	if table, ok := data.(Table); ok {
+		data = table.TableRows()
+	}
+
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+
+	rows, ok := tree.([]any)
+	if !ok {
+		rows = []any{tree}
+	}
+
+	var header []string
+
+	columns := map[string]int{}
+
+	for _, row := range rows {
+		obj, ok := row.(object)
+		if !ok {
+			obj = object{{key: "value", value: row}} // values that are not objects go in a column of their own
+		}
+
+		for _, m := range obj {
+			if _, ok := columns[m.key]; !ok {
+				columns[m.key] = len(header)
+				header = append(header, m.key)
+			}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+
+	if len(header) > 0 {
+		writer.Write(header)
+	}
+
+	for _, row := range rows {
+		obj, ok := row.(object)
+		if !ok {
+			obj = object{{key: "value", value: row}}
+		}
+
+		record := make([]string, len(header))
+
+		for _, m := range obj {
+			cell, err := csvCell(m.value)
+			if err != nil {
+				return err
+			}
+
+			record[columns[m.key]] = cell
+		}
+
+		writer.Write(record)
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func csvCell(value any) (string, error) {
+	switch value.(type) {
+	case object, []any:
+		encoded, err := json.Marshal(value)
+
+		return string(encoded), err
+	case nil:
+		return "", nil
+	default:
+		return scalar(value), nil
+	}
+}
+
+// scalar returns the text of a JSON string, number or boolean.
+func scalar(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func encodeMsgpack(w io.Writer, data any) error {
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+
+	return writeMsgpack(msgpack.NewEncoder(w), tree)
+}
+
+func writeMsgpack(encoder *msgpack.Encoder, value any) error {
+	switch value := value.(type) {
+	case object:
+		if err := encoder.EncodeMapLen(len(value)); err != nil {
+			return err
+		}
+
+		for _, m := range value {
+			if err := encoder.EncodeString(m.key); err != nil {
+				return err
+			}
+
+			if err := writeMsgpack(encoder, m.value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case []any:
+		if err := encoder.EncodeArrayLen(len(value)); err != nil {
+			return err
+		}
+
+		for _, item := range value {
+			if err := writeMsgpack(encoder, item); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return encoder.EncodeInt(i) // integers are written in the smallest encoding that holds them
+		}
+
+		f, err := value.Float64()
+		if err != nil {
+			return err
+		}
+
+		return encoder.EncodeFloat64(f)
+	default:
+		return encoder.Encode(value) // strings, booleans and nil
+	}
+}
+
+// decodeMsgpack reads a MessagePack body into v through its JSON form, so that v is filled by its JSON field names.
+func decodeMsgpack(r io.Reader, v any) error {
+	var value any
+
+	if err := msgpack.NewDecoder(r).Decode(&value); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, v)
+}
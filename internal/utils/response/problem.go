@@ -0,0 +1,179 @@
+package response
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/validation"
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes carried by problem details. They are part of the API: clients branch on them, so they never change.
+const (
+	CodeBadRequest           = "bad_request"            // the path, query or body of the request is malformed
+	CodeValidationFailed     = "validation_failed"      // fields of the body break validation rules, listed in errors
+	CodeUnauthorized         = "unauthorized"           // the request has no valid API key or token
+	CodeForbidden            = "forbidden"              // the caller lacks the permission the request requires
+	CodeNotFound             = "not_found"              // there is no such student
+	CodeConflict             = "conflict"               // the request conflicts with the stored students
+	CodePreconditionFailed   = "precondition_failed"    // If-Match does not match the version of the student
+	CodePayloadTooLarge      = "payload_too_large"      // the body is over the size limit of the endpoint
+	CodeUnsupportedMediaType = "unsupported_media_type" // the body is in a format the endpoint cannot read
+	CodeNotAcceptable        = "not_acceptable"         // the Accept header rules out every response format
+	CodeUnprocessable        = "unprocessable"          // the body is well-formed but cannot be applied
+	CodeBatchAborted         = "batch_aborted"          // the operation was rolled back with a failed one of its batch
+	CodeIdempotencyKeyReused = "idempotency_key_reused" // the Idempotency-Key was already used for a different request
+	CodeRequestInProgress    = "request_in_progress"    // the request with the same Idempotency-Key is still being processed
+	CodeInternal             = "internal_error"         // the server failed, the request may be retried
+)
+
+// problemTypePrefix starts the type URI of every problem, which ends with its code.
+const problemTypePrefix = "urn:golang-students-api:problem:"
+
+// statusCodes holds the error code of the problems of each status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusNotAcceptable:         CodeNotAcceptable,
+	http.StatusConflict:              CodeConflict,
+	http.StatusPreconditionFailed:    CodePreconditionFailed,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusFailedDependency:      CodeBatchAborted,
+	http.StatusInternalServerError:   CodeInternal,
+}
+
+// StatusCode returns the error code of the problems reported with an HTTP status code.
+func StatusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+
+	return CodeBadRequest
+}
+
+// Problem is an RFC 7807 problem details body, extended with a stable error code, the ID of the request, the
+// invalid fields of the body and the student a conflict is with.
+type Problem struct {
+	Type      string       `json:"type"`               // URI identifying the kind of problem, ends with Code
+	Title     string       `json:"title"`              // short summary of the kind of problem
+	Status    int          `json:"status"`             // HTTP status code of the response
+	Detail    string       `json:"detail,omitempty"`   // explanation of this occurrence of the problem
+	Instance  string       `json:"instance,omitempty"` // path of the request that failed
+	Code      string       `json:"code"`               // one of the Code* constants
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"` // invalid fields, for validation failures
+
+	ConflictingID int64 `json:"conflicting_id,omitempty"` // student the request conflicts with, for duplicate emails
+}
+
+// FieldError describes a field of the request body that breaks a validation rule.
+type FieldError struct {
+	Pointer string `json:"pointer"` // JSON pointer to the field in the body, e.g. /email
+	Rule    string `json:"rule"`    // name of the rule, e.g. required
+	Message string `json:"message"`
+}
+
+// NewProblem returns the problem of a request that failed with the given status code and error.
+func NewProblem(status int, err error) Problem {
+	problem := Problem{Status: status, Code: StatusCode(status)}
+
+	if err != nil {
+		problem.Detail = err.Error()
+	}
+
+	return problem
+}
+
+// ValidationProblem returns the problem of a request whose body fails validation.
+func ValidationProblem(errs validator.ValidationErrors) Problem {
+	fieldErrs := ValidationErrors(errs)
+
+	messages := make([]string, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		messages[i] = fieldErr.Message
+	}
+
+	return Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: strings.Join(messages, ", "),
+		Errors: fieldErrs,
+	}
+}
+
+// ValidationErrors describes the fields of a body that fail validation. The errors must come from a validator of
+// the validation package, which reports fields under their JSON names.
+func ValidationErrors(errs validator.ValidationErrors) []FieldError {
+	fieldErrs := make([]FieldError, len(errs))
+
+	for i, err := range errs {
+		fieldErrs[i] = FieldError{Pointer: "/" + escapePointer(err.Field()), Rule: err.ActualTag(), Message: validation.Message(err)}
+	}
+
+	return fieldErrs
+}
+
+// escapePointer escapes a key for use as a JSON pointer reference token (RFC 6901).
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// xmlRoot names the root element of problems written as XML, as RFC 7807 does.
+func (Problem) xmlRoot() xml.Name {
+	return xml.Name{Space: "urn:ietf:rfc:7807", Local: "problem"}
+}
+
+// WriteProblem writes a problem in the format negotiated from the Accept header of the request, with the
+// problem+json or problem+xml content type for JSON and XML. The type, title, instance and request ID of the
+// problem are filled in when they are empty.
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem Problem) error {
+	if problem.Code == "" {
+		problem.Code = StatusCode(problem.Status)
+	}
+
+	if problem.Type == "" {
+		problem.Type = problemTypePrefix + problem.Code
+	}
+
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+
+	if problem.RequestID == "" {
+		problem.RequestID = w.Header().Get("X-Request-ID") // set by the request ID middleware
+	}
+
+	encoder, ok := Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		encoder = encoders[0]
+	}
+
+	contentType := encoder.ContentType
+	if encoder.ProblemContentType != "" {
+		contentType = encoder.ProblemContentType
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(problem.Status)
+
+	return encoder.Encode(w, problem)
+}
+
+// WriteError writes the problem of a request that failed with the given status code and error.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, err error) error {
+	return WriteProblem(w, r, NewProblem(status, err))
+}
@@ -0,0 +1,107 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/utils/response"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/validation"
+	"github.com/go-playground/validator/v10"
+)
+
+// writeProblem writes a problem as the response to a request for /api/students with the given Accept header.
+func writeProblem(t *testing.T, accept string, problem response.Problem) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/students", nil)
+	r.Header.Set("Accept", accept)
+
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "abc")
+
+	if err := response.WriteProblem(w, r, problem); err != nil {
+		t.Fatalf("WriteProblem: %v", err)
+	}
+
+	return w
+}
+
+func TestWriteProblem(t *testing.T) {
+	w := writeProblem(t, "", response.NewProblem(http.StatusNotFound, errors.New("no student with ID 9")))
+
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("got status %d and Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var got response.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+
+	want := response.Problem{
+		Type:      "urn:golang-students-api:problem:not_found",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "no student with ID 9",
+		Instance:  "/api/students",
+		Code:      response.CodeNotFound,
+		RequestID: "abc",
+	}
+
+	if got.Type != want.Type || got.Title != want.Title || got.Status != want.Status || got.Detail != want.Detail ||
+		got.Instance != want.Instance || got.Code != want.Code || got.RequestID != want.RequestID {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteProblemXML(t *testing.T) {
+	w := writeProblem(t, "application/xml", response.NewProblem(http.StatusConflict, nil))
+
+	if got := w.Header().Get("Content-Type"); got != "application/problem+xml; charset=utf-8" {
+		t.Errorf("Content-Type is %q", got)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, `<problem xmlns="urn:ietf:rfc:7807"><type>`) || !strings.Contains(body, "<code>conflict</code>") {
+		t.Errorf("body is %s", body)
+	}
+}
+
+func TestValidationProblem(t *testing.T) {
+	validate, err := validation.New(config.Validation{NameMinLength: 1})
+	if err != nil {
+		t.Fatalf("validation.New: %v", err)
+	}
+
+	err = validate.Struct(types.Student{Name: "Ann"})
+
+	var validateErrs validator.ValidationErrors
+	if !errors.As(err, &validateErrs) {
+		t.Fatalf("validation error is %v", err)
+	}
+
+	problem := response.ValidationProblem(validateErrs)
+
+	if problem.Status != http.StatusBadRequest || problem.Code != response.CodeValidationFailed {
+		t.Errorf("got status %d and code %s", problem.Status, problem.Code)
+	}
+
+	want := []response.FieldError{
+		{Pointer: "/email", Rule: "required", Message: "field email is required"},
+	}
+
+	if len(problem.Errors) != len(want) {
+		t.Fatalf("got errors %+v, want %+v", problem.Errors, want)
+	}
+
+	for i := range want {
+		if problem.Errors[i] != want[i] {
+			t.Errorf("error %d is %+v, want %+v", i, problem.Errors[i], want[i])
+		}
+	}
+}
@@ -2,23 +2,10 @@ package response
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"strings"
-
-	"github.com/go-playground/validator/v10"
-)
-
-type Response struct {
-	Status string `json:"status"`
-	Error  string `json:"error"`
-}
-
-const (
-	StatusOK    = "OK"
-	StatusError = "Error"
 )
 
+// WriteJSON writes data as JSON with the given status code, whatever the Accept header of the request.
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -26,28 +13,3 @@ func WriteJSON(w http.ResponseWriter, status int, data interface{}) error {
 	return json.NewEncoder(w).Encode(data)
 
 }
-
-func GeneralError(err error) Response {
-	return Response{
-		Status: StatusError,
-		Error:  err.Error(),
-	}
-}
-
-func ValidationError(errs validator.ValidationErrors) Response {
-	var errMsgs []string
-
-	for _, err := range errs {
-		switch err.ActualTag() {
-		case "required":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is required", err.Field()))
-		default:
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is invalid", err.Field()))
-		}
-	}
-
-	return Response{
-		Status: StatusError,
-		Error:  strings.Join(errMsgs, ", "),
-	}
-}
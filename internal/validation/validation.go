@@ -0,0 +1,163 @@
+// Package validation builds the validator shared by everything that checks students, with the rules of the
+// configuration registered on it.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/go-playground/validator/v10"
+)
+
+// Rules registered by New besides the built-in ones of the validator.
+const (
+	ruleNameChars    = "name_chars"    // letters, spaces, apostrophes, hyphens and periods, with at least one letter
+	ruleEmailAddress = "email_address" // a bare RFC 5322 address, without display name or angle brackets
+	ruleEmailDomain  = "email_domain"  // the domain, or a subdomain of it, is one of the space-separated parameters
+)
+
+// New returns a validator for students following the given rules. The validate tags of types.Student refer to them
+// through the student_name, student_email and student_age aliases. Fields are reported under their JSON names.
+func New(rules config.Validation) (*validator.Validate, error) {
+	if rules.NameMinLength < 0 || rules.NameMaxLength < 0 || rules.MinAge < 0 || rules.MaxAge < 0 {
+		return nil, errors.New("validation lengths and ages cannot be negative")
+	}
+
+	if rules.NameMaxLength > 0 && rules.NameMinLength > rules.NameMaxLength {
+		return nil, fmt.Errorf("name_min_length %d is above name_max_length %d", rules.NameMinLength, rules.NameMaxLength)
+	}
+
+	if rules.MaxAge > 0 && rules.MinAge > rules.MaxAge {
+		return nil, fmt.Errorf("min_age %d is above max_age %d", rules.MinAge, rules.MaxAge)
+	}
+
+	domains := make([]string, len(rules.EmailDomains))
+
+	for i, domain := range rules.EmailDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+
+		if domain == "" || strings.ContainsAny(domain, " ,|@") {
+			return nil, fmt.Errorf("invalid email domain %q", rules.EmailDomains[i])
+		}
+
+		domains[i] = domain
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+
+		return name
+	})
+
+	for tag, fn := range map[string]validator.Func{
+		ruleNameChars:    nameChars,
+		ruleEmailAddress: emailAddress,
+		ruleEmailDomain:  emailDomain,
+	} {
+		if err := validate.RegisterValidation(tag, fn); err != nil {
+			return nil, err
+		}
+	}
+
+	name := fmt.Sprintf("min=%d", rules.NameMinLength)
+	if rules.NameMaxLength > 0 {
+		name += fmt.Sprintf(",max=%d", rules.NameMaxLength)
+	}
+
+	email := "max=254," + ruleEmailAddress // the longest address SMTP can deliver to
+	if len(domains) > 0 {
+		email += fmt.Sprintf(",%s=%s", ruleEmailDomain, strings.Join(domains, " "))
+	}
+
+	age := fmt.Sprintf("min=%d", rules.MinAge)
+	if rules.MaxAge > 0 {
+		age += fmt.Sprintf(",max=%d", rules.MaxAge)
+	}
+
+	validate.RegisterAlias("student_name", name+","+ruleNameChars)
+	validate.RegisterAlias("student_email", email)
+	validate.RegisterAlias("student_age", age)
+
+	return validate, nil
+}
+
+func nameChars(fl validator.FieldLevel) bool {
+	letters := 0
+
+	for _, r := range fl.Field().String() {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.Is(unicode.Mn, r), r == ' ', r == '\'', r == '’', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+
+	return letters > 0
+}
+
+func emailAddress(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+
+	address, err := mail.ParseAddress(value)
+
+	return err == nil && address.Name == "" && address.String() == "<"+value+">" // anything around the address, like a comment, is left out of String
+}
+
+func emailDomain(fl validator.FieldLevel) bool {
+	_, domain, ok := strings.Cut(fl.Field().String(), "@")
+	if !ok {
+		return false
+	}
+
+	domain = strings.ToLower(domain)
+
+	for _, allowed := range strings.Fields(fl.Param()) {
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Message describes a validation failure of a field for clients.
+func Message(err validator.FieldError) string {
+	field := err.Field()
+
+	switch err.ActualTag() {
+	case "required":
+		return fmt.Sprintf("field %s is required", field)
+	case "min":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("field %s must be at least %s characters long", field, err.Param())
+		}
+
+		return fmt.Sprintf("field %s must be at least %s", field, err.Param())
+	case "max":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("field %s must be at most %s characters long", field, err.Param())
+		}
+
+		return fmt.Sprintf("field %s must be at most %s", field, err.Param())
+	case ruleNameChars:
+		return fmt.Sprintf("field %s must contain letters and only letters, spaces, apostrophes, hyphens and periods", field)
+	case ruleEmailAddress:
+		return fmt.Sprintf("field %s must be a valid email address", field)
+	case ruleEmailDomain:
+		return fmt.Sprintf("field %s must use one of the domains %s", field, strings.Join(strings.Fields(err.Param()), ", "))
+	default:
+		return fmt.Sprintf("field %s is invalid", field)
+	}
+}
@@ -0,0 +1,117 @@
+package validation_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/config"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/types"
+	"github.com/AnshSinghSonkhia/golang-students-api/internal/validation"
+	"github.com/go-playground/validator/v10"
+)
+
+// failures returns the field and rule of every validation failure of a student, as "field:rule".
+func failures(t *testing.T, validate *validator.Validate, student types.Student) []string {
+	t.Helper()
+
+	err := validate.Struct(student)
+	if err == nil {
+		return nil
+	}
+
+	var validateErrs validator.ValidationErrors
+	if !errors.As(err, &validateErrs) {
+		t.Fatalf("validation error is %v", err)
+	}
+
+	var got []string
+	for _, fieldErr := range validateErrs {
+		got = append(got, fieldErr.Field()+":"+fieldErr.ActualTag())
+	}
+
+	return got
+}
+
+func TestStudentRules(t *testing.T) {
+	validate, err := validation.New(config.Validation{NameMinLength: 2, NameMaxLength: 20, MinAge: 0, MaxAge: 120})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		student types.Student
+		want    string
+	}{
+		{types.Student{Name: "Zoë O'Brien-Smith", Email: "zoe@example.com", Age: 0}, ""},
+		{types.Student{Name: "J. R.", Email: "\"j r\"@example.com", Age: 120}, ""},
+		{types.Student{Name: "A", Email: "a@example.com"}, "name:min"},
+		{types.Student{Name: strings.Repeat("a", 21), Email: "a@example.com"}, "name:max"},
+		{types.Student{Name: "R2-D2", Email: "r2@example.com"}, "name:name_chars"},
+		{types.Student{Name: "--", Email: "a@example.com"}, "name:name_chars"},
+		{types.Student{Name: "Ann", Email: "x"}, "email:email_address"},
+		{types.Student{Name: "Ann", Email: "Ann <ann@example.com>"}, "email:email_address"},
+		{types.Student{Name: "Ann", Email: "ann@example.com (Ann)"}, "email:email_address"},
+		{types.Student{Name: "Ann", Email: "ann@example.com", Age: -5}, "age:min"},
+		{types.Student{Name: "Ann", Email: "ann@example.com", Age: 121}, "age:max"},
+		{types.Student{}, "name:required,email:required"},
+	}
+
+	for _, test := range tests {
+		if got := strings.Join(failures(t, validate, test.student), ","); got != test.want {
+			t.Errorf("%+v fails with %q, want %q", test.student, got, test.want)
+		}
+	}
+}
+
+func TestEmailDomains(t *testing.T) {
+	validate, err := validation.New(config.Validation{NameMinLength: 1, EmailDomains: []string{"School.edu", "@example.org"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for email, want := range map[string]string{
+		"ann@school.edu":      "",
+		"ann@MAIL.School.EDU": "",
+		"ann@example.org":     "",
+		"ann@notschool.edu":   "email:email_domain",
+		"ann@school.edu.evil": "email:email_domain",
+		"ann@example.com":     "email:email_domain",
+	} {
+		if got := strings.Join(failures(t, validate, types.Student{Name: "Ann", Email: email}), ","); got != want {
+			t.Errorf("%s fails with %q, want %q", email, got, want)
+		}
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, rules := range []config.Validation{
+		{NameMinLength: 10, NameMaxLength: 5},
+		{MinAge: 30, MaxAge: 20},
+		{MinAge: -1},
+		{EmailDomains: []string{"a b.com"}},
+	} {
+		if _, err := validation.New(rules); err == nil {
+			t.Errorf("New(%+v) succeeded, want an error", rules)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	validate, _ := validation.New(config.Validation{NameMinLength: 1, MaxAge: 120, EmailDomains: []string{"school.edu"}})
+
+	err := validate.Struct(types.Student{Name: "Ann", Email: "ann@example.com", Age: 130})
+
+	var validateErrs validator.ValidationErrors
+	errors.As(err, &validateErrs)
+
+	var got []string
+	for _, fieldErr := range validateErrs {
+		got = append(got, validation.Message(fieldErr))
+	}
+
+	want := "field email must use one of the domains school.edu; field age must be at most 120"
+	if strings.Join(got, "; ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, "; "), want)
+	}
+}